@@ -0,0 +1,187 @@
+// Package client is a Go client for go-redis, and for Redis servers in
+// general as far as the commands go.
+//
+// A Client is safe for concurrent use and keeps a pool of connections:
+//
+//	c := client.New(client.Options{Addr: "localhost:6379"})
+//	defer c.Close()
+//
+//	if err := c.Set(ctx, "greeting", "hello", time.Minute).Err(); err != nil {
+//		...
+//	}
+//	val, err := c.Get(ctx, "greeting").Result()
+//	if errors.Is(err, client.ErrNil) {
+//		// no such key
+//	}
+//
+// Every command of the server has a typed method returning a *Cmd with the
+// parsed result. Commands queued in a Pipeline go out in a single write and
+// their replies are read in one go; a TxPipeline also wraps them in
+// MULTI/EXEC. Watch runs optimistic transactions on a dedicated
+// connection, Subscribe and PSubscribe open one for pub/sub.
+//
+// Every method takes a context: its deadline caps the Options timeouts and
+// cancelling it interrupts the command. A connection that was interrupted
+// is not reused, since its reply may be half read.
+//
+// PSYNC and REPLCONF are spoken by replicas only and have no methods; Do
+// sends any command.
+package client
+
+import "context"
+
+// Client is a pool of connections to one server
+type Client struct {
+	cmdable
+
+	opts Options
+	pool *pool
+}
+
+// New returns a Client for opts. Connections are dialed on first use.
+func New(opts Options) *Client {
+	opts = opts.withDefaults()
+	c := &Client{opts: opts}
+	c.pool = newPool(&c.opts)
+	c.cmdable = c.process
+	return c
+}
+
+// Close closes the idle connections, and the others as soon as the
+// commands using them are done. Commands run afterwards fail with
+// ErrClosed.
+func (c *Client) Close() error {
+	return c.pool.Close()
+}
+
+// Options returns the options in use, with the defaults filled in
+func (c *Client) Options() Options {
+	return c.opts
+}
+
+// PoolStats returns what the connection pool did so far
+func (c *Client) PoolStats() PoolStats {
+	return c.pool.Stats()
+}
+
+// Do sends any command and returns the reply as a Go value: string, int64,
+// []any, nil or an Error inside an array. A nil reply is ErrNil.
+func (c *Client) Do(ctx context.Context, args ...string) *Cmd[any] {
+	cmd := newCmd(parseAny, args...)
+	c.process(ctx, cmd)
+	return cmd
+}
+
+// process runs one command on a pooled connection
+func (c *Client) process(ctx context.Context, cmd command) {
+	c.processPipeline(ctx, []command{cmd})
+}
+
+func (c *Client) processPipeline(ctx context.Context, cmds []command) error {
+	cn, err := c.pool.Get(ctx)
+	if err != nil {
+		setErr(cmds, err)
+		return err
+	}
+	defer c.pool.Put(cn)
+
+	return cn.roundTrip(ctx, cmds)
+}
+
+func (c *Client) processTxPipeline(ctx context.Context, cmds []command) error {
+	cn, err := c.pool.Get(ctx)
+	if err != nil {
+		setErr(cmds, err)
+		return err
+	}
+	defer c.pool.Put(cn)
+
+	return cn.txRoundTrip(ctx, cmds)
+}
+
+// Pipeline returns a pipeline that sends its commands in one round trip
+func (c *Client) Pipeline() *Pipeline {
+	return newPipeline(c.processPipeline)
+}
+
+// TxPipeline returns a pipeline that runs its commands as a MULTI/EXEC
+// transaction
+func (c *Client) TxPipeline() *Pipeline {
+	return newPipeline(c.processTxPipeline)
+}
+
+// Pipelined queues the commands fn issues on a Pipeline and runs them
+func (c *Client) Pipelined(ctx context.Context, fn func(*Pipeline) error) error {
+	return c.Pipeline().run(ctx, fn)
+}
+
+// TxPipelined queues the commands fn issues on a TxPipeline and runs them
+// as one transaction
+func (c *Client) TxPipelined(ctx context.Context, fn func(*Pipeline) error) error {
+	return c.TxPipeline().run(ctx, fn)
+}
+
+// Watch runs fn on a connection of its own that watches keys, for check
+// and set: fn reads the keys, then writes with Tx.TxPipelined. If one of
+// the keys changed meanwhile, the transaction doesn't run and fails with
+// ErrTxFailed, so fn can be retried.
+func (c *Client) Watch(ctx context.Context, fn func(*Tx) error, keys ...string) error {
+	cn, err := c.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	tx := newTx(cn)
+	defer func() {
+		tx.close()
+		c.pool.Put(cn)
+	}()
+
+	if len(keys) > 0 {
+		if err := tx.Watch(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return fn(tx)
+}
+
+// Subscribe opens a connection of its own that listens to channels.
+// Messages are read from the returned PubSub, which must be closed.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) > 0 {
+		if err := ps.Subscribe(ctx, channels...); err != nil {
+			ps.Close()
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// PSubscribe opens a connection of its own that listens to channels
+// matching glob patterns, see Subscribe
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) > 0 {
+		if err := ps.PSubscribe(ctx, patterns...); err != nil {
+			ps.Close()
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// newPubSub dials a connection outside the pool: a subscribed connection
+// can't run other commands, and it is held for as long as the PubSub lives
+func (c *Client) newPubSub(ctx context.Context) (*PubSub, error) {
+	cn, err := dial(ctx, &c.opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPubSub(cn), nil
+}
@@ -0,0 +1,311 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+var (
+	// ErrNil is returned when the reply is nil: a missing key, field or
+	// member, or a blocking command that timed out
+	ErrNil = errors.New("client: nil")
+
+	// ErrTxFailed is returned by the commands of a transaction that EXEC
+	// didn't run because a watched key changed
+	ErrTxFailed = errors.New("client: transaction failed")
+
+	ErrClosed      = errors.New("client: client is closed")
+	ErrPoolTimeout = errors.New("client: connection pool timeout")
+)
+
+// Error is an error reply from the server, such as
+// "WRONGTYPE Operation against a key holding the wrong kind of value"
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Code returns the error code, the first word of the reply: ERR, WRONGTYPE,
+// READONLY, ...
+func (e Error) Code() string {
+	code, _, _ := strings.Cut(string(e), " ")
+	return code
+}
+
+// command is what a connection sends and fills in with the reply
+type command interface {
+	Args() []string
+	Err() error
+
+	// How long the server may hold the reply back on purpose, like BLPOP
+	// does. Negative means forever.
+	block() time.Duration
+
+	setReply(v resp.Value)
+	setErr(err error)
+}
+
+// Cmd is a command and, once it ran, its result. On a Client it runs right
+// away; in a Pipeline the result is there after Exec.
+type Cmd[T any] struct {
+	args   []string
+	parse  func(resp.Value) (T, error)
+	blockd time.Duration
+
+	val T
+	err error
+}
+
+func newCmd[T any](parse func(resp.Value) (T, error), args ...string) *Cmd[T] {
+	return &Cmd[T]{args: args, parse: parse}
+}
+
+// Args returns the command and its arguments as sent
+func (c *Cmd[T]) Args() []string {
+	return c.args
+}
+
+// Val returns the result, the zero value on error
+func (c *Cmd[T]) Val() T {
+	return c.val
+}
+
+// Err returns the error of the command: an Error reply, ErrNil, or what
+// went wrong talking to the server
+func (c *Cmd[T]) Err() error {
+	return c.err
+}
+
+func (c *Cmd[T]) Result() (T, error) {
+	return c.val, c.err
+}
+
+func (c *Cmd[T]) String() string {
+	if c.err != nil {
+		return fmt.Sprintf("%s: %v", strings.Join(c.args, " "), c.err)
+	}
+	return fmt.Sprintf("%s: %v", strings.Join(c.args, " "), c.val)
+}
+
+func (c *Cmd[T]) block() time.Duration {
+	return c.blockd
+}
+
+func (c *Cmd[T]) setReply(v resp.Value) {
+	if v.Type == resp.Error {
+		c.setErr(Error(v.Str))
+		return
+	}
+
+	val, err := c.parse(v)
+	if err != nil {
+		c.setErr(err)
+		return
+	}
+	c.val, c.err = val, nil
+}
+
+func (c *Cmd[T]) setErr(err error) {
+	var zero T
+	c.val, c.err = zero, err
+}
+
+// isNil reports whether a reply is a null bulk string or array
+func isNil(v resp.Value) bool {
+	return v.Type == resp.Null || v.Null && (v.Type == resp.BulkString || v.Type == resp.Array)
+}
+
+func unexpected(v resp.Value) error {
+	return fmt.Errorf("client: unexpected reply type %q", byte(v.Type))
+}
+
+// Reply parsers, one per result type
+
+func parseAny(v resp.Value) (any, error) {
+	switch {
+	case isNil(v):
+		return nil, ErrNil
+	case v.Type == resp.SimpleString || v.Type == resp.BulkString:
+		return v.Str, nil
+	case v.Type == resp.Integer:
+		return v.Int, nil
+	case v.Type == resp.Array:
+		out := make([]any, len(v.Array))
+		for i, el := range v.Array {
+			switch val, err := parseAny(el); {
+			case errors.Is(err, ErrNil):
+				out[i] = nil
+			case err != nil:
+				return nil, err
+			default:
+				out[i] = val
+			}
+		}
+		return out, nil
+	case v.Type == resp.Error:
+		return Error(v.Str), nil
+	}
+	return nil, unexpected(v)
+}
+
+func parseStatus(v resp.Value) (string, error) {
+	if v.Type != resp.SimpleString {
+		return "", unexpected(v)
+	}
+	return v.Str, nil
+}
+
+func parseString(v resp.Value) (string, error) {
+	switch {
+	case isNil(v):
+		return "", ErrNil
+	case v.Type == resp.BulkString || v.Type == resp.SimpleString:
+		return v.Str, nil
+	}
+	return "", unexpected(v)
+}
+
+func parseInt(v resp.Value) (int64, error) {
+	switch {
+	case isNil(v):
+		return 0, ErrNil
+	case v.Type == resp.Integer:
+		return v.Int, nil
+	}
+	return 0, unexpected(v)
+}
+
+func parseBool(v resp.Value) (bool, error) {
+	n, err := parseInt(v)
+	return n != 0, err
+}
+
+func parseFloat(v resp.Value) (float64, error) {
+	s, err := parseString(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseTime(v resp.Value) (time.Time, error) {
+	n, err := parseInt(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(n, 0), nil
+}
+
+// parseTTL keeps the negative replies as they are, see TTL
+func parseTTL(v resp.Value) (time.Duration, error) {
+	n, err := parseInt(v)
+	if err != nil || n < 0 {
+		return time.Duration(n), err
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func parseStrings(v resp.Value) ([]string, error) {
+	switch {
+	case isNil(v):
+		return nil, ErrNil
+	case v.Type != resp.Array:
+		return nil, unexpected(v)
+	}
+
+	out := make([]string, len(v.Array))
+	for i, el := range v.Array {
+		out[i] = el.Str
+	}
+	return out, nil
+}
+
+// parseStringMap parses a flat field, value, field, value ... array
+func parseStringMap(v resp.Value) (map[string]string, error) {
+	flat, err := parseStrings(v)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		out[flat[i]] = flat[i+1]
+	}
+	return out, nil
+}
+
+// parseIntMap parses a flat name, integer, name, integer ... array
+func parseIntMap(v resp.Value) (map[string]int64, error) {
+	if v.Type != resp.Array {
+		return nil, unexpected(v)
+	}
+
+	out := make(map[string]int64, len(v.Array)/2)
+	for i := 0; i+1 < len(v.Array); i += 2 {
+		out[v.Array[i].Str] = v.Array[i+1].Int
+	}
+	return out, nil
+}
+
+// parseZs parses a flat member, score, member, score ... array
+func parseZs(v resp.Value) ([]Z, error) {
+	flat, err := parseStrings(v)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Z, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		score, err := strconv.ParseFloat(flat[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Z{Member: flat[i], Score: score})
+	}
+	return out, nil
+}
+
+// parseNullable parses an array that has nil for missing entries
+func parseNullable(v resp.Value) ([]*string, error) {
+	if v.Type != resp.Array || v.Null {
+		return nil, unexpected(v)
+	}
+
+	out := make([]*string, len(v.Array))
+	for i, el := range v.Array {
+		if !isNil(el) {
+			out[i] = &el.Str
+		}
+	}
+	return out, nil
+}
+
+// parseClusterSlots parses CLUSTER SLOTS: start, end, then the serving
+// node as ip, port, id
+func parseClusterSlots(v resp.Value) ([]ClusterSlot, error) {
+	if v.Type != resp.Array {
+		return nil, unexpected(v)
+	}
+
+	out := make([]ClusterSlot, 0, len(v.Array))
+	for _, el := range v.Array {
+		if len(el.Array) < 3 || len(el.Array[2].Array) < 3 {
+			return nil, unexpected(el)
+		}
+		node := el.Array[2].Array
+		out = append(out, ClusterSlot{
+			Start: el.Array[0].Int,
+			End:   el.Array[1].Int,
+			Addr:  net.JoinHostPort(node[0].Str, strconv.FormatInt(node[1].Int, 10)),
+			ID:    node[2].Str,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,835 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// cmdable runs or queues a command. Client, Pipeline and Tx all embed one,
+// so they share the typed methods below.
+type cmdable func(ctx context.Context, cmd command)
+
+// TTL replies for a key without an expiry and for a missing key. They are
+// returned as they are, not scaled to seconds.
+const (
+	NoExpiry time.Duration = -1
+	NoKey    time.Duration = -2
+)
+
+// Z is a sorted set member with its score
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// ZAddArgs are the options of ZADD
+type ZAddArgs struct {
+	NX, XX bool // only add new members / only update existing ones
+	GT, LT bool // only update when the new score is greater / less
+	Ch     bool // return the number of changed members, not just added ones
+
+	Members []Z
+}
+
+// ZRangeBy is a score range, with "-inf", "+inf" and "(" for exclusive
+// bounds as in Redis, and an optional LIMIT when Count is not 0
+type ZRangeBy struct {
+	Min, Max      string
+	Offset, Count int64
+}
+
+// MigrateArgs are the target and options of MIGRATE. A Timeout of 0 uses
+// the server's default.
+type MigrateArgs struct {
+	Host, Port string
+	Timeout    time.Duration
+	Copy       bool // keep the keys here too
+	Replace    bool // overwrite keys that exist on the target
+
+	Keys []string
+}
+
+// ZStore describes the sources of ZUNIONSTORE and ZINTERSTORE. Aggregate
+// is SUM (the default), MIN or MAX.
+type ZStore struct {
+	Keys      []string
+	Weights   []float64
+	Aggregate string
+}
+
+// args builds an argument list from a command name, fixed arguments and
+// a variadic tail
+func args(name string, fixed []string, rest ...string) []string {
+	out := make([]string, 0, 1+len(fixed)+len(rest))
+	out = append(out, name)
+	out = append(out, fixed...)
+	return append(out, rest...)
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// blockTimeout formats a blocking timeout in seconds, 0 blocks forever
+func blockTimeout(d time.Duration) string {
+	return ftoa(d.Seconds())
+}
+
+// blocking marks a command whose reply may take up to d, forever for 0
+func blocking[T any](cmd *Cmd[T], d time.Duration) *Cmd[T] {
+	if d <= 0 {
+		cmd.blockd = -1
+	} else {
+		cmd.blockd = d
+	}
+	return cmd
+}
+
+// Strings and keys
+
+// Ping checks the connection, the reply is PONG
+func (c cmdable) Ping(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "PING")
+	c(ctx, cmd)
+	return cmd
+}
+
+// Set sets key to value. An expiration above 0 sets a TTL, in milliseconds
+// when it isn't whole seconds.
+func (c cmdable) Set(ctx context.Context, key, value string, expiration time.Duration) *Cmd[string] {
+	a := []string{"SET", key, value}
+	switch {
+	case expiration <= 0:
+	case expiration%time.Second == 0:
+		a = append(a, "EX", itoa(int64(expiration/time.Second)))
+	default:
+		a = append(a, "PX", itoa(max(int64(expiration/time.Millisecond), 1)))
+	}
+
+	cmd := newCmd(parseStatus, a...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Get returns the value of key, ErrNil when it doesn't exist
+func (c cmdable) Get(ctx context.Context, key string) *Cmd[string] {
+	cmd := newCmd(parseString, "GET", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Del deletes keys and returns how many existed
+func (c cmdable) Del(ctx context.Context, keys ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("DEL", nil, keys...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Exists returns how many of the keys exist
+func (c cmdable) Exists(ctx context.Context, keys ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("EXISTS", nil, keys...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Expire sets a TTL in whole seconds, false when key doesn't exist
+func (c cmdable) Expire(ctx context.Context, key string, expiration time.Duration) *Cmd[bool] {
+	cmd := newCmd(parseBool, "EXPIRE", key, itoa(int64(expiration/time.Second)))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ExpireAt expires key at tm, with second precision
+func (c cmdable) ExpireAt(ctx context.Context, key string, tm time.Time) *Cmd[bool] {
+	cmd := newCmd(parseBool, "EXPIREAT", key, itoa(tm.Unix()))
+	c(ctx, cmd)
+	return cmd
+}
+
+// PExpireAt expires key at tm, with millisecond precision
+func (c cmdable) PExpireAt(ctx context.Context, key string, tm time.Time) *Cmd[bool] {
+	cmd := newCmd(parseBool, "PEXPIREAT", key, itoa(tm.UnixMilli()))
+	c(ctx, cmd)
+	return cmd
+}
+
+// TTL returns the remaining time to live of key in whole seconds, NoExpiry
+// when it has none and NoKey when it doesn't exist
+func (c cmdable) TTL(ctx context.Context, key string) *Cmd[time.Duration] {
+	cmd := newCmd(parseTTL, "TTL", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Keys returns the keys matching a glob pattern
+func (c cmdable) Keys(ctx context.Context, pattern string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "KEYS", pattern)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Dump serializes the value of key for Restore, ErrNil when it doesn't
+// exist
+func (c cmdable) Dump(ctx context.Context, key string) *Cmd[string] {
+	cmd := newCmd(parseString, "DUMP", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Restore creates key from a Dump payload, with a TTL when ttl is above 0.
+// It fails when key exists.
+func (c cmdable) Restore(ctx context.Context, key string, ttl time.Duration, value string) *Cmd[string] {
+	cmd := newCmd(parseStatus, "RESTORE", key, itoa(int64(ttl/time.Millisecond)), value)
+	c(ctx, cmd)
+	return cmd
+}
+
+// RestoreReplace is Restore overwriting key when it exists
+func (c cmdable) RestoreReplace(ctx context.Context, key string, ttl time.Duration, value string) *Cmd[string] {
+	cmd := newCmd(parseStatus, "RESTORE", key, itoa(int64(ttl/time.Millisecond)), value, "REPLACE")
+	c(ctx, cmd)
+	return cmd
+}
+
+// Migrate moves keys to another server. The reply is OK, or NOKEY when
+// none of the keys exist.
+func (c cmdable) Migrate(ctx context.Context, m MigrateArgs) *Cmd[string] {
+	a := []string{"MIGRATE", m.Host, m.Port, "", itoa(int64(m.Timeout / time.Millisecond))}
+	if len(m.Keys) == 1 {
+		a[3] = m.Keys[0]
+	}
+	if m.Copy {
+		a = append(a, "COPY")
+	}
+	if m.Replace {
+		a = append(a, "REPLACE")
+	}
+	if len(m.Keys) > 1 {
+		a = append(a, "KEYS")
+		a = append(a, m.Keys...)
+	}
+
+	cmd := newCmd(parseStatus, a...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Incr(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "INCR", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) Decr(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "DECR", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) IncrBy(ctx context.Context, key string, increment int64) *Cmd[int64] {
+	cmd := newCmd(parseInt, "INCRBY", key, itoa(increment))
+	c(ctx, cmd)
+	return cmd
+}
+
+// Lists
+
+// LPush inserts values at the head of a list and returns its new length
+func (c cmdable) LPush(ctx context.Context, key string, values ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("LPUSH", []string{key}, values...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// RPush inserts values at the tail of a list and returns its new length
+func (c cmdable) RPush(ctx context.Context, key string, values ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("RPUSH", []string{key}, values...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// LPop removes and returns the head of a list, ErrNil when it is empty
+func (c cmdable) LPop(ctx context.Context, key string) *Cmd[string] {
+	cmd := newCmd(parseString, "LPOP", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// LPopCount removes and returns up to count elements from the head
+func (c cmdable) LPopCount(ctx context.Context, key string, count int) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "LPOP", key, strconv.Itoa(count))
+	c(ctx, cmd)
+	return cmd
+}
+
+// RPop removes and returns the tail of a list, ErrNil when it is empty
+func (c cmdable) RPop(ctx context.Context, key string) *Cmd[string] {
+	cmd := newCmd(parseString, "RPOP", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// RPopCount removes and returns up to count elements from the tail
+func (c cmdable) RPopCount(ctx context.Context, key string, count int) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "RPOP", key, strconv.Itoa(count))
+	c(ctx, cmd)
+	return cmd
+}
+
+// LRange returns the elements from start to stop, negative indexes count
+// from the tail
+func (c cmdable) LRange(ctx context.Context, key string, start, stop int64) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "LRANGE", key, itoa(start), itoa(stop))
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) LLen(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "LLEN", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// LIndex returns the element at index, ErrNil when out of range
+func (c cmdable) LIndex(ctx context.Context, key string, index int64) *Cmd[string] {
+	cmd := newCmd(parseString, "LINDEX", key, itoa(index))
+	c(ctx, cmd)
+	return cmd
+}
+
+// LMove pops an element from one end of source, pushes it to one end of
+// destination and returns it. The ends are LEFT or RIGHT.
+func (c cmdable) LMove(ctx context.Context, source, destination, srcpos, destpos string) *Cmd[string] {
+	cmd := newCmd(parseString, "LMOVE", source, destination, srcpos, destpos)
+	c(ctx, cmd)
+	return cmd
+}
+
+// RPopLPush is LMove from the tail of source to the head of destination
+func (c cmdable) RPopLPush(ctx context.Context, source, destination string) *Cmd[string] {
+	cmd := newCmd(parseString, "RPOPLPUSH", source, destination)
+	c(ctx, cmd)
+	return cmd
+}
+
+// BLPop pops the head of the first non-empty list, waiting up to timeout
+// for one, forever for 0. The result is the key and the element, ErrNil
+// after the timeout.
+func (c cmdable) BLPop(ctx context.Context, timeout time.Duration, keys ...string) *Cmd[[]string] {
+	cmd := blocking(newCmd(parseStrings, args("BLPOP", keys, blockTimeout(timeout))...), timeout)
+	c(ctx, cmd)
+	return cmd
+}
+
+// BRPop pops the tail of the first non-empty list, see BLPop
+func (c cmdable) BRPop(ctx context.Context, timeout time.Duration, keys ...string) *Cmd[[]string] {
+	cmd := blocking(newCmd(parseStrings, args("BRPOP", keys, blockTimeout(timeout))...), timeout)
+	c(ctx, cmd)
+	return cmd
+}
+
+// BLMove is LMove waiting up to timeout for source to have an element,
+// forever for 0. ErrNil after the timeout.
+func (c cmdable) BLMove(ctx context.Context, source, destination, srcpos, destpos string, timeout time.Duration) *Cmd[string] {
+	cmd := blocking(newCmd(parseString, "BLMOVE", source, destination, srcpos, destpos, blockTimeout(timeout)), timeout)
+	c(ctx, cmd)
+	return cmd
+}
+
+// BRPopLPush is RPopLPush waiting up to timeout, see BLMove
+func (c cmdable) BRPopLPush(ctx context.Context, source, destination string, timeout time.Duration) *Cmd[string] {
+	cmd := blocking(newCmd(parseString, "BRPOPLPUSH", source, destination, blockTimeout(timeout)), timeout)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Sets
+
+// SAdd adds members to a set and returns how many were new
+func (c cmdable) SAdd(ctx context.Context, key string, members ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("SADD", []string{key}, members...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// SRem removes members from a set and returns how many were there
+func (c cmdable) SRem(ctx context.Context, key string, members ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("SREM", []string{key}, members...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SMembers(ctx context.Context, key string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "SMEMBERS", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SIsMember(ctx context.Context, key, member string) *Cmd[bool] {
+	cmd := newCmd(parseBool, "SISMEMBER", key, member)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SCard(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "SCARD", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SUnion(ctx context.Context, keys ...string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, args("SUNION", nil, keys...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) SInter(ctx context.Context, keys ...string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, args("SINTER", nil, keys...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Hashes
+
+// HSet sets fields of a hash from field, value pairs and returns how many
+// fields were new
+func (c cmdable) HSet(ctx context.Context, key string, fieldValues ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("HSET", []string{key}, fieldValues...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// HSetNX sets a field only if it doesn't exist yet, true when it was set
+func (c cmdable) HSetNX(ctx context.Context, key, field, value string) *Cmd[bool] {
+	cmd := newCmd(parseBool, "HSETNX", key, field, value)
+	c(ctx, cmd)
+	return cmd
+}
+
+// HGet returns the value of a field, ErrNil when it doesn't exist
+func (c cmdable) HGet(ctx context.Context, key, field string) *Cmd[string] {
+	cmd := newCmd(parseString, "HGET", key, field)
+	c(ctx, cmd)
+	return cmd
+}
+
+// HMGet returns the values of fields, nil for the missing ones
+func (c cmdable) HMGet(ctx context.Context, key string, fields ...string) *Cmd[[]*string] {
+	cmd := newCmd(parseNullable, args("HMGET", []string{key}, fields...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HDel(ctx context.Context, key string, fields ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("HDEL", []string{key}, fields...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HExists(ctx context.Context, key, field string) *Cmd[bool] {
+	cmd := newCmd(parseBool, "HEXISTS", key, field)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HLen(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "HLEN", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HKeys(ctx context.Context, key string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "HKEYS", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HVals(ctx context.Context, key string) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "HVALS", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HGetAll(ctx context.Context, key string) *Cmd[map[string]string] {
+	cmd := newCmd(parseStringMap, "HGETALL", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HIncrBy(ctx context.Context, key, field string, increment int64) *Cmd[int64] {
+	cmd := newCmd(parseInt, "HINCRBY", key, field, itoa(increment))
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HIncrByFloat(ctx context.Context, key, field string, increment float64) *Cmd[float64] {
+	cmd := newCmd(parseFloat, "HINCRBYFLOAT", key, field, ftoa(increment))
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) HStrLen(ctx context.Context, key, field string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "HSTRLEN", key, field)
+	c(ctx, cmd)
+	return cmd
+}
+
+// Sorted sets
+
+// ZAdd adds members or updates their scores and returns how many were new
+func (c cmdable) ZAdd(ctx context.Context, key string, members ...Z) *Cmd[int64] {
+	return c.ZAddArgs(ctx, key, ZAddArgs{Members: members})
+}
+
+// ZAddArgs is ZAdd with options. For ZADD's INCR use ZIncrBy.
+func (c cmdable) ZAddArgs(ctx context.Context, key string, za ZAddArgs) *Cmd[int64] {
+	a := []string{"ZADD", key}
+	for _, opt := range []struct {
+		set  bool
+		name string
+	}{{za.NX, "NX"}, {za.XX, "XX"}, {za.GT, "GT"}, {za.LT, "LT"}, {za.Ch, "CH"}} {
+		if opt.set {
+			a = append(a, opt.name)
+		}
+	}
+	for _, m := range za.Members {
+		a = append(a, ftoa(m.Score), m.Member)
+	}
+
+	cmd := newCmd(parseInt, a...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZIncrBy adds increment to the score of member and returns the new score
+func (c cmdable) ZIncrBy(ctx context.Context, key string, increment float64, member string) *Cmd[float64] {
+	cmd := newCmd(parseFloat, "ZINCRBY", key, ftoa(increment), member)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRem(ctx context.Context, key string, members ...string) *Cmd[int64] {
+	cmd := newCmd(parseInt, args("ZREM", []string{key}, members...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZScore returns the score of member, ErrNil when it isn't in the set
+func (c cmdable) ZScore(ctx context.Context, key, member string) *Cmd[float64] {
+	cmd := newCmd(parseFloat, "ZSCORE", key, member)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZCard(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "ZCARD", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRank returns the rank of member by ascending score, ErrNil when it
+// isn't in the set
+func (c cmdable) ZRank(ctx context.Context, key, member string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "ZRANK", key, member)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRevRank returns the rank of member by descending score
+func (c cmdable) ZRevRank(ctx context.Context, key, member string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "ZREVRANK", key, member)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRange returns the members from rank start to stop, by ascending score
+func (c cmdable) ZRange(ctx context.Context, key string, start, stop int64) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "ZRANGE", key, itoa(start), itoa(stop))
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, "ZRANGE", key, itoa(start), itoa(stop), "WITHSCORES")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRevRange returns the members from rank start to stop, by descending
+// score
+func (c cmdable) ZRevRange(ctx context.Context, key string, start, stop int64) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "ZREVRANGE", key, itoa(start), itoa(stop))
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, "ZREVRANGE", key, itoa(start), itoa(stop), "WITHSCORES")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRangeByScore returns the members with scores in a range, ascending
+func (c cmdable) ZRangeByScore(ctx context.Context, key string, by ZRangeBy) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, by.args("ZRANGEBYSCORE", key, by.Min, by.Max, false)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRangeByScoreWithScores(ctx context.Context, key string, by ZRangeBy) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, by.args("ZRANGEBYSCORE", key, by.Min, by.Max, true)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZRevRangeByScore returns the members with scores in a range, descending
+func (c cmdable) ZRevRangeByScore(ctx context.Context, key string, by ZRangeBy) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, by.args("ZREVRANGEBYSCORE", key, by.Max, by.Min, false)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (c cmdable) ZRevRangeByScoreWithScores(ctx context.Context, key string, by ZRangeBy) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, by.args("ZREVRANGEBYSCORE", key, by.Max, by.Min, true)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (by ZRangeBy) args(name, key, from, to string, withScores bool) []string {
+	a := []string{name, key, from, to}
+	if withScores {
+		a = append(a, "WITHSCORES")
+	}
+	if by.Count != 0 {
+		a = append(a, "LIMIT", itoa(by.Offset), itoa(by.Count))
+	}
+	return a
+}
+
+// ZCount counts the members with scores between min and max
+func (c cmdable) ZCount(ctx context.Context, key, min, max string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "ZCOUNT", key, min, max)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores
+func (c cmdable) ZPopMin(ctx context.Context, key string, count int64) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, "ZPOPMIN", key, itoa(count))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZPopMax removes and returns up to count members with the highest scores
+func (c cmdable) ZPopMax(ctx context.Context, key string, count int64) *Cmd[[]Z] {
+	cmd := newCmd(parseZs, "ZPOPMAX", key, itoa(count))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZUnionStore stores the union of sorted sets in destination and returns
+// its size
+func (c cmdable) ZUnionStore(ctx context.Context, destination string, store ZStore) *Cmd[int64] {
+	cmd := newCmd(parseInt, store.args("ZUNIONSTORE", destination)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ZInterStore stores the intersection of sorted sets in destination and
+// returns its size
+func (c cmdable) ZInterStore(ctx context.Context, destination string, store ZStore) *Cmd[int64] {
+	cmd := newCmd(parseInt, store.args("ZINTERSTORE", destination)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+func (s ZStore) args(name, destination string) []string {
+	a := []string{name, destination, strconv.Itoa(len(s.Keys))}
+	a = append(a, s.Keys...)
+	if len(s.Weights) > 0 {
+		a = append(a, "WEIGHTS")
+		for _, w := range s.Weights {
+			a = append(a, ftoa(w))
+		}
+	}
+	if s.Aggregate != "" {
+		a = append(a, "AGGREGATE", s.Aggregate)
+	}
+	return a
+}
+
+// Pub/sub, subscribing is done with Client.Subscribe
+
+// Publish posts a message to a channel and returns how many subscribers
+// got it
+func (c cmdable) Publish(ctx context.Context, channel, message string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "PUBLISH", channel, message)
+	c(ctx, cmd)
+	return cmd
+}
+
+// PubSubChannels lists the channels with subscribers, matching pattern
+// unless it is empty
+func (c cmdable) PubSubChannels(ctx context.Context, pattern string) *Cmd[[]string] {
+	a := []string{"PUBSUB", "CHANNELS"}
+	if pattern != "" {
+		a = append(a, pattern)
+	}
+	cmd := newCmd(parseStrings, a...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// PubSubNumSub returns the number of subscribers of each channel
+func (c cmdable) PubSubNumSub(ctx context.Context, channels ...string) *Cmd[map[string]int64] {
+	cmd := newCmd(parseIntMap, args("PUBSUB", []string{"NUMSUB"}, channels...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// PubSubNumPat returns the number of pattern subscriptions
+func (c cmdable) PubSubNumPat(ctx context.Context) *Cmd[int64] {
+	cmd := newCmd(parseInt, "PUBSUB", "NUMPAT")
+	c(ctx, cmd)
+	return cmd
+}
+
+// Server
+
+// BgRewriteAOF starts an AOF rewrite in the background
+func (c cmdable) BgRewriteAOF(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "BGREWRITEAOF")
+	c(ctx, cmd)
+	return cmd
+}
+
+// Save writes a snapshot, blocking the server until it is done
+func (c cmdable) Save(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "SAVE")
+	c(ctx, cmd)
+	return cmd
+}
+
+// BgSave starts writing a snapshot in the background
+func (c cmdable) BgSave(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "BGSAVE")
+	c(ctx, cmd)
+	return cmd
+}
+
+// LastSave returns when the last snapshot was written
+func (c cmdable) LastSave(ctx context.Context) *Cmd[time.Time] {
+	cmd := newCmd(parseTime, "LASTSAVE")
+	c(ctx, cmd)
+	return cmd
+}
+
+// Info returns the server information, of one section or the default ones
+func (c cmdable) Info(ctx context.Context, section ...string) *Cmd[string] {
+	cmd := newCmd(parseString, args("INFO", nil, section...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ReplicaOf makes the server a replica of host:port
+func (c cmdable) ReplicaOf(ctx context.Context, host, port string) *Cmd[string] {
+	cmd := newCmd(parseStatus, "REPLICAOF", host, port)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ReplicaOfNoOne turns a replica back into a master
+func (c cmdable) ReplicaOfNoOne(ctx context.Context) *Cmd[string] {
+	return c.ReplicaOf(ctx, "NO", "ONE")
+}
+
+// ClusterSlot is a range of hash slots and the node serving it
+type ClusterSlot struct {
+	Start, End int64
+	Addr       string // host:port
+	ID         string
+}
+
+// ClusterKeySlot returns the hash slot of a key
+func (c cmdable) ClusterKeySlot(ctx context.Context, key string) *Cmd[int64] {
+	cmd := newCmd(parseInt, "CLUSTER", "KEYSLOT", key)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterSlots returns the slot ranges and the nodes serving them
+func (c cmdable) ClusterSlots(ctx context.Context) *Cmd[[]ClusterSlot] {
+	cmd := newCmd(parseClusterSlots, "CLUSTER", "SLOTS")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterNodes returns the cluster as the node sees it, one line per node
+func (c cmdable) ClusterNodes(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseString, "CLUSTER", "NODES")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterInfo returns the cluster state as field:value lines
+func (c cmdable) ClusterInfo(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseString, "CLUSTER", "INFO")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterMyID returns the node's ID
+func (c cmdable) ClusterMyID(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseString, "CLUSTER", "MYID")
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterMeet connects the node to the node at host:port
+func (c cmdable) ClusterMeet(ctx context.Context, host string, port int64) *Cmd[string] {
+	cmd := newCmd(parseStatus, "CLUSTER", "MEET", host, itoa(port))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterAddSlotsRange assigns the slots from start to end to the node
+func (c cmdable) ClusterAddSlotsRange(ctx context.Context, start, end int64) *Cmd[string] {
+	cmd := newCmd(parseStatus, "CLUSTER", "ADDSLOTSRANGE", itoa(start), itoa(end))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterSetSlot changes the state of a slot: action is MIGRATING,
+// IMPORTING or NODE followed by a node ID, or STABLE
+func (c cmdable) ClusterSetSlot(ctx context.Context, slot int64, action string, nodeID ...string) *Cmd[string] {
+	cmd := newCmd(parseStatus, args("CLUSTER", []string{"SETSLOT", itoa(slot), action}, nodeID...)...)
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterCountKeysInSlot returns how many keys of a slot the node holds
+func (c cmdable) ClusterCountKeysInSlot(ctx context.Context, slot int64) *Cmd[int64] {
+	cmd := newCmd(parseInt, "CLUSTER", "COUNTKEYSINSLOT", itoa(slot))
+	c(ctx, cmd)
+	return cmd
+}
+
+// ClusterGetKeysInSlot returns up to count keys of a slot held by the node
+func (c cmdable) ClusterGetKeysInSlot(ctx context.Context, slot, count int64) *Cmd[[]string] {
+	cmd := newCmd(parseStrings, "CLUSTER", "GETKEYSINSLOT", itoa(slot), itoa(count))
+	c(ctx, cmd)
+	return cmd
+}
+
+// Asking lets the next command use a slot the node is importing, after an
+// ASK redirect
+func (c cmdable) Asking(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "ASKING")
+	c(ctx, cmd)
+	return cmd
+}
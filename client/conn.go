@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// conn is a connection to the server. It is used by one goroutine at a
+// time, the pool hands it out.
+type conn struct {
+	nc     net.Conn
+	bw     *bufio.Writer
+	writer *resp.Writer
+	reader *resp.Reader
+	opts   *Options
+
+	usedAt time.Time
+
+	// Set once the connection can't be trusted anymore: an I/O error, a
+	// timeout or a cancellation may have left a reply half read
+	broken bool
+}
+
+func dial(ctx context.Context, opts *Options) (*conn, error) {
+	d := net.Dialer{Timeout: opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commands are collected in bw and sent with one write per round trip,
+	// which is what makes a pipeline cheap
+	bw := bufio.NewWriter(nc)
+	return &conn{
+		nc:     nc,
+		bw:     bw,
+		writer: resp.NewWriter(bw),
+		reader: resp.NewReader(nc),
+		opts:   opts,
+		usedAt: time.Now(),
+	}, nil
+}
+
+func (cn *conn) Close() error {
+	return cn.nc.Close()
+}
+
+// roundTrip sends commands in one write and reads their replies in order.
+// A server error only fails its own command; an I/O error fails every
+// command that didn't get its reply and is returned.
+func (cn *conn) roundTrip(ctx context.Context, cmds []command) error {
+	done := 0
+	err := cn.withContext(ctx, cmds, func() error {
+		if err := cn.write(cmds); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			v, err := cn.reader.ReadValue()
+			if err != nil {
+				return err
+			}
+			cmd.setReply(v)
+			done++
+		}
+		return nil
+	})
+	if err != nil {
+		setErr(cmds[done:], err)
+	}
+	return err
+}
+
+// txRoundTrip runs commands as a MULTI/EXEC transaction in one round
+// trip. A command the server refuses to queue fails with its error and
+// aborts the transaction, the others fail with the EXECABORT error. When
+// a watched key changed they all fail with ErrTxFailed.
+func (cn *conn) txRoundTrip(ctx context.Context, cmds []command) error {
+	multi := newCmd(parseStatus, "MULTI")
+	exec := newCmd(parseAny, "EXEC")
+	all := append(append([]command{multi}, cmds...), exec)
+
+	done := false
+	err := cn.withContext(ctx, cmds, func() error {
+		if err := cn.write(all); err != nil {
+			return err
+		}
+
+		// +OK for MULTI, then +QUEUED or an error for each command
+		for _, cmd := range all[:len(all)-1] {
+			v, err := cn.reader.ReadValue()
+			if err != nil {
+				return err
+			}
+			if v.Type == resp.Error {
+				cmd.setReply(v)
+			}
+		}
+
+		v, err := cn.reader.ReadValue()
+		if err != nil {
+			return err
+		}
+		done = true
+
+		switch {
+		case v.Type == resp.Error:
+			for _, cmd := range cmds {
+				if cmd.Err() == nil {
+					cmd.setErr(Error(v.Str))
+				}
+			}
+		case isNil(v):
+			setErr(cmds, ErrTxFailed)
+		case v.Type == resp.Array && len(v.Array) == len(cmds):
+			for i, cmd := range cmds {
+				cmd.setReply(v.Array[i])
+			}
+		default:
+			setErr(cmds, unexpected(v))
+		}
+		return nil
+	})
+	if err != nil && !done {
+		setErr(cmds, err)
+	}
+	return err
+}
+
+// write queues commands and sends them
+func (cn *conn) write(cmds []command) error {
+	for _, cmd := range cmds {
+		if err := cn.writer.WriteValue(encode(cmd.Args())); err != nil {
+			return err
+		}
+	}
+	return cn.bw.Flush()
+}
+
+// withContext runs fn, which talks to the server, under the deadlines of
+// the options and ctx. Cancelling ctx interrupts it. Any error leaves the
+// connection broken; ctx's error is returned when it was the cause.
+func (cn *conn) withContext(ctx context.Context, cmds []command, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cn.nc.SetWriteDeadline(deadline(ctx, now, cn.opts.WriteTimeout))
+	cn.nc.SetReadDeadline(deadline(ctx, now, cn.readTimeout(cmds)))
+
+	stop := context.AfterFunc(ctx, func() {
+		// Wakes up a blocked read or write, fn returns an error
+		cn.nc.SetDeadline(time.Unix(1, 0))
+	})
+	err := fn()
+	if !stop() {
+		// The deadline was moved or is about to be, too late to tell
+		cn.broken = true
+	}
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	cn.usedAt = time.Now()
+	if err != nil {
+		cn.broken = true
+	}
+	return err
+}
+
+// readTimeout returns the read timeout for a round trip, longer when a
+// command may block on purpose and 0 for none
+func (cn *conn) readTimeout(cmds []command) time.Duration {
+	if cn.opts.ReadTimeout == 0 {
+		return 0
+	}
+
+	var block time.Duration
+	for _, cmd := range cmds {
+		b := cmd.block()
+		if b < 0 {
+			return 0
+		}
+		block = max(block, b)
+	}
+	return cn.opts.ReadTimeout + block
+}
+
+// deadline returns when an operation of timeout must be done by, the zero
+// time for no deadline
+func deadline(ctx context.Context, now time.Time, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout > 0 {
+		t = now.Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// encode turns arguments into a command, an array of bulk strings
+func encode(args []string) resp.Value {
+	vals := make([]resp.Value, len(args))
+	for i, arg := range args {
+		vals[i] = resp.BulkValue(arg)
+	}
+	return resp.ArrayValue(vals)
+}
+
+func setErr(cmds []command, err error) {
+	for _, cmd := range cmds {
+		cmd.setErr(err)
+	}
+}
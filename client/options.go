@@ -0,0 +1,67 @@
+package client
+
+import (
+	"runtime"
+	"time"
+)
+
+// Options configures a Client. Zero values get the defaults listed on each
+// field; a negative timeout disables it.
+type Options struct {
+	// Server address as host:port, "localhost:6379" by default
+	Addr string
+
+	// Most connections open at once, idle or in use. 10 per CPU by default.
+	PoolSize int
+
+	// How long a command waits for a connection when all PoolSize are in
+	// use, ReadTimeout + 1 second by default
+	PoolTimeout time.Duration
+
+	// Timeout for establishing a connection, 5 seconds by default
+	DialTimeout time.Duration
+
+	// Timeouts for reading a reply and writing a command, 3 seconds by
+	// default. A context deadline that comes earlier wins. Blocking
+	// commands like BLPOP get their own timeout on top of ReadTimeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Idle connections older than this are closed instead of reused,
+	// 5 minutes by default
+	IdleTimeout time.Duration
+
+	// Idle connections older than this are checked with a PING before
+	// they are reused, so a connection the server or a middlebox dropped
+	// never fails a command. 30 seconds by default.
+	HealthCheckInterval time.Duration
+}
+
+// withDefaults returns the options with defaults filled in and negative
+// timeouts turned into 0, meaning none
+func (o Options) withDefaults() Options {
+	if o.Addr == "" {
+		o.Addr = "localhost:6379"
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = 10 * runtime.GOMAXPROCS(0)
+	}
+
+	o.DialTimeout = timeout(o.DialTimeout, 5*time.Second)
+	o.ReadTimeout = timeout(o.ReadTimeout, 3*time.Second)
+	o.WriteTimeout = timeout(o.WriteTimeout, o.ReadTimeout)
+	o.PoolTimeout = timeout(o.PoolTimeout, o.ReadTimeout+time.Second)
+	o.IdleTimeout = timeout(o.IdleTimeout, 5*time.Minute)
+	o.HealthCheckInterval = timeout(o.HealthCheckInterval, 30*time.Second)
+	return o
+}
+
+func timeout(d, def time.Duration) time.Duration {
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return def
+	}
+	return d
+}
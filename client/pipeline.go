@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// Pipeline queues commands and sends them together: one write for all of
+// them, then their replies are read in order. This saves a round trip per
+// command. The *Cmd a method returns has its result after Exec.
+//
+// A Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	cmdable
+
+	exec func(context.Context, []command) error
+	cmds []command
+}
+
+func newPipeline(exec func(context.Context, []command) error) *Pipeline {
+	p := &Pipeline{exec: exec}
+	p.cmdable = p.queue
+	return p
+}
+
+func (p *Pipeline) queue(_ context.Context, cmd command) {
+	p.cmds = append(p.cmds, cmd)
+}
+
+// Do queues any command, see Client.Do
+func (p *Pipeline) Do(ctx context.Context, args ...string) *Cmd[any] {
+	cmd := newCmd(parseAny, args...)
+	p.queue(ctx, cmd)
+	return cmd
+}
+
+// Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Discard drops the queued commands
+func (p *Pipeline) Discard() {
+	p.cmds = nil
+}
+
+// Exec sends the queued commands and reads their replies, leaving the
+// pipeline empty for reuse. It returns the first error of a command other
+// than ErrNil, a missing key being a result rather than a failure.
+func (p *Pipeline) Exec(ctx context.Context) error {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	if err := p.exec(ctx, cmds); err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, ErrNil) {
+			return err
+		}
+	}
+	return nil
+}
+
+// run queues the commands fn issues and executes them, unless fn fails
+func (p *Pipeline) run(ctx context.Context, fn func(*Pipeline) error) error {
+	if err := fn(p); err != nil {
+		return err
+	}
+	return p.Exec(ctx)
+}
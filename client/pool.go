@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats counts what the connection pool did, see Client.PoolStats
+type PoolStats struct {
+	Hits       uint64 // an idle connection was reused
+	Misses     uint64 // a new connection was dialed
+	Timeouts   uint64 // waited PoolTimeout without getting a connection
+	StaleConns uint64 // idle connections dropped as too old or failing the health check
+
+	TotalConns int // open connections, idle or in use
+	IdleConns  int
+}
+
+// pool hands out connections, at most opts.PoolSize at a time. Returned
+// connections are kept for reuse and checked before they're handed out
+// again.
+type pool struct {
+	opts *Options
+
+	// One token per connection in use, bounding them to PoolSize. Idle
+	// connections were in use before, so they never add to the total.
+	sem chan struct{}
+
+	mu     sync.Mutex
+	idle   []*conn // most recently used last
+	total  int
+	closed bool
+
+	hits, misses, timeouts, stale atomic.Uint64
+}
+
+func newPool(opts *Options) *pool {
+	return &pool{
+		opts: opts,
+		sem:  make(chan struct{}, opts.PoolSize),
+	}
+}
+
+// Get returns a connection, waiting up to PoolTimeout when all are in use.
+// It must be handed back with Put.
+func (p *pool) Get(ctx context.Context) (*conn, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		cn, err := p.popIdle()
+		if err != nil {
+			p.release()
+			return nil, err
+		}
+		if cn == nil {
+			break
+		}
+		if p.healthy(ctx, cn) {
+			p.hits.Add(1)
+			return cn, nil
+		}
+		p.stale.Add(1)
+		p.remove(cn)
+	}
+
+	p.misses.Add(1)
+	cn, err := dial(ctx, p.opts)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.total++
+	p.mu.Unlock()
+	return cn, nil
+}
+
+// Put hands a connection back. Broken ones are closed.
+func (p *pool) Put(cn *conn) {
+	defer p.release()
+
+	p.mu.Lock()
+	if cn.broken || p.closed {
+		p.mu.Unlock()
+		p.remove(cn)
+		return
+	}
+	p.idle = append(p.idle, cn)
+	p.mu.Unlock()
+}
+
+// Close closes the idle connections. Those in use are closed when they
+// come back.
+func (p *pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, cn := range idle {
+		p.remove(cn)
+	}
+	return nil
+}
+
+func (p *pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		Hits:       p.hits.Load(),
+		Misses:     p.misses.Load(),
+		Timeouts:   p.timeouts.Load(),
+		StaleConns: p.stale.Load(),
+		TotalConns: p.total,
+		IdleConns:  len(p.idle),
+	}
+}
+
+// acquire takes a token, waiting for one up to PoolTimeout
+func (p *pool) acquire(ctx context.Context) error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var expired <-chan time.Time
+	if p.opts.PoolTimeout > 0 {
+		timer := time.NewTimer(p.opts.PoolTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-expired:
+		p.timeouts.Add(1)
+		return ErrPoolTimeout
+	}
+}
+
+func (p *pool) release() {
+	<-p.sem
+}
+
+// popIdle returns the most recently used idle connection, nil when there
+// is none
+func (p *pool) popIdle() (*conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+	cn := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return cn, nil
+}
+
+// healthy reports whether an idle connection can be reused: not idle for
+// longer than IdleTimeout, and answering a PING when it was idle for a
+// while
+func (p *pool) healthy(ctx context.Context, cn *conn) bool {
+	idle := time.Since(cn.usedAt)
+	if p.opts.IdleTimeout > 0 && idle > p.opts.IdleTimeout {
+		return false
+	}
+	if p.opts.HealthCheckInterval == 0 || idle < p.opts.HealthCheckInterval {
+		return true
+	}
+
+	ping := newCmd(parseStatus, "PING")
+	return cn.roundTrip(ctx, []command{ping}) == nil && ping.Val() == "PONG"
+}
+
+// remove closes a connection that won't be reused
+func (p *pool) remove(cn *conn) {
+	cn.Close()
+
+	p.mu.Lock()
+	p.total--
+	p.mu.Unlock()
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// Message is a message published to a channel the PubSub listens to
+type Message struct {
+	Channel string
+	Pattern string // the matching pattern for PSubscribe, empty otherwise
+	Payload string
+}
+
+// Subscription confirms a change of subscriptions: Kind is subscribe,
+// unsubscribe, psubscribe or punsubscribe, Count the number of channels
+// and patterns listened to afterwards
+type Subscription struct {
+	Kind    string
+	Channel string
+	Count   int
+}
+
+// PubSub is a connection in subscriber mode, see Client.Subscribe.
+// Subscribing and receiving may happen from different goroutines.
+type PubSub struct {
+	cn *conn
+
+	wmu sync.Mutex // held while sending
+	rmu sync.Mutex // held while receiving
+
+	chOnce    sync.Once
+	ch        chan *Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPubSub(cn *conn) *PubSub {
+	return &PubSub{cn: cn, done: make(chan struct{})}
+}
+
+// Subscribe listens to more channels. The confirmations arrive through
+// Receive.
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	return ps.send(ctx, "SUBSCRIBE", channels)
+}
+
+// PSubscribe listens to channels matching more glob patterns
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	return ps.send(ctx, "PSUBSCRIBE", patterns)
+}
+
+// Unsubscribe stops listening to channels, to all of them without any
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	return ps.send(ctx, "UNSUBSCRIBE", channels)
+}
+
+// PUnsubscribe stops listening to patterns, to all of them without any
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return ps.send(ctx, "PUNSUBSCRIBE", patterns)
+}
+
+func (ps *PubSub) send(ctx context.Context, name string, args []string) error {
+	ps.wmu.Lock()
+	defer ps.wmu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ps.cn.nc.SetWriteDeadline(deadline(ctx, time.Now(), ps.cn.opts.WriteTimeout))
+	return ps.cn.write([]command{newCmd(parseAny, append([]string{name}, args...)...)})
+}
+
+// Receive waits for the next message or subscription change and returns it
+// as a *Message or *Subscription. It waits until ctx is done, without a
+// read timeout. An error other than ctx's leaves the PubSub unusable.
+func (ps *PubSub) Receive(ctx context.Context) (any, error) {
+	ps.rmu.Lock()
+	defer ps.rmu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Only the read deadline is ours, a send may be running
+	ps.cn.nc.SetReadDeadline(deadline(ctx, time.Now(), 0))
+	fired := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		ps.cn.nc.SetReadDeadline(time.Unix(1, 0))
+		close(fired)
+	})
+
+	start := ps.cn.reader.Offset()
+	v, err := ps.cn.reader.ReadValue()
+	if !stop() {
+		<-fired
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			return nil, err
+		}
+		// A read interrupted before it got anything leaves the stream
+		// intact, so receiving can go on
+		if ps.cn.reader.Offset() != start {
+			ps.Close()
+		}
+		return nil, ctx.Err()
+	}
+	return parsePush(v)
+}
+
+// ReceiveMessage waits for the next message, skipping subscription
+// changes, see Receive
+func (ps *PubSub) ReceiveMessage(ctx context.Context) (*Message, error) {
+	for {
+		v, err := ps.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg, ok := v.(*Message); ok {
+			return msg, nil
+		}
+	}
+}
+
+// Channel returns a channel that delivers the messages until the PubSub is
+// closed or its connection fails. Use either Channel or the Receive
+// methods, not both.
+func (ps *PubSub) Channel() <-chan *Message {
+	ps.chOnce.Do(func() {
+		ps.ch = make(chan *Message, 100)
+		go func() {
+			defer close(ps.ch)
+			for {
+				msg, err := ps.ReceiveMessage(context.Background())
+				if err != nil {
+					return
+				}
+				select {
+				case ps.ch <- msg:
+				case <-ps.done:
+					return
+				}
+			}
+		}()
+	})
+	return ps.ch
+}
+
+// Close closes the connection, which ends all subscriptions
+func (ps *PubSub) Close() error {
+	err := ErrClosed
+	ps.closeOnce.Do(func() {
+		close(ps.done)
+		err = ps.cn.Close()
+	})
+	return err
+}
+
+// parsePush parses what the server pushes to a subscriber
+func parsePush(v resp.Value) (any, error) {
+	if v.Type == resp.Error {
+		return nil, Error(v.Str)
+	}
+	if v.Type != resp.Array || len(v.Array) < 3 {
+		return nil, unexpected(v)
+	}
+
+	switch kind := strings.ToLower(v.Array[0].Str); kind {
+	case "message":
+		return &Message{Channel: v.Array[1].Str, Payload: v.Array[2].Str}, nil
+	case "pmessage":
+		if len(v.Array) != 4 {
+			return nil, unexpected(v)
+		}
+		return &Message{Pattern: v.Array[1].Str, Channel: v.Array[2].Str, Payload: v.Array[3].Str}, nil
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe":
+		return &Subscription{Kind: kind, Channel: v.Array[1].Str, Count: int(v.Array[2].Int)}, nil
+	default:
+		return nil, fmt.Errorf("client: unexpected %q push", kind)
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Tx is the connection Client.Watch runs its function on. Commands run on
+// it directly, so reads see the watched keys; the writes then go in a
+// transaction with TxPipelined. A Tx is only valid inside that function.
+type Tx struct {
+	cmdable
+
+	cn *conn
+}
+
+func newTx(cn *conn) *Tx {
+	tx := &Tx{cn: cn}
+	tx.cmdable = tx.process
+	return tx
+}
+
+func (tx *Tx) process(ctx context.Context, cmd command) {
+	tx.cn.roundTrip(ctx, []command{cmd})
+}
+
+// Do sends any command, see Client.Do
+func (tx *Tx) Do(ctx context.Context, args ...string) *Cmd[any] {
+	cmd := newCmd(parseAny, args...)
+	tx.process(ctx, cmd)
+	return cmd
+}
+
+// Watch watches more keys, the transaction fails if any of them changes
+func (tx *Tx) Watch(ctx context.Context, keys ...string) *Cmd[string] {
+	cmd := newCmd(parseStatus, append([]string{"WATCH"}, keys...)...)
+	tx.process(ctx, cmd)
+	return cmd
+}
+
+// Unwatch forgets all watched keys
+func (tx *Tx) Unwatch(ctx context.Context) *Cmd[string] {
+	cmd := newCmd(parseStatus, "UNWATCH")
+	tx.process(ctx, cmd)
+	return cmd
+}
+
+// Pipeline returns a pipeline on the Tx's connection
+func (tx *Tx) Pipeline() *Pipeline {
+	return newPipeline(tx.cn.roundTrip)
+}
+
+// TxPipeline returns a pipeline that runs as a MULTI/EXEC transaction on
+// the Tx's connection, failing with ErrTxFailed if a watched key changed
+func (tx *Tx) TxPipeline() *Pipeline {
+	return newPipeline(tx.cn.txRoundTrip)
+}
+
+// Pipelined queues the commands fn issues on a Pipeline and runs them
+func (tx *Tx) Pipelined(ctx context.Context, fn func(*Pipeline) error) error {
+	return tx.Pipeline().run(ctx, fn)
+}
+
+// TxPipelined queues the commands fn issues and runs them as the
+// transaction the watched keys guard
+func (tx *Tx) TxPipelined(ctx context.Context, fn func(*Pipeline) error) error {
+	return tx.TxPipeline().run(ctx, fn)
+}
+
+// close drops the watches before the connection goes back to the pool, in
+// case fn returned without running a transaction
+func (tx *Tx) close() {
+	if tx.cn.broken {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tx.Unwatch(ctx)
+}
@@ -0,0 +1,125 @@
+// Command aofcheck validates an append only file and can repair it by
+// cutting it after the last complete record, like redis-check-aof. It
+// takes either an AOF directory, checked file by file in manifest order,
+// or a single file.
+//
+//	aofcheck [-fix] <dir|file>
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/persistence"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "truncate the last file after its last complete record")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: aofcheck [-fix] <dir|file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Println("Failed to open:", err)
+		os.Exit(1)
+	}
+
+	// Nothing is applied, the records are only read and counted
+	keys, records := 0, 0
+	restore := func(string, store.ValueType, any, time.Time) { keys++ }
+	dispatch := func(resp.Value) { records++ }
+
+	// The file a repair would truncate, only the last one can be
+	var last string
+	if info.IsDir() {
+		name, m, openErr := openManifest(path)
+		if openErr != nil {
+			fmt.Println("Failed to open:", openErr)
+			os.Exit(1)
+		}
+		for _, f := range m.Files {
+			fmt.Printf("%s (seq %d, type %c)\n", f.Name, f.Seq, f.Type)
+		}
+		if l := m.Last(); l != nil {
+			last = filepath.Join(path, l.Name)
+		}
+		err = persistence.Replay(path, name, restore, dispatch)
+	} else {
+		last = path
+		err = persistence.ReplayFile(path, 0, restore, dispatch)
+	}
+
+	if err == nil {
+		fmt.Printf("AOF is valid: %d preamble keys, %d commands\n", keys, records)
+		return
+	}
+
+	var loadErr *persistence.LoadError
+	if !errors.As(err, &loadErr) {
+		// A missing file or a bad snapshot preamble can't be repaired by truncating
+		fmt.Println("AOF is not valid:", err)
+		os.Exit(1)
+	}
+
+	file := path
+	if loadErr.File != "" {
+		file = filepath.Join(path, loadErr.File)
+	}
+	if file != last {
+		fmt.Println("AOF is not valid:", err)
+		fmt.Println("Only the last incremental file can be repaired, restore this one from a backup")
+		os.Exit(1)
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		fmt.Println("Failed to open:", err)
+		os.Exit(1)
+	}
+	discard := fi.Size() - loadErr.Offset
+	fmt.Println("AOF is not valid:", loadErr)
+	fmt.Printf("%d commands before it are intact, repairing discards the last %d bytes\n", records, discard)
+	if loadErr.Truncated {
+		fmt.Println("The file ends in the middle of a record, most likely a torn write from a crash")
+	} else {
+		fmt.Println("Warning: the file is corrupted in the middle, repairing also drops every record after the bad data")
+	}
+
+	if !*fix {
+		fmt.Println("Run with -fix to repair it")
+		os.Exit(1)
+	}
+	if err := persistence.TruncateAt(file, loadErr.Offset); err != nil {
+		fmt.Println("Failed to repair:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("AOF repaired, %s truncated to %d bytes\n", file, loadErr.Offset)
+}
+
+// openManifest reads the single manifest in dir and returns the AOF name
+// it belongs to
+func openManifest(dir string) (string, *persistence.Manifest, error) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.manifest"))
+	if len(matches) != 1 {
+		return "", nil, fmt.Errorf("expected one manifest in %s, found %d", dir, len(matches))
+	}
+
+	name := strings.TrimSuffix(filepath.Base(matches[0]), ".manifest")
+	m, err := persistence.ReadManifest(dir, name)
+	return name, m, err
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +20,7 @@ var (
 	dataSize  = flag.Int("d", 3, "Data size in bytes for SET value")
 	testType  = flag.String("t", "all", "Test type: set, get, incr, lpush, sadd, all")
 	keepAlive = flag.Bool("k", true, "Use keep-alive connections")
+	pipeline  = flag.Int("P", 1, "Pipeline <numreq> requests, 1 sends one at a time")
 )
 
 type BenchResult struct {
@@ -31,12 +33,16 @@ type BenchResult struct {
 
 func main() {
 	flag.Parse()
+	if *pipeline < 1 {
+		fmt.Println("Pipeline must be at least 1")
+		return
+	}
 
 	fmt.Println("╔═══════════════════════════════════════════════════════════╗")
 	fmt.Println("║              go-redis Benchmark Tool                       ║")
 	fmt.Println("╚═══════════════════════════════════════════════════════════╝")
 	fmt.Printf("\nServer: %s:%d\n", *host, *port)
-	fmt.Printf("Clients: %d, Requests: %d, Data size: %d bytes\n\n", *clients, *requests, *dataSize)
+	fmt.Printf("Clients: %d, Requests: %d, Data size: %d bytes, Pipeline: %d\n\n", *clients, *requests, *dataSize, *pipeline)
 
 	// Generate value of specified size
 	value := make([]byte, *dataSize)
@@ -49,54 +55,54 @@ func main() {
 
 	switch *testType {
 	case "set":
-		results = append(results, runBenchmark("SET", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("SET", func(id int) []string {
 			key := fmt.Sprintf("key:%d", id)
-			sendCommand(w, r, "SET", key, valueStr)
+			return []string{"SET", key, valueStr}
 		}))
 	case "get":
 		// Pre-populate keys
 		setupBenchmark("GET", valueStr)
-		results = append(results, runBenchmark("GET", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("GET", func(id int) []string {
 			key := fmt.Sprintf("key:%d", id%1000)
-			sendCommand(w, r, "GET", key)
+			return []string{"GET", key}
 		}))
 	case "incr":
-		results = append(results, runBenchmark("INCR", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("INCR", func(id int) []string {
 			key := fmt.Sprintf("counter:%d", id%100)
-			sendCommand(w, r, "INCR", key)
+			return []string{"INCR", key}
 		}))
 	case "lpush":
-		results = append(results, runBenchmark("LPUSH", func(id int, w *resp.Writer, r *resp.Reader) {
-			sendCommand(w, r, "LPUSH", "mylist", valueStr)
+		results = append(results, runBenchmark("LPUSH", func(id int) []string {
+			return []string{"LPUSH", "mylist", valueStr}
 		}))
 	case "sadd":
-		results = append(results, runBenchmark("SADD", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("SADD", func(id int) []string {
 			member := fmt.Sprintf("member:%d", id)
-			sendCommand(w, r, "SADD", "myset", member)
+			return []string{"SADD", "myset", member}
 		}))
 	case "all":
-		results = append(results, runBenchmark("PING", func(id int, w *resp.Writer, r *resp.Reader) {
-			sendCommand(w, r, "PING")
+		results = append(results, runBenchmark("PING", func(id int) []string {
+			return []string{"PING"}
 		}))
-		results = append(results, runBenchmark("SET", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("SET", func(id int) []string {
 			key := fmt.Sprintf("key:%d", id)
-			sendCommand(w, r, "SET", key, valueStr)
+			return []string{"SET", key, valueStr}
 		}))
 		setupBenchmark("GET", valueStr)
-		results = append(results, runBenchmark("GET", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("GET", func(id int) []string {
 			key := fmt.Sprintf("key:%d", id%1000)
-			sendCommand(w, r, "GET", key)
+			return []string{"GET", key}
 		}))
-		results = append(results, runBenchmark("INCR", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("INCR", func(id int) []string {
 			key := fmt.Sprintf("counter:%d", id%100)
-			sendCommand(w, r, "INCR", key)
+			return []string{"INCR", key}
 		}))
-		results = append(results, runBenchmark("LPUSH", func(id int, w *resp.Writer, r *resp.Reader) {
-			sendCommand(w, r, "LPUSH", "benchlist", valueStr)
+		results = append(results, runBenchmark("LPUSH", func(id int) []string {
+			return []string{"LPUSH", "benchlist", valueStr}
 		}))
-		results = append(results, runBenchmark("SADD", func(id int, w *resp.Writer, r *resp.Reader) {
+		results = append(results, runBenchmark("SADD", func(id int) []string {
 			member := fmt.Sprintf("member:%d", id)
-			sendCommand(w, r, "SADD", "benchset", member)
+			return []string{"SADD", "benchset", member}
 		}))
 	default:
 		fmt.Println("Unknown test type:", *testType)
@@ -117,7 +123,7 @@ func main() {
 }
 
 func setupBenchmark(name, value string) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", *host, *port))
+	conn, err := net.Dial("tcp", net.JoinHostPort(*host, strconv.Itoa(*port)))
 	if err != nil {
 		fmt.Printf("Setup failed: %v\n", err)
 		return
@@ -134,7 +140,10 @@ func setupBenchmark(name, value string) {
 	}
 }
 
-func runBenchmark(name string, operation func(id int, w *resp.Writer, r *resp.Reader)) BenchResult {
+// runBenchmark runs operation, which builds the command for a request id,
+// from every client. Commands are sent in batches of -P, each of them
+// counting the latency of its whole batch.
+func runBenchmark(name string, operation func(id int) []string) BenchResult {
 	fmt.Printf("Running %s benchmark...\n", name)
 
 	var totalOps int64
@@ -150,7 +159,7 @@ func runBenchmark(name string, operation func(id int, w *resp.Writer, r *resp.Re
 		go func(clientID int) {
 			defer wg.Done()
 
-			conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", *host, *port))
+			conn, err := net.Dial("tcp", net.JoinHostPort(*host, strconv.Itoa(*port)))
 			if err != nil {
 				fmt.Printf("Client %d failed to connect: %v\n", clientID, err)
 				return
@@ -160,12 +169,18 @@ func runBenchmark(name string, operation func(id int, w *resp.Writer, r *resp.Re
 			w := resp.NewWriter(conn)
 			r := resp.NewReader(conn)
 
-			for i := 0; i < opsPerClient; i++ {
-				opID := clientID*opsPerClient + i
+			for i := 0; i < opsPerClient; i += *pipeline {
+				n := min(*pipeline, opsPerClient-i)
 				opStart := time.Now()
-				operation(opID, w, r)
-				atomic.AddInt64(&totalLatency, int64(time.Since(opStart)))
-				atomic.AddInt64(&totalOps, 1)
+				for j := 0; j < n; j++ {
+					w.Buffer(command(operation(clientID*opsPerClient + i + j)))
+				}
+				w.Flush()
+				for j := 0; j < n; j++ {
+					r.ReadValue()
+				}
+				atomic.AddInt64(&totalLatency, int64(time.Since(opStart))*int64(n))
+				atomic.AddInt64(&totalOps, int64(n))
 			}
 		}(c)
 	}
@@ -191,11 +206,15 @@ func runBenchmark(name string, operation func(id int, w *resp.Writer, r *resp.Re
 }
 
 func sendCommand(w *resp.Writer, r *resp.Reader, args ...string) resp.Value {
+	w.WriteValue(command(args))
+	response, _ := r.ReadValue()
+	return response
+}
+
+func command(args []string) resp.Value {
 	vals := make([]resp.Value, len(args))
 	for i, arg := range args {
 		vals[i] = resp.BulkValue(arg)
 	}
-	w.WriteValue(resp.ArrayValue(vals))
-	response, _ := r.ReadValue()
-	return response
+	return resp.ArrayValue(vals)
 }
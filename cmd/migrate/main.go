@@ -0,0 +1,92 @@
+// Command migrate moves the keys matching a pattern from one server to
+// another with MIGRATE, in batches, printing its progress as it goes.
+// Keys keep their TTLs and are deleted from the source once the target
+// stored them, unless -copy is given.
+//
+//	migrate [-pattern p] [-batch n] [-copy] [-replace] <source> <target>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Eahtasham/go-redis/client"
+)
+
+func main() {
+	pattern := flag.String("pattern", "*", "glob pattern of the keys to move")
+	batch := flag.Int("batch", 100, "keys sent per MIGRATE")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout of each MIGRATE")
+	copyKeys := flag.Bool("copy", false, "keep the keys on the source")
+	replace := flag.Bool("replace", false, "overwrite keys that exist on the target")
+	interval := flag.Duration("progress", time.Second, "how often to report progress")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: migrate [options] <source host:port> <target host:port>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *batch <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	host, port, err := net.SplitHostPort(flag.Arg(1))
+	if err != nil {
+		fmt.Println("Invalid target address:", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	src := client.New(client.Options{Addr: flag.Arg(0), ReadTimeout: *timeout + 5*time.Second})
+	defer src.Close()
+
+	keys, err := src.Keys(ctx, *pattern).Result()
+	if err != nil {
+		fmt.Println("Failed to list keys:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Moving %d keys matching %q from %s to %s\n", len(keys), *pattern, flag.Arg(0), flag.Arg(1))
+
+	start := time.Now()
+	last := start
+	moved, gone := 0, 0
+	for len(keys) > 0 {
+		n := min(*batch, len(keys))
+		reply, err := src.Migrate(ctx, client.MigrateArgs{
+			Host:    host,
+			Port:    port,
+			Timeout: *timeout,
+			Copy:    *copyKeys,
+			Replace: *replace,
+			Keys:    keys[:n],
+		}).Result()
+		if err != nil {
+			// Keys of the batch the target accepted are moved all the same
+			fmt.Printf("MIGRATE failed after %d keys: %v\n", moved, err)
+			os.Exit(1)
+		}
+
+		if reply == "NOKEY" {
+			// Deleted or expired since they were listed
+			gone += n
+		} else {
+			moved += n
+		}
+		keys = keys[n:]
+
+		if now := time.Now(); now.Sub(last) >= *interval || len(keys) == 0 {
+			last = now
+			progress(moved, gone, len(keys), now.Sub(start))
+		}
+	}
+
+	fmt.Printf("Done in %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+func progress(moved, gone, left int, elapsed time.Duration) {
+	rate := float64(moved) / max(elapsed.Seconds(), 0.001)
+	fmt.Printf("%d moved, %d gone, %d left (%.0f keys/s)\n", moved, gone, left, rate)
+}
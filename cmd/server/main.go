@@ -2,18 +2,78 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Eahtasham/go-redis/internal/persistence"
 	"github.com/Eahtasham/go-redis/internal/server"
 )
 
 func main() {
+	cfg := server.DefaultConfig()
+	flag.StringVar(&cfg.Addr, "addr", cfg.Addr, "address to listen on")
+	flag.StringVar(&cfg.AOFDir, "appenddirname", cfg.AOFDir, "directory holding the append only files and their manifest")
+	flag.StringVar(&cfg.AOFFilename, "appendfilename", cfg.AOFFilename, "base name of the append only files")
+	flag.StringVar(&cfg.DBFilename, "dbfilename", cfg.DBFilename, "snapshot file path")
+	flag.Func("save", `snapshot rules as "<seconds> <changes> ...", "" disables (default "3600 1 300 100 60 10000")`, func(s string) error {
+		rules, err := persistence.ParseSaveRules(s)
+		cfg.SaveRules = rules
+		return err
+	})
+	flag.Func("appendfsync", "when to fsync the AOF: always, everysec or no (default everysec)", func(s string) error {
+		policy, err := persistence.ParseFsyncPolicy(s)
+		cfg.AppendFsync = policy
+		return err
+	})
+	flag.Func("aof-backpressure", "what writes do when the AOF queue is full: block, grow or refuse (default block)", func(s string) error {
+		policy, err := persistence.ParseBackpressurePolicy(s)
+		cfg.AOFBackpressure = policy
+		return err
+	})
+	flag.BoolVar(&cfg.AOFUseRDBPreamble, "aof-use-rdb-preamble", cfg.AOFUseRDBPreamble, "start rewritten AOFs with a binary snapshot")
+	flag.BoolVar(&cfg.AOFLoadTruncated, "aof-load-truncated", cfg.AOFLoadTruncated, "load an AOF with a torn tail and truncate it, instead of refusing to start")
+	flag.IntVar(&cfg.AutoAOFRewritePercentage, "auto-aof-rewrite-percentage", cfg.AutoAOFRewritePercentage, "rewrite the AOF once it grew by this percentage (0 disables)")
+	flag.Int64Var(&cfg.AutoAOFRewriteMinSize, "auto-aof-rewrite-min-size", cfg.AutoAOFRewriteMinSize, "minimum AOF size in bytes for an automatic rewrite")
+	flag.Func("replicaof", `replicate the master at "<host> <port>"`, func(s string) error {
+		host, port, ok := strings.Cut(strings.TrimSpace(s), " ")
+		if !ok {
+			return errors.New(`expected "<host> <port>"`)
+		}
+		cfg.ReplicaOf = net.JoinHostPort(host, strings.TrimSpace(port))
+		return nil
+	})
+	flag.IntVar(&cfg.ReplBacklogSize, "repl-backlog-size", cfg.ReplBacklogSize, "bytes of replication stream kept for replicas that reconnect")
+	flag.BoolVar(&cfg.ClusterEnabled, "cluster-enabled", cfg.ClusterEnabled, "run as a cluster node")
+	flag.StringVar(&cfg.ClusterConfigFile, "cluster-config-file", cfg.ClusterConfigFile, "file the cluster node keeps its view of the cluster in")
+	flag.Func("cluster-node-timeout", "milliseconds a cluster node may not answer before it is considered failing (default 15000)", func(s string) error {
+		ms, err := strconv.Atoi(s)
+		if err != nil || ms <= 0 {
+			return errors.New("expected a positive number of milliseconds")
+		}
+		cfg.ClusterNodeTimeout = time.Duration(ms) * time.Millisecond
+		return nil
+	})
+	flag.Func("proto-max-bulk-len", "largest bulk string in bytes a client may send, at least 1mb (default 536870912)", func(s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1<<20 {
+			return errors.New("expected a number of bytes of at least 1048576")
+		}
+		cfg.ProtoMaxBulkLen = n
+		return nil
+	})
+	flag.Parse()
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	srv := server.New(":6379")
+	srv := server.New(cfg)
 
 	go func() {
 		if err := srv.Start(); err != nil {
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/client"
+)
+
+var failed bool
+
+func check(label string, ok bool, got any) {
+	status := "PASS"
+	if !ok {
+		status = "FAIL"
+		failed = true
+	}
+	fmt.Printf("[%s] %s -> %v\n", status, label, got)
+}
+
+func main() {
+	ctx := context.Background()
+	c := client.New(client.Options{Addr: "localhost:6379", PoolSize: 8})
+	defer c.Close()
+
+	if err := c.Ping(ctx).Err(); err != nil {
+		fmt.Println("Failed to connect:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("=== Client Library Test ===")
+	c.Del(ctx, "cl:str", "cl:empty", "cl:n", "cl:list", "cl:hash", "cl:zset", "cl:watched", "cl:q")
+
+	fmt.Println("\n--- TYPED COMMANDS ---")
+	set := c.Set(ctx, "cl:str", "hello", time.Minute)
+	check("SET with TTL", set.Val() == "OK", set)
+	get := c.Get(ctx, "cl:str")
+	check("GET", get.Val() == "hello", get)
+	missing := c.Get(ctx, "cl:missing")
+	check("GET missing is ErrNil", errors.Is(missing.Err(), client.ErrNil), missing.Err())
+	c.Set(ctx, "cl:empty", "", 0)
+	empty := c.Get(ctx, "cl:empty")
+	check("GET empty string is not nil", empty.Err() == nil && empty.Val() == "", empty.Err())
+	ttl := c.TTL(ctx, "cl:str")
+	check("TTL", ttl.Val() > 50*time.Second, ttl.Val())
+	check("TTL missing", c.TTL(ctx, "cl:missing").Val() == client.NoKey, c.TTL(ctx, "cl:missing").Val())
+	incr := c.IncrBy(ctx, "cl:n", 5)
+	check("INCRBY", incr.Val() == 5, incr.Val())
+	rpush := c.RPush(ctx, "cl:list", "a", "b", "c")
+	check("RPUSH", rpush.Val() == 3, rpush.Val())
+	lrange := c.LRange(ctx, "cl:list", 0, -1)
+	check("LRANGE", fmt.Sprint(lrange.Val()) == "[a b c]", lrange.Val())
+	c.HSet(ctx, "cl:hash", "name", "bob", "age", "30")
+	hmget := c.HMGet(ctx, "cl:hash", "name", "nope")
+	check("HMGET nil field", len(hmget.Val()) == 2 && *hmget.Val()[0] == "bob" && hmget.Val()[1] == nil, hmget.Err())
+	hall := c.HGetAll(ctx, "cl:hash")
+	check("HGETALL", hall.Val()["age"] == "30", hall.Val())
+	c.ZAdd(ctx, "cl:zset", client.Z{Member: "a", Score: 1}, client.Z{Member: "b", Score: 2.5})
+	zr := c.ZRangeWithScores(ctx, "cl:zset", 0, -1)
+	check("ZRANGE WITHSCORES", len(zr.Val()) == 2 && zr.Val()[1].Score == 2.5, zr.Val())
+	wrong := c.Incr(ctx, "cl:list")
+	var srvErr client.Error
+	check("WRONGTYPE error", errors.As(wrong.Err(), &srvErr) && srvErr.Code() == "WRONGTYPE", wrong.Err())
+
+	fmt.Println("\n--- PIPELINE ---")
+	pipe := c.Pipeline()
+	incrs := make([]*client.Cmd[int64], 1000)
+	for i := range incrs {
+		incrs[i] = pipe.Incr(ctx, "cl:n")
+	}
+	err := pipe.Exec(ctx)
+	check("1000 pipelined INCRs", err == nil && incrs[999].Val() == 1005, incrs[999].Val())
+
+	fmt.Println("\n--- TRANSACTIONS ---")
+	var a, b *client.Cmd[int64]
+	err = c.TxPipelined(ctx, func(p *client.Pipeline) error {
+		a = p.Incr(ctx, "cl:n")
+		b = p.IncrBy(ctx, "cl:n", 10)
+		return nil
+	})
+	check("MULTI/EXEC", err == nil && a.Val() == 1006 && b.Val() == 1016, []int64{a.Val(), b.Val()})
+
+	c.Set(ctx, "cl:watched", "1", 0)
+	err = c.Watch(ctx, func(tx *client.Tx) error {
+		// Another client changes the key between the read and the write
+		c.Set(ctx, "cl:watched", "2", 0)
+		return tx.TxPipelined(ctx, func(p *client.Pipeline) error {
+			p.Set(ctx, "cl:watched", "3", 0)
+			return nil
+		})
+	}, "cl:watched")
+	check("WATCH conflict is ErrTxFailed", errors.Is(err, client.ErrTxFailed), err)
+	check("watched key kept", c.Get(ctx, "cl:watched").Val() == "2", c.Get(ctx, "cl:watched").Val())
+
+	fmt.Println("\n--- BLOCKING AND TIMEOUTS ---")
+	start := time.Now()
+	blpop := c.BLPop(ctx, 500*time.Millisecond, "cl:q")
+	check("BLPOP timeout is ErrNil", errors.Is(blpop.Err(), client.ErrNil), time.Since(start).Round(100*time.Millisecond))
+
+	tctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	blpop = c.BLPop(tctx, 0, "cl:q")
+	cancel()
+	check("context deadline interrupts BLPOP", errors.Is(blpop.Err(), context.DeadlineExceeded), blpop.Err())
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		c.RPush(ctx, "cl:q", "job")
+	}()
+	blpop = c.BLPop(ctx, 5*time.Second, "cl:q")
+	check("BLPOP served", fmt.Sprint(blpop.Val()) == "[cl:q job]", blpop.Val())
+
+	fmt.Println("\n--- PUB/SUB ---")
+	ps, err := c.Subscribe(ctx, "cl:news")
+	if err == nil {
+		sub, _ := ps.Receive(ctx)
+		check("SUBSCRIBE confirmed", sub != nil, sub)
+		c.Publish(ctx, "cl:news", "hi")
+		msg := <-ps.Channel()
+		check("message received", msg != nil && msg.Payload == "hi", msg)
+		ps.Close()
+	} else {
+		check("SUBSCRIBE", false, err)
+	}
+
+	fmt.Println("\n--- POOL ---")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Incr(ctx, "cl:n")
+			}
+		}()
+	}
+	wg.Wait()
+	n := c.Get(ctx, "cl:n")
+	check("50 goroutines x 100 INCRs", n.Val() == "6016", n.Val())
+	stats := c.PoolStats()
+	check("pool stays within PoolSize", stats.TotalConns <= 8, fmt.Sprintf("%+v", stats))
+
+	fmt.Println("\n=== All Tests Complete ===")
+	if failed {
+		os.Exit(1)
+	}
+}
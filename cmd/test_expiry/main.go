@@ -28,7 +28,7 @@ func formatResponse(v resp.Value) string {
 	case resp.Integer:
 		return fmt.Sprintf(":%d", v.Int)
 	case resp.BulkString:
-		if v.Str == "" {
+		if v.Null {
 			return "(nil)"
 		}
 		return fmt.Sprintf("\"%s\"", v.Str)
@@ -70,7 +70,7 @@ func main() {
 	result := sendCommand(writer, reader, "GET", "expiring")
 	fmt.Printf("   GET expiring -> %s\n", formatResponse(result))
 
-	if result.Type == resp.BulkString && result.Str == "" {
+	if result.Type == resp.BulkString && result.Null {
 		fmt.Println()
 		fmt.Println("   PASS: Key was actively expired!")
 	} else {
@@ -99,7 +99,7 @@ func main() {
 	for i := 0; i < 10; i++ {
 		key := fmt.Sprintf("temp%d", i)
 		result := sendCommand(writer, reader, "GET", key)
-		if result.Type == resp.BulkString && result.Str == "" {
+		if result.Type == resp.BulkString && result.Null {
 			expiredCount++
 		}
 	}
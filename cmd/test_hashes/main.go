@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+func sendCommand(writer *resp.Writer, reader *resp.Reader, args ...string) resp.Value {
+	vals := make([]resp.Value, len(args))
+	for i, arg := range args {
+		vals[i] = resp.BulkValue(arg)
+	}
+	writer.WriteValue(resp.ArrayValue(vals))
+	response, _ := reader.ReadValue()
+	return response
+}
+
+func formatResponse(v resp.Value) string {
+	switch v.Type {
+	case resp.SimpleString:
+		return fmt.Sprintf("+%s", v.Str)
+	case resp.Error:
+		return fmt.Sprintf("-%s", v.Str)
+	case resp.Integer:
+		return fmt.Sprintf(":%d", v.Int)
+	case resp.BulkString:
+		if v.Null {
+			return "(nil)"
+		}
+		return fmt.Sprintf("\"%s\"", v.Str)
+	case resp.Array:
+		if v.Null {
+			return "(nil)"
+		}
+		if len(v.Array) == 0 {
+			return "(empty array)"
+		}
+		result := fmt.Sprintf("[%d] ", len(v.Array))
+		for i, item := range v.Array {
+			if i > 0 {
+				result += ", "
+			}
+			result += formatResponse(item)
+		}
+		return result
+	}
+	return "unknown"
+}
+
+func test(writer *resp.Writer, reader *resp.Reader, label string, args ...string) {
+	result := sendCommand(writer, reader, args...)
+	fmt.Printf("  %s -> %s\n", label, formatResponse(result))
+}
+
+func main() {
+	conn, err := net.Dial("tcp", "localhost:6379")
+	if err != nil {
+		fmt.Println("Failed to connect:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	reader := resp.NewReader(conn)
+	writer := resp.NewWriter(conn)
+
+	fmt.Println("=== Hash Commands Test ===")
+
+	// Clean up first
+	sendCommand(writer, reader, "DEL", "user:1", "plain")
+
+	fmt.Println("\n--- HASH COMMANDS ---")
+	test(writer, reader, "HSET user:1 name bob age 30", "HSET", "user:1", "name", "bob", "age", "30")
+	test(writer, reader, "HSET user:1 name alice", "HSET", "user:1", "name", "alice")
+	test(writer, reader, "HGET user:1 name", "HGET", "user:1", "name")
+	test(writer, reader, "HGET user:1 missing", "HGET", "user:1", "missing")
+	test(writer, reader, "HMGET user:1 name missing age", "HMGET", "user:1", "name", "missing", "age")
+	test(writer, reader, "HSETNX user:1 name bob", "HSETNX", "user:1", "name", "bob")
+	test(writer, reader, "HSETNX user:1 city paris", "HSETNX", "user:1", "city", "paris")
+	test(writer, reader, "HEXISTS user:1 city", "HEXISTS", "user:1", "city")
+	test(writer, reader, "HLEN user:1", "HLEN", "user:1")
+	test(writer, reader, "HSTRLEN user:1 name", "HSTRLEN", "user:1", "name")
+	test(writer, reader, "HKEYS user:1", "HKEYS", "user:1")
+	test(writer, reader, "HVALS user:1", "HVALS", "user:1")
+	test(writer, reader, "HGETALL user:1", "HGETALL", "user:1")
+	test(writer, reader, "HDEL user:1 city missing", "HDEL", "user:1", "city", "missing")
+
+	fmt.Println("\n--- HASH COUNTERS ---")
+	test(writer, reader, "HINCRBY user:1 age 5", "HINCRBY", "user:1", "age", "5")
+	test(writer, reader, "HINCRBY user:1 name 1", "HINCRBY", "user:1", "name", "1")
+	test(writer, reader, "HINCRBYFLOAT user:1 score 1.5", "HINCRBYFLOAT", "user:1", "score", "1.5")
+	test(writer, reader, "HINCRBYFLOAT user:1 score 0.25", "HINCRBYFLOAT", "user:1", "score", "0.25")
+
+	fmt.Println("\n--- TYPE CHECKS ---")
+	sendCommand(writer, reader, "SET", "plain", "value")
+	test(writer, reader, "HGET plain field", "HGET", "plain", "field")
+	test(writer, reader, "GET user:1", "GET", "user:1")
+
+	fmt.Println("\n=== All Tests Complete ===")
+}
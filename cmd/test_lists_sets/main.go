@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
 )
@@ -27,11 +28,14 @@ func formatResponse(v resp.Value) string {
 	case resp.Integer:
 		return fmt.Sprintf(":%d", v.Int)
 	case resp.BulkString:
-		if v.Str == "" {
+		if v.Null {
 			return "(nil)"
 		}
 		return fmt.Sprintf("\"%s\"", v.Str)
 	case resp.Array:
+		if v.Null {
+			return "(nil)"
+		}
 		if len(v.Array) == 0 {
 			return "(empty array)"
 		}
@@ -66,7 +70,7 @@ func main() {
 	fmt.Println("=== List & Set Commands Test ===")
 
 	// Clean up first
-	sendCommand(writer, reader, "DEL", "mylist", "myset", "set1", "set2")
+	sendCommand(writer, reader, "DEL", "mylist", "myset", "set1", "set2", "jobs", "done")
 
 	// List tests
 	fmt.Println("\n--- LIST COMMANDS ---")
@@ -80,6 +84,28 @@ func main() {
 	test(writer, reader, "RPOP mylist", "RPOP", "mylist")
 	test(writer, reader, "LRANGE mylist 0 -1", "LRANGE", "mylist", "0", "-1")
 
+	// Blocking list tests
+	fmt.Println("\n--- BLOCKING LIST COMMANDS ---")
+	sendCommand(writer, reader, "DEL", "jobs", "done")
+	test(writer, reader, "BLPOP jobs 0.1 (times out)", "BLPOP", "jobs", "0.1")
+
+	// Park a second client on the empty list, then feed it from this one
+	blocked := make(chan string)
+	go func() {
+		conn2, err := net.Dial("tcp", "localhost:6379")
+		if err != nil {
+			blocked <- "connect error: " + err.Error()
+			return
+		}
+		defer conn2.Close()
+		blocked <- formatResponse(sendCommand(resp.NewWriter(conn2), resp.NewReader(conn2), "BLMOVE", "jobs", "done", "LEFT", "RIGHT", "5"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	test(writer, reader, "RPUSH jobs job1", "RPUSH", "jobs", "job1")
+	fmt.Printf("  BLMOVE jobs done LEFT RIGHT 5 (other client) -> %s\n", <-blocked)
+	test(writer, reader, "LRANGE done 0 -1", "LRANGE", "done", "0", "-1")
+	test(writer, reader, "BRPOP jobs done 1", "BRPOP", "jobs", "done", "1")
+
 	// Set tests
 	fmt.Println("\n--- SET COMMANDS ---")
 	test(writer, reader, "SADD myset a b c", "SADD", "myset", "a", "b", "c")
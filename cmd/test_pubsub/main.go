@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+func sendCommand(writer *resp.Writer, reader *resp.Reader, args ...string) resp.Value {
+	vals := make([]resp.Value, len(args))
+	for i, arg := range args {
+		vals[i] = resp.BulkValue(arg)
+	}
+	writer.WriteValue(resp.ArrayValue(vals))
+	response, _ := reader.ReadValue()
+	return response
+}
+
+func formatResponse(v resp.Value) string {
+	switch v.Type {
+	case resp.SimpleString:
+		return fmt.Sprintf("+%s", v.Str)
+	case resp.Error:
+		return fmt.Sprintf("-%s", v.Str)
+	case resp.Integer:
+		return fmt.Sprintf(":%d", v.Int)
+	case resp.BulkString:
+		if v.Null {
+			return "(nil)"
+		}
+		return fmt.Sprintf("\"%s\"", v.Str)
+	case resp.Array:
+		if v.Null {
+			return "(nil)"
+		}
+		if len(v.Array) == 0 {
+			return "(empty array)"
+		}
+		result := fmt.Sprintf("[%d] ", len(v.Array))
+		for i, item := range v.Array {
+			if i > 0 {
+				result += ", "
+			}
+			result += formatResponse(item)
+		}
+		return result
+	}
+	return "unknown"
+}
+
+func test(writer *resp.Writer, reader *resp.Reader, label string, args ...string) {
+	result := sendCommand(writer, reader, args...)
+	fmt.Printf("  %s -> %s\n", label, formatResponse(result))
+}
+
+func readPush(reader *resp.Reader, label string) {
+	v, err := reader.ReadValue()
+	if err != nil {
+		fmt.Printf("  %s -> error: %v\n", label, err)
+		return
+	}
+	fmt.Printf("  %s -> %s\n", label, formatResponse(v))
+}
+
+func dial() (net.Conn, *resp.Reader, *resp.Writer) {
+	conn, err := net.Dial("tcp", "localhost:6379")
+	if err != nil {
+		fmt.Println("Failed to connect:", err)
+		os.Exit(1)
+	}
+	return conn, resp.NewReader(conn), resp.NewWriter(conn)
+}
+
+func send(writer *resp.Writer, args ...string) {
+	vals := make([]resp.Value, len(args))
+	for i, arg := range args {
+		vals[i] = resp.BulkValue(arg)
+	}
+	writer.WriteValue(resp.ArrayValue(vals))
+}
+
+func main() {
+	subConn, subReader, subWriter := dial()
+	defer subConn.Close()
+	pubConn, pubReader, pubWriter := dial()
+	defer pubConn.Close()
+
+	fmt.Println("=== Pub/Sub Test ===")
+
+	fmt.Println("\n--- SUBSCRIBE ---")
+	send(subWriter, "SUBSCRIBE", "news", "sport")
+	readPush(subReader, "SUBSCRIBE news (confirm 1)")
+	readPush(subReader, "SUBSCRIBE sport (confirm 2)")
+	send(subWriter, "PSUBSCRIBE", "news.*")
+	readPush(subReader, "PSUBSCRIBE news.*")
+
+	fmt.Println("\n--- PUBLISH ---")
+	test(pubWriter, pubReader, "PUBLISH news hello", "PUBLISH", "news", "hello")
+	readPush(subReader, "subscriber receives")
+	test(pubWriter, pubReader, "PUBLISH news.tech gopher", "PUBLISH", "news.tech", "gopher")
+	readPush(subReader, "subscriber receives pmessage")
+	test(pubWriter, pubReader, "PUBLISH nobody x", "PUBLISH", "nobody", "x")
+
+	fmt.Println("\n--- PUBSUB ---")
+	test(pubWriter, pubReader, "PUBSUB CHANNELS", "PUBSUB", "CHANNELS")
+	test(pubWriter, pubReader, "PUBSUB CHANNELS s*", "PUBSUB", "CHANNELS", "s*")
+	test(pubWriter, pubReader, "PUBSUB NUMSUB news missing", "PUBSUB", "NUMSUB", "news", "missing")
+	test(pubWriter, pubReader, "PUBSUB NUMPAT", "PUBSUB", "NUMPAT")
+
+	fmt.Println("\n--- SUBSCRIBER MODE ---")
+	test(subWriter, subReader, "GET key (should error)", "GET", "key")
+	test(subWriter, subReader, "PING", "PING", "hi")
+
+	fmt.Println("\n--- UNSUBSCRIBE ---")
+	send(subWriter, "UNSUBSCRIBE")
+	readPush(subReader, "UNSUBSCRIBE (news)")
+	readPush(subReader, "UNSUBSCRIBE (sport)")
+	send(subWriter, "PUNSUBSCRIBE")
+	readPush(subReader, "PUNSUBSCRIBE (news.*)")
+	test(subWriter, subReader, "PING (normal mode again)", "PING")
+
+	// Give the server a moment to drop the subscriptions
+	time.Sleep(50 * time.Millisecond)
+	test(pubWriter, pubReader, "PUBLISH news bye (no receivers)", "PUBLISH", "news", "bye")
+
+	fmt.Println("\n=== All tests completed ===")
+}
@@ -0,0 +1,116 @@
+// Command test_replication checks that a replica ends up with the same data
+// as its master when many clients write the same keys at once. The list
+// records every write, so it shows any write the replica applied out of
+// order. Start a master on 6379 and a replica of it on 6380 first:
+//
+//	go run ./cmd/server
+//	go run ./cmd/server -addr :6380 -replicaof "127.0.0.1 6379" -appenddirname replicadir -dbfilename replica.rdb
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/client"
+)
+
+const (
+	clients = 50
+	writes  = 200 // per client and round
+	rounds  = 5
+)
+
+var failed bool
+
+func check(label string, ok bool, got any) {
+	status := "PASS"
+	if !ok {
+		status = "FAIL"
+		failed = true
+	}
+	fmt.Printf("[%s] %s -> %v\n", status, label, got)
+}
+
+// infoField returns a field of INFO replication
+func infoField(ctx context.Context, c *client.Client, field string) string {
+	info, _ := c.Do(ctx, "INFO", "replication").Val().(string)
+	for _, line := range strings.Split(info, "\r\n") {
+		if v, ok := strings.CutPrefix(line, field+":"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// caughtUp waits until the replica applied everything the master sent
+func caughtUp(ctx context.Context, master, replica *client.Client) bool {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		sent := infoField(ctx, master, "master_repl_offset")
+		if sent != "" && sent == infoField(ctx, replica, "slave_repl_offset") {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+func main() {
+	ctx := context.Background()
+	master := client.New(client.Options{Addr: "localhost:6379", PoolSize: clients})
+	defer master.Close()
+	replica := client.New(client.Options{Addr: "localhost:6380"})
+	defer replica.Close()
+
+	if err := master.Ping(ctx).Err(); err != nil {
+		fmt.Println("Failed to connect to the master:", err)
+		os.Exit(1)
+	}
+	if err := replica.Ping(ctx).Err(); err != nil {
+		fmt.Println("Failed to connect to the replica:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("=== Replication Test ===")
+	slaves := infoField(ctx, master, "connected_slaves")
+	check("replica attached", slaves != "" && slaves != "0", slaves)
+
+	for round := 1; round <= rounds; round++ {
+		fmt.Printf("\n--- ROUND %d: %d clients x %d writes to the same keys ---\n", round, clients, writes)
+		master.Del(ctx, "repl:key", "repl:list")
+
+		var wg sync.WaitGroup
+		for c := 0; c < clients; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				for i := 0; i < writes; i++ {
+					v := strconv.Itoa(c) + ":" + strconv.Itoa(i)
+					master.Set(ctx, "repl:key", v, 0)
+					master.RPush(ctx, "repl:list", v)
+				}
+			}(c)
+		}
+		wg.Wait()
+
+		check("replica caught up", caughtUp(ctx, master, replica), infoField(ctx, master, "master_repl_offset"))
+
+		want, got := master.Get(ctx, "repl:key").Val(), replica.Get(ctx, "repl:key").Val()
+		check("SET: last write wins on both", want == got, fmt.Sprintf("master %q, replica %q", want, got))
+
+		wantList := master.LRange(ctx, "repl:list", 0, -1).Val()
+		gotList := replica.LRange(ctx, "repl:list", 0, -1).Val()
+		check("RPUSH: same order on both", len(wantList) == clients*writes && strings.Join(wantList, " ") == strings.Join(gotList, " "),
+			fmt.Sprintf("%d elements on the master, %d on the replica", len(wantList), len(gotList)))
+	}
+
+	fmt.Println("\n=== All Tests Complete ===")
+	if failed {
+		os.Exit(1)
+	}
+}
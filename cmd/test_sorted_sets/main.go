@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+func sendCommand(writer *resp.Writer, reader *resp.Reader, args ...string) resp.Value {
+	vals := make([]resp.Value, len(args))
+	for i, arg := range args {
+		vals[i] = resp.BulkValue(arg)
+	}
+	writer.WriteValue(resp.ArrayValue(vals))
+	response, _ := reader.ReadValue()
+	return response
+}
+
+func formatResponse(v resp.Value) string {
+	switch v.Type {
+	case resp.SimpleString:
+		return fmt.Sprintf("+%s", v.Str)
+	case resp.Error:
+		return fmt.Sprintf("-%s", v.Str)
+	case resp.Integer:
+		return fmt.Sprintf(":%d", v.Int)
+	case resp.BulkString:
+		if v.Null {
+			return "(nil)"
+		}
+		return fmt.Sprintf("\"%s\"", v.Str)
+	case resp.Array:
+		if v.Null {
+			return "(nil)"
+		}
+		if len(v.Array) == 0 {
+			return "(empty array)"
+		}
+		result := fmt.Sprintf("[%d] ", len(v.Array))
+		for i, item := range v.Array {
+			if i > 0 {
+				result += ", "
+			}
+			result += formatResponse(item)
+		}
+		return result
+	}
+	return "unknown"
+}
+
+func test(writer *resp.Writer, reader *resp.Reader, label string, args ...string) {
+	result := sendCommand(writer, reader, args...)
+	fmt.Printf("  %s -> %s\n", label, formatResponse(result))
+}
+
+func main() {
+	conn, err := net.Dial("tcp", "localhost:6379")
+	if err != nil {
+		fmt.Println("Failed to connect:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	reader := resp.NewReader(conn)
+	writer := resp.NewWriter(conn)
+
+	fmt.Println("=== Sorted Set Commands Test ===")
+
+	// Clean up first
+	sendCommand(writer, reader, "DEL", "leaderboard", "z1", "z2", "out")
+
+	fmt.Println("\n--- SORTED SET COMMANDS ---")
+	test(writer, reader, "ZADD leaderboard 10 alice 20 bob 15 carol", "ZADD", "leaderboard", "10", "alice", "20", "bob", "15", "carol")
+	test(writer, reader, "ZADD leaderboard NX 99 alice 5 dave", "ZADD", "leaderboard", "NX", "99", "alice", "5", "dave")
+	test(writer, reader, "ZADD leaderboard GT CH 1 bob 30 carol", "ZADD", "leaderboard", "GT", "CH", "1", "bob", "30", "carol")
+	test(writer, reader, "ZADD leaderboard INCR 5 alice", "ZADD", "leaderboard", "INCR", "5", "alice")
+	test(writer, reader, "ZINCRBY leaderboard 2.5 bob", "ZINCRBY", "leaderboard", "2.5", "bob")
+	test(writer, reader, "ZSCORE leaderboard bob", "ZSCORE", "leaderboard", "bob")
+	test(writer, reader, "ZCARD leaderboard", "ZCARD", "leaderboard")
+	test(writer, reader, "ZRANK leaderboard alice", "ZRANK", "leaderboard", "alice")
+	test(writer, reader, "ZREVRANK leaderboard alice", "ZREVRANK", "leaderboard", "alice")
+
+	fmt.Println("\n--- RANGE QUERIES ---")
+	test(writer, reader, "ZRANGE leaderboard 0 -1 WITHSCORES", "ZRANGE", "leaderboard", "0", "-1", "WITHSCORES")
+	test(writer, reader, "ZRANGE leaderboard 0 1 REV", "ZRANGE", "leaderboard", "0", "1", "REV")
+	test(writer, reader, "ZRANGE leaderboard (10 +inf BYSCORE", "ZRANGE", "leaderboard", "(10", "+inf", "BYSCORE")
+	test(writer, reader, "ZRANGE leaderboard +inf -inf BYSCORE REV LIMIT 0 2", "ZRANGE", "leaderboard", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "0", "2")
+	test(writer, reader, "ZRANGEBYSCORE leaderboard 5 20", "ZRANGEBYSCORE", "leaderboard", "5", "20")
+	test(writer, reader, "ZCOUNT leaderboard 5 (20", "ZCOUNT", "leaderboard", "5", "(20")
+	sendCommand(writer, reader, "ZADD", "z1", "0", "a", "0", "b", "0", "c")
+	test(writer, reader, "ZRANGE z1 [b + BYLEX", "ZRANGE", "z1", "[b", "+", "BYLEX")
+
+	fmt.Println("\n--- POPS AND STORES ---")
+	test(writer, reader, "ZPOPMIN leaderboard", "ZPOPMIN", "leaderboard")
+	test(writer, reader, "ZPOPMAX leaderboard 2", "ZPOPMAX", "leaderboard", "2")
+	sendCommand(writer, reader, "ZADD", "z2", "10", "b", "20", "c", "30", "d")
+	test(writer, reader, "ZUNIONSTORE out 2 z1 z2", "ZUNIONSTORE", "out", "2", "z1", "z2")
+	test(writer, reader, "ZRANGE out 0 -1 WITHSCORES", "ZRANGE", "out", "0", "-1", "WITHSCORES")
+	test(writer, reader, "ZINTERSTORE out 2 z1 z2 WEIGHTS 2 1 AGGREGATE MAX", "ZINTERSTORE", "out", "2", "z1", "z2", "WEIGHTS", "2", "1", "AGGREGATE", "MAX")
+	test(writer, reader, "ZRANGE out 0 -1 WITHSCORES", "ZRANGE", "out", "0", "-1", "WITHSCORES")
+
+	fmt.Println("\n=== All Tests Complete ===")
+}
@@ -35,11 +35,14 @@ func formatResponse(v resp.Value) string {
 	case resp.Integer:
 		return fmt.Sprintf(":%d", v.Int)
 	case resp.BulkString:
-		if v.Str == "" {
+		if v.Null {
 			return "(nil)"
 		}
 		return fmt.Sprintf("\"%s\"", v.Str)
 	case resp.Array:
+		if v.Null {
+			return "(nil)"
+		}
 		return fmt.Sprintf("[%d items]", len(v.Array))
 	}
 	return "unknown"
@@ -91,5 +94,28 @@ func main() {
 	test(writer, reader, "\"value1\"", "GET", "tx1")
 	test(writer, reader, "\"value2\"", "GET", "tx2")
 
+	// WATCH test: a second client modifies the watched key before EXEC
+	fmt.Println("\nWATCH Test:")
+	other, err := net.Dial("tcp", "localhost:6379")
+	if err != nil {
+		fmt.Println("Failed to connect:", err)
+		os.Exit(1)
+	}
+	defer other.Close()
+	otherReader := resp.NewReader(other)
+	otherWriter := resp.NewWriter(other)
+
+	test(writer, reader, "+OK", "WATCH", "tx1")
+	test(otherWriter, otherReader, "+OK", "SET", "tx1", "changed")
+	test(writer, reader, "+OK", "MULTI")
+	test(writer, reader, "+QUEUED", "SET", "tx1", "mine")
+	test(writer, reader, "(nil)", "EXEC")
+	test(writer, reader, "\"changed\"", "GET", "tx1")
+	test(writer, reader, "+OK", "WATCH", "tx1")
+	test(writer, reader, "+OK", "MULTI")
+	test(writer, reader, "+QUEUED", "SET", "tx1", "mine")
+	test(writer, reader, "[1 items]", "EXEC")
+	test(writer, reader, "\"mine\"", "GET", "tx1")
+
 	fmt.Println("\nAll tests completed!")
 }
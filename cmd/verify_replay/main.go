@@ -43,7 +43,7 @@ func main() {
 
 	response, _ = reader.ReadValue()
 	fmt.Printf("GET mykey -> ")
-	if response.Type == resp.BulkString && response.Str == "" {
+	if response.Type == resp.BulkString && response.Null {
 		fmt.Printf("(nil) (PASS - delete persisted!)\n")
 	} else {
 		fmt.Printf("%v (FAIL - expected nil)\n", response)
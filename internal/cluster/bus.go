@@ -0,0 +1,599 @@
+package cluster
+
+import (
+	"encoding/gob"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// How often the bus checks links, timeouts and who to ping
+	cronInterval = 100 * time.Millisecond
+
+	// Every this many cron runs a random node is pinged, so every node
+	// hears from every other node without a full mesh of pings
+	randomPingEvery = 10
+
+	busDialTimeout  = time.Second
+	busWriteTimeout = time.Second
+
+	// Messages waiting for a slow link beyond this are dropped
+	linkQueueSize = 64
+)
+
+type msgType uint8
+
+const (
+	msgPing msgType = iota
+	msgPong
+	msgMeet // a PING that also asks an unknown receiver to add the sender
+	msgFail // tells that the Failing node is down
+)
+
+// message is what nodes exchange over the bus. Every message carries the
+// sender's view of itself, so ownership changes spread with the pings.
+type message struct {
+	Type         msgType
+	ID           string
+	IP           string // empty when unknown, the receiver uses the connection's address
+	Port         int
+	BusPort      int
+	CurrentEpoch uint64
+	ConfigEpoch  uint64
+	Slots        slotSet
+
+	// Gossip tells about a few other nodes, so nodes learn about each
+	// other and about failures
+	Gossip []gossip
+
+	Failing string
+}
+
+type gossip struct {
+	ID      string
+	IP      string
+	Port    int
+	BusPort int
+	Failing bool // the sender can't reach the node
+}
+
+// link is a bus connection. Messages are queued and written by a goroutine
+// of the link, so they can be sent while holding the Cluster's mutex.
+type link struct {
+	conn    net.Conn
+	dec     *gob.Decoder
+	out     chan *message
+	created time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newLink(conn net.Conn) *link {
+	l := &link{
+		conn:    conn,
+		dec:     gob.NewDecoder(conn),
+		out:     make(chan *message, linkQueueSize),
+		created: time.Now(),
+		done:    make(chan struct{}),
+	}
+	go l.writeLoop()
+	return l
+}
+
+func (l *link) writeLoop() {
+	enc := gob.NewEncoder(l.conn)
+	for {
+		select {
+		case m := <-l.out:
+			l.conn.SetWriteDeadline(time.Now().Add(busWriteTimeout))
+			if err := enc.Encode(m); err != nil {
+				l.close()
+				return
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *link) send(m *message) {
+	select {
+	case l.out <- m:
+	default:
+	}
+}
+
+func (l *link) close() {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.conn.Close()
+	})
+}
+
+// Start listens on the bus port and starts talking to the known nodes
+func (c *Cluster) Start() error {
+	ln, err := net.Listen("tcp", net.JoinHostPort(c.cfg.IP, strconv.Itoa(c.cfg.BusPort)))
+	if err != nil {
+		return err
+	}
+	c.ln = ln
+	log.Printf("Cluster bus listening on %s", ln.Addr())
+
+	c.wg.Add(2)
+	go c.acceptLoop()
+	go c.cron()
+	return nil
+}
+
+// Close stops the bus and saves the config
+func (c *Cluster) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.stop)
+	if c.ln != nil {
+		c.ln.Close()
+	}
+	for _, n := range c.nodes {
+		if n.link != nil {
+			n.link.close()
+			n.link = nil
+		}
+	}
+	for l := range c.inbound {
+		l.close()
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.save(); err != nil {
+		log.Printf("Saving cluster config: %v", err)
+	}
+}
+
+func (c *Cluster) acceptLoop() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l := newLink(conn)
+		c.inbound[l] = struct{}{}
+		c.mu.Unlock()
+
+		go c.serveInbound(l)
+	}
+}
+
+// serveInbound answers the pings another node sends on its link to us
+func (c *Cluster) serveInbound(l *link) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.inbound, l)
+		c.mu.Unlock()
+		l.close()
+	}()
+
+	for {
+		var m message
+		if err := l.dec.Decode(&m); err != nil {
+			return
+		}
+		c.process(&m, l, nil)
+	}
+}
+
+// connect opens the link to n and greets it
+func (c *Cluster) connect(n *node, addr string) {
+	conn, err := net.DialTimeout("tcp", addr, busDialTimeout)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n.connecting = false
+	if err != nil {
+		// An unreachable node must end up flagged as failing too
+		if n.pingSent.IsZero() {
+			n.pingSent = time.Now()
+		}
+		return
+	}
+	if c.closed || c.nodes[n.id] != n {
+		conn.Close()
+		return
+	}
+
+	l := newLink(conn)
+	n.link = l
+	go c.readLink(n, l)
+
+	if n.flags&flagMeet != 0 {
+		n.flags &^= flagMeet
+		c.ping(n, msgMeet)
+	} else {
+		c.ping(n, msgPing)
+	}
+}
+
+// readLink receives the pongs n sends back on our link to it
+func (c *Cluster) readLink(n *node, l *link) {
+	for {
+		var m message
+		if err := l.dec.Decode(&m); err != nil {
+			break
+		}
+		c.process(&m, l, n)
+	}
+
+	c.mu.Lock()
+	if n.link == l {
+		n.link = nil
+	}
+	c.mu.Unlock()
+	l.close()
+}
+
+// ping sends a PING or MEET to n on its link
+func (c *Cluster) ping(n *node, t msgType) {
+	if n.pingSent.IsZero() {
+		n.pingSent = time.Now()
+	}
+	c.send(n.link, c.message(t, n))
+}
+
+func (c *Cluster) send(l *link, m *message) {
+	c.sent++
+	l.send(m)
+}
+
+// message builds a message about this node for to, gossiping about a few
+// random other nodes and about every node we suspect
+func (c *Cluster) message(t msgType, to *node) *message {
+	m := &message{
+		Type:         t,
+		ID:           c.myself.id,
+		IP:           c.myself.ip,
+		Port:         c.myself.port,
+		BusPort:      c.myself.busPort,
+		CurrentEpoch: c.currentEpoch,
+		ConfigEpoch:  c.myself.configEpoch,
+		Slots:        c.myself.slots,
+	}
+
+	var candidates, failing []*node
+	for _, n := range c.nodes {
+		if n == c.myself || n == to || n.flags&flagHandshake != 0 {
+			continue
+		}
+		if n.failing() {
+			failing = append(failing, n)
+		} else {
+			candidates = append(candidates, n)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	wanted := max(3, len(c.nodes)/10)
+	for _, n := range append(failing, candidates[:min(wanted, len(candidates))]...) {
+		m.Gossip = append(m.Gossip, gossip{
+			ID:      n.id,
+			IP:      n.ip,
+			Port:    n.port,
+			BusPort: n.busPort,
+			Failing: n.failing(),
+		})
+	}
+	return m
+}
+
+// process handles a message received on l. from is the node l connects
+// to for our own links, nil for links other nodes opened to us.
+func (c *Cluster) process(m *message, l *link, from *node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.received++
+
+	ip := m.IP
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(l.conn.RemoteAddr().String())
+	}
+	if m.Type == msgMeet && c.myself.ip == "" {
+		// Other nodes reach us at the address this MEET arrived on
+		c.myself.ip, _, _ = net.SplitHostPort(l.conn.LocalAddr().String())
+		log.Printf("IP address for this node updated to %s", c.myself.ip)
+		c.dirty = true
+	}
+
+	sender := c.nodes[m.ID]
+	if sender != nil && sender.flags&flagHandshake != 0 {
+		sender = nil
+	}
+
+	// The first reply to a handshake tells us the node's real ID
+	if m.Type == msgPong && from != nil && from.flags&flagHandshake != 0 {
+		delete(c.nodes, from.id)
+		if sender != nil {
+			// Already known under its real ID, e.g. through gossip
+			if from.link == l {
+				from.link = nil
+			}
+			l.close()
+		} else {
+			from.id = m.ID
+			from.flags &^= flagHandshake | flagMeet
+			c.nodes[from.id] = from
+			sender = from
+			log.Printf("Handshake with node %s completed", from.id)
+		}
+		c.dirty = true
+	}
+
+	// Only a MEET makes a stranger part of the cluster
+	if sender == nil && m.Type == msgMeet {
+		sender = newNode(m.ID, ip, m.Port, m.BusPort, 0)
+		c.nodes[sender.id] = sender
+		c.dirty = true
+		log.Printf("Node %s joined the cluster by MEET", sender.id)
+	}
+
+	if m.Type == msgPing || m.Type == msgMeet {
+		c.send(l, c.message(msgPong, sender))
+	}
+	if sender == nil {
+		return
+	}
+
+	if m.CurrentEpoch > c.currentEpoch {
+		c.currentEpoch = m.CurrentEpoch
+		c.dirty = true
+	}
+	if sender.ip != ip || sender.port != m.Port || sender.busPort != m.BusPort {
+		sender.ip, sender.port, sender.busPort = ip, m.Port, m.BusPort
+		if sender.link != nil {
+			sender.link.close()
+			sender.link = nil
+		}
+		c.dirty = true
+	}
+	if sender.configEpoch != m.ConfigEpoch {
+		sender.configEpoch = m.ConfigEpoch
+		c.dirty = true
+	}
+
+	if m.Type == msgPong && from == sender {
+		sender.pingSent = time.Time{}
+		sender.pongRecv = time.Now()
+		if sender.failing() {
+			sender.flags &^= flagPFail | flagFail
+			log.Printf("Node %s is reachable again", sender.id)
+			c.dirty = true
+		}
+	}
+
+	c.updateSlots(sender, &m.Slots)
+	c.resolveEpochCollision(sender)
+
+	for _, g := range m.Gossip {
+		c.processGossip(sender, g)
+	}
+
+	if m.Type == msgFail {
+		if n := c.nodes[m.Failing]; n != nil && n != c.myself && n.flags&flagFail == 0 {
+			n.flags = n.flags&^flagPFail | flagFail
+			log.Printf("FAIL message received from %s about %s", sender.id, n.id)
+			c.dirty = true
+		}
+	}
+	c.updateState()
+}
+
+// updateSlots applies the slots sender claims: a slot changes hands when
+// it was unassigned or the claim comes with a newer config epoch than the
+// current owner's
+func (c *Cluster) updateSlots(sender *node, claimed *slotSet) {
+	for slot := range Slots {
+		if !claimed.has(slot) {
+			continue
+		}
+		owner := c.slots[slot]
+		if owner == sender || c.importing[slot] != nil {
+			continue
+		}
+		if owner != nil && owner.configEpoch >= sender.configEpoch {
+			continue
+		}
+		if owner == c.myself {
+			log.Printf("Slot %d is now served by %s", slot, sender.id)
+			delete(c.migrating, slot)
+		}
+		c.setOwner(slot, sender)
+		c.dirty = true
+	}
+}
+
+// resolveEpochCollision makes sure no two nodes share a config epoch, as
+// ties leave slot conflicts unresolved. Of two colliding nodes the one with
+// the smaller ID moves on to a new epoch.
+func (c *Cluster) resolveEpochCollision(sender *node) {
+	if sender.configEpoch != c.myself.configEpoch || sender.id <= c.myself.id {
+		return
+	}
+	c.currentEpoch++
+	c.myself.configEpoch = c.currentEpoch
+	c.dirty = true
+}
+
+// processGossip learns about nodes the sender knows and records its
+// opinion on whether they are failing
+func (c *Cluster) processGossip(sender *node, g gossip) {
+	n := c.nodes[g.ID]
+	if n == nil {
+		if !g.Failing && g.IP != "" {
+			n = newNode(g.ID, g.IP, g.Port, g.BusPort, 0)
+			c.nodes[n.id] = n
+			c.dirty = true
+		}
+		return
+	}
+	if n == c.myself || n.flags&flagHandshake != 0 {
+		return
+	}
+
+	if g.Failing {
+		n.failReports[sender.id] = time.Now()
+		c.markFailing(n)
+	} else {
+		delete(n.failReports, sender.id)
+	}
+}
+
+// markFailing flags a node we suspect as failed once a majority of the
+// masters serving slots agree, and tells every node
+func (c *Cluster) markFailing(n *node) {
+	if n.flags&flagPFail == 0 || n.flags&flagFail != 0 {
+		return
+	}
+
+	for id, at := range n.failReports {
+		if time.Since(at) > 2*c.cfg.NodeTimeout || c.nodes[id] == nil {
+			delete(n.failReports, id)
+		}
+	}
+	if len(n.failReports)+1 < c.size()/2+1 {
+		return
+	}
+
+	n.flags = n.flags&^flagPFail | flagFail
+	log.Printf("Marking node %s as failing (quorum reached)", n.id)
+	c.dirty = true
+	c.updateState()
+
+	for _, other := range c.nodes {
+		if other.link != nil {
+			m := c.message(msgFail, other)
+			m.Failing = n.id
+			c.send(other.link, m)
+		}
+	}
+}
+
+// cron keeps links up, pings nodes and notices the ones that stopped
+// answering
+func (c *Cluster) cron() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(cronInterval)
+	defer ticker.Stop()
+
+	for tick := 1; ; tick++ {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		c.cronLocked(tick)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Cluster) cronLocked(tick int) {
+	now := time.Now()
+	timeout := c.cfg.NodeTimeout
+
+	var idle []*node
+	for _, n := range c.nodes {
+		if n == c.myself {
+			continue
+		}
+
+		// A handshake nobody answered is given up
+		if n.flags&flagHandshake != 0 && now.Sub(n.created) > timeout {
+			delete(c.nodes, n.id)
+			if n.link != nil {
+				n.link.close()
+			}
+			continue
+		}
+
+		if n.link == nil {
+			if !n.connecting {
+				n.connecting = true
+				go c.connect(n, n.busAddr())
+			}
+			continue
+		}
+
+		// A link waiting long for a pong may be stuck, try a fresh one
+		if !n.pingSent.IsZero() && now.Sub(n.pingSent) > timeout/2 && now.Sub(n.link.created) > timeout/2 {
+			n.link.close()
+			n.link = nil
+			continue
+		}
+
+		switch {
+		case c.broadcast:
+			c.ping(n, msgPing)
+		case n.pingSent.IsZero() && now.Sub(n.pongRecv) > timeout/2:
+			c.ping(n, msgPing)
+		case n.pingSent.IsZero():
+			idle = append(idle, n)
+		}
+	}
+	c.broadcast = false
+
+	// Ping the node we heard from last among a few random ones
+	if tick%randomPingEvery == 0 && len(idle) > 0 {
+		rand.Shuffle(len(idle), func(i, j int) { idle[i], idle[j] = idle[j], idle[i] })
+		oldest := idle[0]
+		for _, n := range idle[1:min(5, len(idle))] {
+			if n.pongRecv.Before(oldest.pongRecv) {
+				oldest = n
+			}
+		}
+		c.ping(oldest, msgPing)
+	}
+
+	for _, n := range c.nodes {
+		if n == c.myself || n.flags&flagHandshake != 0 || n.pingSent.IsZero() {
+			continue
+		}
+		if now.Sub(n.pingSent) > timeout && !n.failing() {
+			n.flags |= flagPFail
+			log.Printf("Node %s possibly failing", n.id)
+		}
+		c.markFailing(n)
+	}
+
+	c.updateState()
+	if c.dirty {
+		if err := c.save(); err != nil {
+			log.Printf("Saving cluster config: %v", err)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+// Package cluster implements Redis Cluster style sharding: the keyspace is
+// split into 16384 hash slots, each owned by one node. Nodes learn about
+// each other and about slot ownership by gossiping over a cluster bus, and
+// clients that reach the wrong node are redirected with MOVED or ASK.
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+	ErrDown      = errors.New("CLUSTERDOWN The cluster is down")
+	ErrTryAgain  = errors.New("TRYAGAIN Multiple keys request during rehashing of slot")
+)
+
+// Redirect tells a client which node to ask for a slot. A MOVED redirect
+// means the slot lives there now, an ASK one only applies to the next
+// command, sent after ASKING, while the slot is being migrated.
+type Redirect struct {
+	Ask  bool
+	Slot int
+	Addr string
+}
+
+func (r *Redirect) Error() string {
+	kind := "MOVED"
+	if r.Ask {
+		kind = "ASK"
+	}
+	return fmt.Sprintf("%s %d %s", kind, r.Slot, r.Addr)
+}
+
+// Config configures the node this server runs as
+type Config struct {
+	// IP is the address announced to the other nodes. When empty it is
+	// learned from the first MEET received.
+	IP      string
+	Port    int
+	BusPort int // Port+10000 when zero
+
+	// ConfigFile is where the node saves its view of the cluster, so it
+	// keeps its ID and slots across restarts
+	ConfigFile string
+
+	// NodeTimeout is how long a node may not answer before it is
+	// considered failing
+	NodeTimeout time.Duration
+}
+
+// Cluster is this server's view of the cluster and its bus endpoint
+type Cluster struct {
+	cfg Config
+
+	mu           sync.Mutex
+	myself       *node
+	nodes        map[string]*node
+	slots        [Slots]*node
+	migrating    map[int]*node // slots of ours moving to another node
+	importing    map[int]*node // slots moving here from another node
+	currentEpoch uint64
+	ok           bool // every slot is served by a reachable node
+	dirty        bool // the config file must be rewritten
+	broadcast    bool // every node must hear from us soon
+
+	sent, received int64
+
+	ln      net.Listener
+	inbound map[*link]struct{}
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New loads the node's config file, or creates a new node with a random ID
+// and no slots when there is none. The bus starts with Start.
+func New(cfg Config) (*Cluster, error) {
+	if cfg.BusPort == 0 {
+		cfg.BusPort = cfg.Port + 10000
+	}
+	c := &Cluster{
+		cfg:       cfg,
+		nodes:     make(map[string]*node),
+		migrating: make(map[int]*node),
+		importing: make(map[int]*node),
+		inbound:   make(map[*link]struct{}),
+		stop:      make(chan struct{}),
+	}
+
+	err := c.load()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.myself = newNode(newNodeID(), cfg.IP, cfg.Port, cfg.BusPort, flagMyself)
+		c.nodes[c.myself.id] = c.myself
+		log.Printf("No cluster configuration found, I'm %s", c.myself.id)
+		if err := c.save(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("cluster config %s: %w", cfg.ConfigFile, err)
+	default:
+		// The address may have changed since the config was saved
+		c.myself.port = cfg.Port
+		c.myself.busPort = cfg.BusPort
+		if cfg.IP != "" {
+			c.myself.ip = cfg.IP
+		}
+		log.Printf("Node configuration loaded, I'm %s", c.myself.id)
+	}
+
+	c.updateState()
+	return c, nil
+}
+
+// MyID returns this node's ID
+func (c *Cluster) MyID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.myself.id
+}
+
+// Route checks that this node serves a command's keys. All keys must hash
+// to the same slot. The error is the reply for the client: a *Redirect
+// when another node owns the slot, or one of the Err values. exists tells
+// whether a key is stored here, which matters while a slot migrates: keys
+// already moved are asked for at the target.
+func (c *Cluster) Route(keys []string, asking bool, exists func(key string) bool) error {
+	slot := KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if KeySlot(key) != slot {
+			return ErrCrossSlot
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ok {
+		return ErrDown
+	}
+
+	owner := c.slots[slot]
+	if owner != c.myself {
+		if asking && c.importing[slot] != nil {
+			// Keys arrive one by one, a multi-key command can only run
+			// once all of them are here
+			if len(keys) > 1 && missing(keys, exists) > 0 {
+				return ErrTryAgain
+			}
+			return nil
+		}
+		return &Redirect{Slot: slot, Addr: owner.addr()}
+	}
+
+	if target := c.migrating[slot]; target != nil {
+		switch missing(keys, exists) {
+		case 0:
+		case len(keys):
+			return &Redirect{Ask: true, Slot: slot, Addr: target.addr()}
+		default:
+			return ErrTryAgain
+		}
+	}
+	return nil
+}
+
+func missing(keys []string, exists func(string) bool) int {
+	n := 0
+	for _, key := range keys {
+		if !exists(key) {
+			n++
+		}
+	}
+	return n
+}
+
+// AddSlots assigns unassigned slots to this node
+func (c *Cluster) AddSlots(slots []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[int]bool, len(slots))
+	for _, slot := range slots {
+		if seen[slot] {
+			return fmt.Errorf("ERR Slot %d specified multiple times", slot)
+		}
+		seen[slot] = true
+		if c.slots[slot] != nil {
+			return fmt.Errorf("ERR Slot %d is already busy", slot)
+		}
+	}
+
+	for _, slot := range slots {
+		delete(c.importing, slot)
+		c.setOwner(slot, c.myself)
+	}
+	c.dirty = true
+	c.broadcast = true
+	c.updateState()
+	return nil
+}
+
+// Meet starts a handshake with the node at ip:port, which joins the
+// clusters the two nodes are part of
+func (c *Cluster) Meet(ip string, port, busPort int) error {
+	if net.ParseIP(ip) == nil || port <= 0 || port > 65535 || busPort <= 0 || busPort > 65535 {
+		return fmt.Errorf("ERR Invalid node address specified: %s:%d", ip, port)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, n := range c.nodes {
+		if n.flags&flagHandshake != 0 && n.ip == ip && n.port == port && n.busPort == busPort {
+			return nil
+		}
+	}
+	n := newNode(newNodeID(), ip, port, busPort, flagHandshake|flagMeet)
+	c.nodes[n.id] = n
+	return nil
+}
+
+// SetSlot changes the state of a slot, as CLUSTER SETSLOT does. action is
+// MIGRATING or IMPORTING to start moving a slot from or to the node with
+// the given ID, STABLE to stop and NODE to hand the slot to the node. keys
+// counts the keys stored here for a slot.
+func (c *Cluster) SetSlot(slot int, action, id string, keys func(slot int) int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n *node
+	if action != "STABLE" {
+		if n = c.nodes[id]; n == nil || n.flags&flagHandshake != 0 {
+			return fmt.Errorf("ERR I don't know about node %s", id)
+		}
+	}
+
+	switch action {
+	case "MIGRATING":
+		if c.slots[slot] != c.myself {
+			return fmt.Errorf("ERR I'm not the owner of hash slot %d", slot)
+		}
+		if n == c.myself {
+			return errors.New("ERR Can't MIGRATE to myself")
+		}
+		c.migrating[slot] = n
+
+	case "IMPORTING":
+		if c.slots[slot] == c.myself {
+			return fmt.Errorf("ERR I'm already the owner of hash slot %d", slot)
+		}
+		if n == c.myself {
+			return errors.New("ERR Can't IMPORT from myself")
+		}
+		c.importing[slot] = n
+
+	case "STABLE":
+		delete(c.migrating, slot)
+		delete(c.importing, slot)
+
+	case "NODE":
+		if c.slots[slot] == c.myself && n != c.myself && keys(slot) > 0 {
+			return fmt.Errorf("ERR Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+		}
+		if n != c.myself {
+			delete(c.migrating, slot)
+		}
+		if n == c.myself && c.importing[slot] != nil {
+			// The slot is ours now. A new config epoch makes our claim
+			// win over the old owner's when the other nodes hear of it.
+			delete(c.importing, slot)
+			c.currentEpoch++
+			c.myself.configEpoch = c.currentEpoch
+			log.Printf("Slot %d imported, configEpoch set to %d", slot, c.myself.configEpoch)
+		}
+		c.setOwner(slot, n)
+		c.broadcast = true
+
+	default:
+		return errors.New("ERR Invalid CLUSTER SETSLOT action or number of arguments. Try CLUSTER HELP")
+	}
+
+	c.dirty = true
+	c.updateState()
+	return nil
+}
+
+// setOwner records n as the owner of slot, nil for unassigned
+func (c *Cluster) setOwner(slot int, n *node) {
+	if old := c.slots[slot]; old != nil {
+		old.slots.clear(slot)
+	}
+	c.slots[slot] = n
+	if n != nil {
+		n.slots.set(slot)
+	}
+}
+
+// updateState recomputes whether the cluster can serve requests: every
+// slot must be assigned to a node that isn't known to have failed
+func (c *Cluster) updateState() {
+	ok := true
+	for _, n := range c.slots {
+		if n == nil || n.flags&flagFail != 0 {
+			ok = false
+			break
+		}
+	}
+	if ok != c.ok {
+		if ok {
+			log.Println("Cluster state changed: ok")
+		} else {
+			log.Println("Cluster state changed: fail")
+		}
+		c.ok = ok
+	}
+}
+
+// size is the number of masters serving at least one slot, the voters on
+// node failures
+func (c *Cluster) size() int {
+	n := 0
+	for _, node := range c.nodes {
+		if node.flags&flagHandshake == 0 && node.slots.count() > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// SlotRange is a run of slots owned by the same node, for CLUSTER SLOTS
+type SlotRange struct {
+	Start, End int
+	ID         string
+	IP         string
+	Port       int
+}
+
+// SlotRanges returns the assigned slots grouped in runs
+func (c *Cluster) SlotRanges() []SlotRange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []SlotRange
+	for slot := 0; slot < Slots; slot++ {
+		n := c.slots[slot]
+		if n == nil {
+			continue
+		}
+		start := slot
+		for slot+1 < Slots && c.slots[slot+1] == n {
+			slot++
+		}
+		out = append(out, SlotRange{Start: start, End: slot, ID: n.id, IP: n.ip, Port: n.port})
+	}
+	return out
+}
+
+// Nodes returns the cluster as CLUSTER NODES describes it, one line per
+// node
+func (c *Cluster) Nodes() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.describe(false)
+}
+
+// describe renders the node lines, sorted by ID. The config file leaves
+// out nodes still in handshake.
+func (c *Cluster) describe(config bool) string {
+	var out string
+	for _, id := range slices.Sorted(maps.Keys(c.nodes)) {
+		n := c.nodes[id]
+		if config && n.flags&flagHandshake != 0 {
+			continue
+		}
+		if n == c.myself {
+			out += n.line(c.migrating, c.importing) + "\n"
+		} else {
+			out += n.line(nil, nil) + "\n"
+		}
+	}
+	return out
+}
+
+// Info is a point-in-time view of the cluster, reported by CLUSTER INFO
+type Info struct {
+	OK               bool
+	SlotsAssigned    int
+	SlotsOK          int
+	SlotsPFail       int
+	SlotsFail        int
+	KnownNodes       int
+	Size             int
+	CurrentEpoch     uint64
+	MyEpoch          uint64
+	MessagesSent     int64
+	MessagesReceived int64
+}
+
+// Info returns the current cluster state
+func (c *Cluster) Info() Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := Info{
+		OK:               c.ok,
+		KnownNodes:       len(c.nodes),
+		Size:             c.size(),
+		CurrentEpoch:     c.currentEpoch,
+		MyEpoch:          c.myself.configEpoch,
+		MessagesSent:     c.sent,
+		MessagesReceived: c.received,
+	}
+	for _, n := range c.slots {
+		switch {
+		case n == nil:
+			continue
+		case n.flags&flagFail != 0:
+			info.SlotsFail++
+		case n.flags&flagPFail != 0:
+			info.SlotsPFail++
+		default:
+			info.SlotsOK++
+		}
+		info.SlotsAssigned++
+	}
+	return info
+}
+
+func sortedSlots(m map[int]*node) []int {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The config file holds the CLUSTER NODES lines followed by a line of
+// variables, like Redis' nodes.conf:
+//
+//	<id> <ip:port@busport> <flags> - <ping-sent> <pong-recv> <config-epoch> <link-state> <slot>...
+//	vars currentEpoch <epoch>
+
+// save rewrites the config file with the current state
+func (c *Cluster) save() error {
+	if c.cfg.ConfigFile == "" {
+		return nil
+	}
+
+	tmp := c.cfg.ConfigFile + ".tmp"
+	content := c.describe(true) + fmt.Sprintf("vars currentEpoch %d\n", c.currentEpoch)
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.cfg.ConfigFile); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// load restores the state saved in the config file. Slot moves can only be
+// resolved once every node is known, so they are applied last.
+func (c *Cluster) load() error {
+	if c.cfg.ConfigFile == "" {
+		return os.ErrNotExist
+	}
+	f, err := os.Open(c.cfg.ConfigFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type move struct {
+		slot      int
+		importing bool
+		id        string
+	}
+	var moves []move
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "vars" {
+			for i := 1; i+1 < len(fields); i += 2 {
+				if fields[i] == "currentEpoch" {
+					c.currentEpoch, _ = strconv.ParseUint(fields[i+1], 10, 64)
+				}
+			}
+			continue
+		}
+
+		n, slots, err := parseNodeLine(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		c.nodes[n.id] = n
+		if n.flags&flagMyself != 0 {
+			c.myself = n
+		}
+
+		for _, s := range slots {
+			if rest, ok := strings.CutPrefix(s, "["); ok {
+				// [slot->-id] or [slot-<-id]
+				rest = strings.TrimSuffix(rest, "]")
+				slot, id, importing := strings.Cut(rest, "-<-")
+				if !importing {
+					slot, id, _ = strings.Cut(rest, "->-")
+				}
+				num, err := parseSlot(slot)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				moves = append(moves, move{num, importing, id})
+				continue
+			}
+
+			first, last, isRange := strings.Cut(s, "-")
+			start, err := parseSlot(first)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			end := start
+			if isRange {
+				if end, err = parseSlot(last); err != nil {
+					return fmt.Errorf("line %d: %w", lineNo, err)
+				}
+			}
+			for slot := start; slot <= end; slot++ {
+				c.setOwner(slot, n)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if c.myself == nil {
+		return fmt.Errorf("no node flagged myself")
+	}
+
+	for _, m := range moves {
+		n := c.nodes[m.id]
+		if n == nil {
+			continue
+		}
+		if m.importing {
+			c.importing[m.slot] = n
+		} else {
+			c.migrating[m.slot] = n
+		}
+	}
+	return nil
+}
+
+// parseNodeLine parses a CLUSTER NODES line, returning the node and its
+// slot fields
+func parseNodeLine(fields []string) (*node, []string, error) {
+	if len(fields) < 8 {
+		return nil, nil, fmt.Errorf("invalid node line")
+	}
+
+	addr, bus, _ := strings.Cut(fields[1], "@")
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid node address %q", fields[1])
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid node address %q", fields[1])
+	}
+	busPort, err := strconv.Atoi(bus)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid node address %q", fields[1])
+	}
+
+	var flags nodeFlags
+	for _, flag := range strings.Split(fields[2], ",") {
+		switch flag {
+		case "myself":
+			flags |= flagMyself
+		case "fail":
+			flags |= flagFail
+		}
+	}
+
+	n := newNode(fields[0], host, port, busPort, flags)
+	if n.configEpoch, err = strconv.ParseUint(fields[6], 10, 64); err != nil {
+		return nil, nil, fmt.Errorf("invalid config epoch %q", fields[6])
+	}
+	return n, fields[8:], nil
+}
+
+func parseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= Slots {
+		return 0, fmt.Errorf("invalid slot %q", s)
+	}
+	return slot, nil
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nodeFlags describe what this node knows about another node
+type nodeFlags uint8
+
+const (
+	flagMyself    nodeFlags = 1 << iota // the node is this server
+	flagPFail                           // not answering, failure not agreed yet
+	flagFail                            // a majority of masters agree it is down
+	flagHandshake                       // met by address, real ID not known yet
+	flagMeet                            // the next message sent must be a MEET
+)
+
+// node is a member of the cluster as this server sees it. All fields are
+// guarded by the Cluster's mutex.
+type node struct {
+	id          string
+	ip          string
+	port        int
+	busPort     int
+	flags       nodeFlags
+	configEpoch uint64
+	slots       slotSet // the slots the node owns in this server's table
+	created     time.Time
+
+	pingSent time.Time // when the unanswered PING was sent, zero if none
+	pongRecv time.Time
+
+	// failReports holds when each master last gossiped the node as failing
+	failReports map[string]time.Time
+
+	link       *link // outgoing bus connection, nil while disconnected
+	connecting bool
+}
+
+func newNode(id, ip string, port, busPort int, flags nodeFlags) *node {
+	return &node{
+		id:          id,
+		ip:          ip,
+		port:        port,
+		busPort:     busPort,
+		flags:       flags,
+		created:     time.Now(),
+		failReports: make(map[string]time.Time),
+	}
+}
+
+// newNodeID returns a random 40 character node ID
+func newNodeID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// addr is the address clients reach the node at
+func (n *node) addr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.port))
+}
+
+func (n *node) busAddr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.busPort))
+}
+
+func (n *node) failing() bool {
+	return n.flags&(flagPFail|flagFail) != 0
+}
+
+// flagString renders the flags like CLUSTER NODES does
+func (n *node) flagString() string {
+	var flags []string
+	if n.flags&flagMyself != 0 {
+		flags = append(flags, "myself")
+	}
+	if n.flags&flagHandshake != 0 {
+		flags = append(flags, "handshake")
+	} else {
+		flags = append(flags, "master")
+	}
+	if n.flags&flagPFail != 0 {
+		flags = append(flags, "fail?")
+	}
+	if n.flags&flagFail != 0 {
+		flags = append(flags, "fail")
+	}
+	return strings.Join(flags, ",")
+}
+
+// line renders the node as a CLUSTER NODES line, which is also the format of
+// the cluster config file. migrating and importing are the slots this
+// server moves, only listed on its own line.
+func (n *node) line(migrating, importing map[int]*node) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s@%d %s - %d %d %d ", n.id, n.addr(), n.busPort, n.flagString(),
+		unixMilli(n.pingSent), unixMilli(n.pongRecv), n.configEpoch)
+	if n.link != nil || n.flags&flagMyself != 0 {
+		b.WriteString("connected")
+	} else {
+		b.WriteString("disconnected")
+	}
+
+	for _, r := range n.slots.ranges() {
+		if r.start == r.end {
+			fmt.Fprintf(&b, " %d", r.start)
+		} else {
+			fmt.Fprintf(&b, " %d-%d", r.start, r.end)
+		}
+	}
+	for _, slot := range sortedSlots(migrating) {
+		fmt.Fprintf(&b, " [%d->-%s]", slot, migrating[slot].id)
+	}
+	for _, slot := range sortedSlots(importing) {
+		fmt.Fprintf(&b, " [%d-<-%s]", slot, importing[slot].id)
+	}
+	return b.String()
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import "strings"
+
+// Slots is the number of hash slots the keyspace is split into
+const Slots = 16384
+
+// KeySlot returns the hash slot of a key: CRC16 of the key modulo Slots.
+// When the key has a non-empty {hashtag}, only the tag is hashed, so
+// related keys like {user:1}:name and {user:1}:age share a slot.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % Slots)
+}
+
+// crc16 is CRC-16/XMODEM (polynomial 0x1021, initial value 0), the variant
+// Redis Cluster uses
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// slotSet is a bitmap of slots
+type slotSet [Slots / 8]byte
+
+func (s *slotSet) has(slot int) bool {
+	return s[slot/8]&(1<<(slot%8)) != 0
+}
+
+func (s *slotSet) set(slot int) {
+	s[slot/8] |= 1 << (slot % 8)
+}
+
+func (s *slotSet) clear(slot int) {
+	s[slot/8] &^= 1 << (slot % 8)
+}
+
+func (s *slotSet) count() int {
+	n := 0
+	for slot := range Slots {
+		if s.has(slot) {
+			n++
+		}
+	}
+	return n
+}
+
+// slotRange is a run of consecutive slots, both ends included
+type slotRange struct {
+	start, end int
+}
+
+// ranges returns the slots of the set as sorted runs
+func (s *slotSet) ranges() []slotRange {
+	var out []slotRange
+	for slot := 0; slot < Slots; slot++ {
+		if !s.has(slot) {
+			continue
+		}
+		start := slot
+		for slot+1 < Slots && s.has(slot+1) {
+			slot++
+		}
+		out = append(out, slotRange{start, slot})
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package commands
+
+import "github.com/Eahtasham/go-redis/internal/protocol/resp"
+
+// Apply runs a command from the master's replication stream, without a
+// client. It skips the write guard, which keeps clients of a replica from
+// writing, and is logged to the AOF like any other write. after runs once
+// the command did, still under the execution lock, so nothing that takes
+// the lock exclusively sees the command without it.
+func Apply(v resp.Value, after func()) resp.Value {
+	execMu.RLock()
+	reply, mark := dispatch(v, nil)
+	after()
+	execMu.RUnlock()
+
+	return waitSynced(mark, reply)
+}
+
+// ApplyTxn runs the commands of a MULTI ... EXEC block from the master's
+// replication stream as one transaction, see Apply
+func ApplyTxn(vs []resp.Value, after func()) {
+	execMu.Lock()
+	if execHooks.begin != nil {
+		execHooks.begin()
+	}
+	for _, v := range vs {
+		dispatch(v, nil)
+	}
+	if execHooks.end != nil {
+		execHooks.end()
+	}
+	mark := syncMark()
+	after()
+	execMu.Unlock()
+
+	waitSynced(mark, resp.Value{})
+}
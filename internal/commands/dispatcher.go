@@ -1,20 +1,191 @@
 package commands
 
-import "github.com/Eahtasham/go-redis/internal/protocol/resp"
+import (
+	"strings"
+	"sync"
+
+	"github.com/Eahtasham/go-redis/internal/engine/pubsub"
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+	"github.com/Eahtasham/go-redis/internal/replication"
+)
+
+// NoReply is returned by handlers that already wrote their replies through
+// ClientContext.Push, e.g. SUBSCRIBE sends one confirmation per channel
+var NoReply = resp.Value{}
+
+// execMu isolates transactions: every command holds it shared while it runs
+// and EXEC holds it exclusively, so no client can observe or interleave with
+// a transaction half-way
+var execMu sync.RWMutex
+
+// writeMu runs write commands one at a time, under execMu. A write reaches
+// the AOF and the replicas before the next one changes the store, so both
+// see writes to a key in the order the store applied them. Waiting for the
+// fsync happens after it is released, see SetSyncHooks.
+var writeMu sync.Mutex
+
+// Commands accepted while a client is in subscriber mode, QUIT is handled
+// by the connection layer
+var subscriberCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+}
 
 // ClientContext holds per-client state for features like transactions
 type ClientContext struct {
 	InTxn   bool         // true when inside a MULTI transaction
 	TxQueue []resp.Value // queued commands during a transaction
+	TxDirty bool         // a command was rejected while queueing, EXEC must abort
+
+	// Closed is closed by the connection layer once the client goes away,
+	// so blocked commands can give up waiting
+	Closed <-chan struct{}
+
+	// Push writes an extra reply straight to the client
+	Push func(resp.Value) error
+
+	// Flush sends the replies the connection buffered for earlier pipelined
+	// commands, so a client doesn't wait for them while a command blocks
+	Flush func() error
+
+	// Sub is the client's pub/sub state, created on first SUBSCRIBE
+	Sub *pubsub.Subscriber
+
+	// Watch holds the keys WATCHed for the next EXEC, created on first WATCH
+	Watch *store.Watch
+
+	// Addr is the client's remote address
+	Addr string
+
+	// ListeningPort is the port a replica announced with REPLCONF
+	ListeningPort int
+
+	// Replica is set once PSYNC turned the client into a replica, the
+	// connection then carries the replication stream
+	Replica *replication.Replica
+
+	// Asking is set by ASKING, letting the next command use a cluster slot
+	// this node is importing
+	Asking bool
+
+	// ID identifies the connection, as HELLO reports it
+	ID int64
+
+	// Protocol is the RESP version replies are written in, 2 until HELLO
+	// switches the connection to 3
+	Protocol int
+
+	// Name is the client name set with HELLO SETNAME
+	Name string
+
+	inExec  bool // true while EXEC runs the queued commands
+	writing bool // true while a write command holds writeMu
+}
+
+// Live reports whether the command runs directly for a connected client,
+// rather than during AOF replay or as part of a transaction
+func (ctx *ClientContext) Live() bool {
+	return ctx != nil && ctx.Closed != nil && !ctx.InTxn && !ctx.inExec
+}
+
+// CanBlock reports whether a command may park this client. Blocking is not
+// allowed inside transactions or without a live connection (AOF replay).
+func (ctx *ClientContext) CanBlock() bool {
+	return ctx.Live()
+}
+
+// Exclusive runs fn while no command is executing, giving it a consistent
+// point-in-time view of the dataset. Must not be called from a handler.
+func Exclusive(fn func()) {
+	execMu.Lock()
+	defer execMu.Unlock()
+	fn()
+}
+
+// Exclusive runs fn while no other command is executing, for commands such
+// as SAVE that need a consistent view of the whole dataset. Inside EXEC the
+// lock is already held exclusively.
+func (ctx *ClientContext) Exclusive(fn func()) {
+	if ctx.inExec {
+		fn()
+		return
+	}
+
+	ctx.unlock()
+	defer ctx.relock()
+	Exclusive(fn)
+}
+
+// WaitUnlocked runs wait without holding the execution lock, so a client
+// parked in a blocking command or waiting on another server doesn't hold up
+// other clients' transactions or writes.
+// Pending replies are flushed first. Only valid when CanBlock reports true.
+func (ctx *ClientContext) WaitUnlocked(wait func()) {
+	if ctx.Flush != nil {
+		ctx.Flush()
+	}
+	ctx.unlock()
+	defer ctx.relock()
+	wait()
+}
+
+// unlock releases the locks the running command holds, relock takes them
+// back in the same order dispatch took them
+func (ctx *ClientContext) unlock() {
+	if ctx.writing {
+		writeMu.Unlock()
+	}
+	execMu.RUnlock()
+}
+
+func (ctx *ClientContext) relock() {
+	execMu.RLock()
+	if ctx.writing {
+		writeMu.Lock()
+	}
+}
+
+// Close releases the client's subscriptions, watched keys and replica
+// state, called by the connection layer on disconnect
+func (ctx *ClientContext) Close() {
+	if ctx.Sub != nil {
+		ctx.Sub.Close()
+	}
+	if ctx.Replica != nil {
+		ctx.Replica.Close()
+	}
+	ctx.unwatch()
+}
+
+func (ctx *ClientContext) unwatch() {
+	if ctx.Watch != nil {
+		ctx.Watch.Reset()
+	}
 }
 
-func Dispatch(v resp.Value) resp.Value {
+// Subscribed reports whether the client is in pub/sub subscriber mode
+func (ctx *ClientContext) Subscribed() bool {
+	return ctx != nil && ctx.Sub != nil && ctx.Sub.Count() > 0
+}
+
+// RESP3 reports whether the client switched to RESP3 with HELLO
+func (ctx *ClientContext) RESP3() bool {
+	return ctx != nil && ctx.Protocol >= 3
+}
+
+// dispatch runs a command. For a write it also returns the mark to pass to
+// waitSynced once the caller released its locks, 0 otherwise.
+func dispatch(v resp.Value, ctx *ClientContext) (resp.Value, int64) {
 	cmd, err := Parse(v)
 	if err != nil {
 		return resp.Value{
 			Type: resp.Error,
 			Str:  err.Error(),
-		}
+		}, 0
 	}
 
 	handler, ok := Get(cmd.Name)
@@ -22,11 +193,20 @@ func Dispatch(v resp.Value) resp.Value {
 		return resp.Value{
 			Type: resp.Error,
 			Str:  "ERR unknown command '" + cmd.Name + "'",
-		}
+		}, 0
 	}
 
-	return handler(cmd.Args)
+	if !IsWrite(cmd.Name) {
+		return handler(ctx, cmd.Args), 0
+	}
 
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if ctx != nil {
+		ctx.writing = true
+		defer func() { ctx.writing = false }()
+	}
+	return handler(ctx, cmd.Args), syncMark()
 }
 
 func DispatchWithContext(v resp.Value, ctx *ClientContext) resp.Value {
@@ -35,47 +215,165 @@ func DispatchWithContext(v resp.Value, ctx *ClientContext) resp.Value {
 		return resp.ErrorValue("ERR invalid command")
 	}
 
+	if errValue, ok := checkLoading(cmd.Name); !ok {
+		return errValue
+	}
+
+	// ASKING only applies to the command right after it
+	asking := ctx.Asking || handlers[cmd.Name].flags&FlagAsking != 0
+	ctx.Asking = false
+
+	// A subscribed client may only manage its subscriptions, unless it
+	// speaks RESP3 where messages are pushes that can't be mistaken for
+	// replies
+	if ctx.Subscribed() && !ctx.RESP3() && !subscriberCommands[cmd.Name] {
+		return resp.ErrorValue("ERR Can't execute '" + strings.ToLower(cmd.Name) +
+			"': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+	}
+
 	switch cmd.Name {
 	case "MULTI":
+		if ctx.InTxn {
+			return resp.ErrorValue("ERR MULTI calls can not be nested")
+		}
 		ctx.InTxn = true
 		ctx.TxQueue = nil
+		ctx.TxDirty = false
 		return resp.SimpleValue("OK")
 
 	case "DISCARD":
+		if !ctx.InTxn {
+			return resp.ErrorValue("ERR DISCARD without MULTI")
+		}
 		ctx.InTxn = false
 		ctx.TxQueue = nil
+		ctx.unwatch()
 		return resp.SimpleValue("OK")
 
 	case "EXEC":
 		if !ctx.InTxn {
 			return resp.ErrorValue("ERR EXEC without MULTI")
 		}
-		return execTransaction(ctx)
+
+		// A command was rejected at queue time: run nothing
+		if ctx.TxDirty {
+			ctx.InTxn = false
+			ctx.TxQueue = nil
+			ctx.unwatch()
+			return resp.ErrorValue("EXECABORT Transaction discarded because of previous errors.")
+		}
+
+		// Writes may have become refused since they were queued
+		for _, queued := range ctx.TxQueue {
+			queuedCmd, _ := Parse(queued)
+			if errValue, ok := checkWrite(queuedCmd.Name); !ok {
+				ctx.InTxn = false
+				ctx.TxQueue = nil
+				ctx.unwatch()
+				return errValue
+			}
+		}
+
+		execMu.Lock()
+
+		// A watched key changed since WATCH: abort with a null reply.
+		// Checked under the lock so nothing can change it before we run.
+		if ctx.Watch != nil && ctx.Watch.Dirty() {
+			execMu.Unlock()
+			ctx.InTxn = false
+			ctx.TxQueue = nil
+			ctx.unwatch()
+			return resp.NullArrayValue()
+		}
+
+		// Keys are unwatched before running, like Redis does
+		ctx.unwatch()
+		reply, mark := execTransaction(ctx)
+		execMu.Unlock()
+
+		return waitSynced(mark, reply)
+
+	case "WATCH":
+		if ctx.InTxn {
+			ctx.TxDirty = true
+			return resp.ErrorValue("ERR WATCH inside MULTI is not allowed")
+		}
 	}
 
 	// normal command
 	if ctx.InTxn {
+		// Unknown commands and bad arity are caught now, not inside EXEC
+		if errValue, ok := validate(cmd); !ok {
+			ctx.TxDirty = true
+			return errValue
+		}
+		if errValue, ok := checkWrite(cmd.Name); !ok {
+			ctx.TxDirty = true
+			return errValue
+		}
+		if errValue, ok := checkRoute(cmd, asking); !ok {
+			ctx.TxDirty = true
+			return errValue
+		}
+
 		ctx.TxQueue = append(ctx.TxQueue, v)
 		return resp.SimpleValue("QUEUED")
 	}
 
-	return Dispatch(v)
+	if errValue, ok := checkWrite(cmd.Name); !ok {
+		return errValue
+	}
+
+	execMu.RLock()
+
+	// Routed under the lock, so the keys checked are the keys the command
+	// finds
+	if errValue, ok := checkRoute(cmd, asking); !ok {
+		execMu.RUnlock()
+		return errValue
+	}
+
+	reply, mark := dispatch(v, ctx)
+	execMu.RUnlock()
+
+	return waitSynced(mark, reply)
 }
 
-func execTransaction(ctx *ClientContext) resp.Value {
+// execTransaction runs the queued commands of an EXEC, returning the mark
+// to wait on when any of them wrote, see dispatch
+func execTransaction(ctx *ClientContext) (resp.Value, int64) {
 	ctx.InTxn = false
+	ctx.inExec = true
+	defer func() { ctx.inExec = false }()
 
 	results := make([]resp.Value, 0, len(ctx.TxQueue))
 
+	// Let persistence record the whole block as one unit
+	if execHooks.begin != nil {
+		execHooks.begin()
+	}
+
+	wrote := false
 	for _, v := range ctx.TxQueue {
-		res := Dispatch(v)
+		res, _ := dispatch(v, ctx)
 		results = append(results, res)
+		if cmd, err := Parse(v); err == nil && IsWrite(cmd.Name) {
+			wrote = true
+		}
+	}
+
+	if execHooks.end != nil {
+		execHooks.end()
 	}
 
 	ctx.TxQueue = nil
 
+	var mark int64
+	if wrote {
+		mark = syncMark()
+	}
 	return resp.Value{
 		Type:  resp.Array,
 		Array: results,
-	}
+	}, mark
 }
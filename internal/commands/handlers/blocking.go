@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// serveBlocked wakes clients blocked on key now that it received elements,
+// logging what they popped right after the push that fed them
+func serveBlocked(key string) {
+	for _, pop := range Store.ServeListWaiters(key) {
+		logListPop(pop)
+	}
+}
+
+// logListPop logs a served pop or move in its non-blocking form
+func logListPop(pop store.ListPop) {
+	switch {
+	case pop.Dest != "":
+		logCommand("LMOVE", pop.Key, pop.Dest, listSideName(pop.FromLeft), listSideName(pop.ToLeft))
+	case pop.FromLeft:
+		logCommand("LPOP", pop.Key)
+	default:
+		logCommand("RPOP", pop.Key)
+	}
+}
+
+// parseTimeout parses a blocking timeout in seconds, 0 means forever
+func parseTimeout(arg string) (time.Duration, resp.Value, bool) {
+	seconds, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, resp.ErrorValue("ERR timeout is not a float or out of range"), false
+	}
+	if seconds < 0 {
+		return 0, resp.ErrorValue("ERR timeout is negative"), false
+	}
+	return time.Duration(seconds * float64(time.Second)), resp.Value{}, true
+}
+
+// waitForList parks the client until w is served, the timeout expires or
+// the connection is closed. It returns false if nothing was received.
+func waitForList(ctx *commands.ClientContext, w *store.ListWaiter, timeout time.Duration) (store.ListPop, bool) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var pop store.ListPop
+	served := false
+	ctx.WaitUnlocked(func() {
+		select {
+		case pop = <-w.C():
+			served = true
+		case <-expired:
+		case <-ctx.Closed:
+		}
+	})
+	if served {
+		return pop, true
+	}
+
+	if Store.CancelWait(w) {
+		return store.ListPop{}, false
+	}
+
+	// Served while we were giving up, the element is already ours
+	return <-w.C(), true
+}
+
+// BLPOP key [key ...] timeout
+// Pop from the head of the first non-empty list, blocking until one has data
+func BLPop(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'blpop' command")
+	}
+	return blockingPop(ctx, args, true)
+}
+
+// BRPOP key [key ...] timeout
+// Pop from the tail of the first non-empty list, blocking until one has data
+func BRPop(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'brpop' command")
+	}
+	return blockingPop(ctx, args, false)
+}
+
+func blockingPop(ctx *commands.ClientContext, args []string, fromLeft bool) resp.Value {
+	keys := args[:len(args)-1]
+	timeout, errValue, ok := parseTimeout(args[len(args)-1])
+	if !ok {
+		return errValue
+	}
+
+	pop, w, err := Store.PopOrWait(keys, fromLeft, ctx.CanBlock())
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if pop != nil {
+		logListPop(*pop)
+	} else if w != nil {
+		// Pops served while parked were logged by the pushing client
+		served, ok := waitForList(ctx, w, timeout)
+		if !ok {
+			return resp.NullArrayValue()
+		}
+		pop = &served
+	} else {
+		return resp.NullArrayValue()
+	}
+
+	return resp.ArrayValue([]resp.Value{
+		resp.BulkValue(pop.Key),
+		resp.BulkValue(pop.Value),
+	})
+}
+
+// BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+// Blocking variant of LMOVE
+func BLMove(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) != 5 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'blmove' command")
+	}
+
+	fromLeft, ok1 := parseListSide(args[2])
+	toLeft, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	return blockingMove(ctx, args[0], args[1], fromLeft, toLeft, args[4])
+}
+
+// BRPOPLPUSH source destination timeout
+// Same as BLMOVE source destination RIGHT LEFT timeout
+func BRPopLPush(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'brpoplpush' command")
+	}
+
+	return blockingMove(ctx, args[0], args[1], false, true, args[2])
+}
+
+func blockingMove(ctx *commands.ClientContext, src, dest string, fromLeft, toLeft bool, timeoutArg string) resp.Value {
+	timeout, errValue, ok := parseTimeout(timeoutArg)
+	if !ok {
+		return errValue
+	}
+
+	pop, w, err := Store.MoveOrWait(src, dest, fromLeft, toLeft, ctx.CanBlock())
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if pop != nil {
+		logListPop(*pop)
+		serveBlocked(dest)
+	} else if w != nil {
+		served, ok := waitForList(ctx, w, timeout)
+		if !ok {
+			return resp.NullBulkValue()
+		}
+		if served.Err != nil {
+			return resp.ErrorValue(served.Err.Error())
+		}
+		pop = &served
+	} else {
+		return resp.NullBulkValue()
+	}
+
+	return resp.BulkValue(pop.Value)
+}
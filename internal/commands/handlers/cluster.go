@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Eahtasham/go-redis/internal/cluster"
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// Cluster is the cluster state, nil unless the server runs in cluster mode
+var Cluster *cluster.Cluster
+
+var errClusterDisabled = errors.New("ERR This instance has cluster support disabled")
+
+// InitCluster turns on cluster mode: commands from clients are routed to
+// the node owning their keys
+func InitCluster(c *cluster.Cluster) {
+	Cluster = c
+	commands.SetRouter(routeKeys)
+}
+
+func routeKeys(keys []string, asking bool) error {
+	return Cluster.Route(keys, asking, func(key string) bool {
+		_, ok := Store.Get(key)
+		return ok
+	})
+}
+
+// keysInSlot returns up to limit keys of a slot stored here, all of them
+// when limit is 0
+func keysInSlot(slot, limit int) []string {
+	return Store.Keys(func(key string) bool {
+		return cluster.KeySlot(key) == slot
+	}, limit)
+}
+
+// CLUSTER subcommand [arg ...]
+// Inspect and configure the cluster
+func ClusterCommand(args []string) resp.Value {
+	if Cluster == nil {
+		return resp.ErrorValue(errClusterDisabled.Error())
+	}
+
+	switch sub := strings.ToUpper(args[0]); {
+	case sub == "MYID" && len(args) == 1:
+		return resp.BulkValue(Cluster.MyID())
+
+	case sub == "KEYSLOT" && len(args) == 2:
+		return resp.IntValue(int64(cluster.KeySlot(args[1])))
+
+	case sub == "INFO" && len(args) == 1:
+		return resp.VerbatimValue("txt", clusterInfo())
+
+	case sub == "NODES" && len(args) == 1:
+		return resp.VerbatimValue("txt", Cluster.Nodes())
+
+	case sub == "SLOTS" && len(args) == 1:
+		ranges := Cluster.SlotRanges()
+		result := make([]resp.Value, len(ranges))
+		for i, r := range ranges {
+			result[i] = resp.ArrayValue([]resp.Value{
+				resp.IntValue(int64(r.Start)),
+				resp.IntValue(int64(r.End)),
+				resp.ArrayValue([]resp.Value{
+					resp.BulkValue(r.IP),
+					resp.IntValue(int64(r.Port)),
+					resp.BulkValue(r.ID),
+				}),
+			})
+		}
+		return resp.ArrayValue(result)
+
+	case sub == "ADDSLOTS" && len(args) >= 2:
+		slots := make([]int, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			slot, err := parseSlot(arg)
+			if err != nil {
+				return resp.ErrorValue(err.Error())
+			}
+			slots = append(slots, slot)
+		}
+		return clusterReply(Cluster.AddSlots(slots))
+
+	case sub == "ADDSLOTSRANGE" && len(args) >= 3 && len(args)%2 == 1:
+		var slots []int
+		for i := 1; i < len(args); i += 2 {
+			start, err := parseSlot(args[i])
+			if err != nil {
+				return resp.ErrorValue(err.Error())
+			}
+			end, err := parseSlot(args[i+1])
+			if err != nil {
+				return resp.ErrorValue(err.Error())
+			}
+			if start > end {
+				return resp.ErrorValue(fmt.Sprintf("ERR start slot number %d is greater than end slot number %d", start, end))
+			}
+			for slot := start; slot <= end; slot++ {
+				slots = append(slots, slot)
+			}
+		}
+		return clusterReply(Cluster.AddSlots(slots))
+
+	case sub == "MEET" && (len(args) == 3 || len(args) == 4):
+		port, err := strconv.Atoi(args[2])
+		if err != nil {
+			return resp.ErrorValue("ERR Invalid base port specified: " + args[2])
+		}
+		busPort := port + 10000
+		if len(args) == 4 {
+			if busPort, err = strconv.Atoi(args[3]); err != nil {
+				return resp.ErrorValue("ERR Invalid bus port specified: " + args[3])
+			}
+		}
+		return clusterReply(Cluster.Meet(args[1], port, busPort))
+
+	case sub == "SETSLOT" && len(args) >= 3:
+		slot, err := parseSlot(args[1])
+		if err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		action := strings.ToUpper(args[2])
+		id := ""
+		switch {
+		case action == "STABLE" && len(args) == 3:
+		case action != "STABLE" && len(args) == 4:
+			id = args[3]
+		default:
+			return resp.ErrorValue("ERR Invalid CLUSTER SETSLOT action or number of arguments. Try CLUSTER HELP")
+		}
+		return clusterReply(Cluster.SetSlot(slot, action, id, func(slot int) int {
+			return len(keysInSlot(slot, 1))
+		}))
+
+	case sub == "COUNTKEYSINSLOT" && len(args) == 2:
+		slot, err := parseSlot(args[1])
+		if err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		return resp.IntValue(int64(len(keysInSlot(slot, 0))))
+
+	case sub == "GETKEYSINSLOT" && len(args) == 3:
+		slot, err := parseSlot(args[1])
+		if err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count < 0 {
+			return resp.ErrorValue("ERR Invalid number of keys")
+		}
+		if count == 0 {
+			return resp.ArrayValue([]resp.Value{})
+		}
+		keys := keysInSlot(slot, count)
+		result := make([]resp.Value, len(keys))
+		for i, key := range keys {
+			result[i] = resp.BulkValue(key)
+		}
+		return resp.ArrayValue(result)
+	}
+
+	return resp.ErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0] + "'. Try CLUSTER HELP.")
+}
+
+// ASKING
+// Let the next command use a slot this node is importing
+func Asking(ctx *commands.ClientContext, args []string) resp.Value {
+	if Cluster == nil {
+		return resp.ErrorValue(errClusterDisabled.Error())
+	}
+	ctx.Asking = true
+	return resp.SimpleValue("OK")
+}
+
+func parseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= cluster.Slots {
+		return 0, errors.New("ERR Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+func clusterReply(err error) resp.Value {
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	return resp.SimpleValue("OK")
+}
+
+// clusterInfo formats CLUSTER INFO, in the same field:value lines as INFO
+func clusterInfo() string {
+	info := Cluster.Info()
+	state := "fail"
+	if info.OK {
+		state = "ok"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "cluster_enabled:1\r\n")
+	fmt.Fprintf(&b, "cluster_state:%s\r\n", state)
+	fmt.Fprintf(&b, "cluster_slots_assigned:%d\r\n", info.SlotsAssigned)
+	fmt.Fprintf(&b, "cluster_slots_ok:%d\r\n", info.SlotsOK)
+	fmt.Fprintf(&b, "cluster_slots_pfail:%d\r\n", info.SlotsPFail)
+	fmt.Fprintf(&b, "cluster_slots_fail:%d\r\n", info.SlotsFail)
+	fmt.Fprintf(&b, "cluster_known_nodes:%d\r\n", info.KnownNodes)
+	fmt.Fprintf(&b, "cluster_size:%d\r\n", info.Size)
+	fmt.Fprintf(&b, "cluster_current_epoch:%d\r\n", info.CurrentEpoch)
+	fmt.Fprintf(&b, "cluster_my_epoch:%d\r\n", info.MyEpoch)
+	fmt.Fprintf(&b, "cluster_stats_messages_sent:%d\r\n", info.MessagesSent)
+	fmt.Fprintf(&b, "cluster_stats_messages_received:%d\r\n", info.MessagesReceived)
+	return b.String()
+}
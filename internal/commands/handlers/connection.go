@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// serverVersion is the Redis version whose commands and protocol the server
+// follows, as HELLO reports it
+const serverVersion = "7.2.0"
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+// Switch the connection to RESP2 or RESP3 and describe the server. There
+// are no passwords, so AUTH accepts any password for the default user.
+func Hello(ctx *commands.ClientContext, args []string) resp.Value {
+	proto := ctx.Protocol
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return resp.ErrorValue("ERR Protocol version is not an integer or out of range")
+		}
+		if n != 2 && n != 3 {
+			return resp.ErrorValue("NOPROTO unsupported protocol version")
+		}
+		proto = n
+	}
+
+	name, setName := "", false
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "AUTH" && i+2 < len(args):
+			if args[i+1] != "default" {
+				return resp.ErrorValue("WRONGPASS invalid username-password pair or user is disabled.")
+			}
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			name, setName = args[i+1], true
+			if strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r > '~' }) {
+				return resp.ErrorValue("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			i++
+		default:
+			return resp.ErrorValue("ERR Syntax error in HELLO option '" + args[i] + "'")
+		}
+	}
+
+	// Only switch once every option checked out
+	ctx.Protocol = proto
+	if setName {
+		ctx.Name = name
+	}
+
+	mode := "standalone"
+	if Cluster != nil {
+		mode = "cluster"
+	}
+	role := "master"
+	if currentLink() != nil {
+		role = "replica"
+	}
+
+	return resp.MapValue([]resp.Value{
+		resp.BulkValue("server"), resp.BulkValue("redis"),
+		resp.BulkValue("version"), resp.BulkValue(serverVersion),
+		resp.BulkValue("proto"), resp.IntValue(int64(proto)),
+		resp.BulkValue("id"), resp.IntValue(ctx.ID),
+		resp.BulkValue("mode"), resp.BulkValue(mode),
+		resp.BulkValue("role"), resp.BulkValue(role),
+		resp.BulkValue("modules"), resp.ArrayValue([]resp.Value{}),
+	})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// HSET key field value [field value ...]
+// Set one or more fields in a hash
+func HSet(args []string) resp.Value {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hset' command")
+	}
+
+	key := args[0]
+	added, err := Store.HSet(key, args[1:])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	// Log to AOF
+	logCommand("HSET", args...)
+
+	return resp.IntValue(added)
+}
+
+// HSETNX key field value
+// Set a field only if it does not exist yet
+func HSetNX(args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hsetnx' command")
+	}
+
+	set, err := Store.HSetNX(args[0], args[1], args[2])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if !set {
+		return resp.IntValue(0)
+	}
+
+	// Log as a plain HSET, the field is known to be new at this point
+	logCommand("HSET", args...)
+
+	return resp.IntValue(1)
+}
+
+// HGET key field
+// Get the value of a field
+func HGet(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hget' command")
+	}
+
+	value, exists, err := Store.HGet(args[0], args[1])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if !exists {
+		return resp.NullBulkValue()
+	}
+
+	return resp.BulkValue(value)
+}
+
+// HMGET key field [field ...]
+// Get the values of several fields, missing fields are returned as nil
+func HMGet(args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hmget' command")
+	}
+
+	values, found, err := Store.HMGet(args[0], args[1:])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	result := make([]resp.Value, len(values))
+	for i, v := range values {
+		if !found[i] {
+			result[i] = resp.NullBulkValue()
+			continue
+		}
+		result[i] = resp.BulkValue(v)
+	}
+
+	return resp.ArrayValue(result)
+}
+
+// HDEL key field [field ...]
+// Remove fields from a hash
+func HDel(args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hdel' command")
+	}
+
+	removed, err := Store.HDel(args[0], args[1:])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if removed > 0 {
+		logCommand("HDEL", args...)
+	}
+
+	return resp.IntValue(removed)
+}
+
+// HEXISTS key field
+// Check if a field exists in a hash
+func HExists(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hexists' command")
+	}
+
+	exists, err := Store.HExists(args[0], args[1])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if exists {
+		return resp.IntValue(1)
+	}
+	return resp.IntValue(0)
+}
+
+// HLEN key
+// Get the number of fields in a hash
+func HLen(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hlen' command")
+	}
+
+	length, err := Store.HLen(args[0])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	return resp.IntValue(length)
+}
+
+// HKEYS key
+// Get all field names of a hash
+func HKeys(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hkeys' command")
+	}
+
+	fields, err := Store.HKeys(args[0])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	result := make([]resp.Value, len(fields))
+	for i, field := range fields {
+		result[i] = resp.BulkValue(field)
+	}
+
+	return resp.ArrayValue(result)
+}
+
+// HVALS key
+// Get all values of a hash
+func HVals(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hvals' command")
+	}
+
+	values, err := Store.HVals(args[0])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	result := make([]resp.Value, len(values))
+	for i, value := range values {
+		result[i] = resp.BulkValue(value)
+	}
+
+	return resp.ArrayValue(result)
+}
+
+// HGETALL key
+// Get all fields and values of a hash as a flat field/value array
+func HGetAll(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hgetall' command")
+	}
+
+	hash, err := Store.HGetAll(args[0])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	result := make([]resp.Value, 0, len(hash)*2)
+	for field, value := range hash {
+		result = append(result, resp.BulkValue(field), resp.BulkValue(value))
+	}
+
+	return resp.MapValue(result)
+}
+
+// HINCRBY key field increment
+// Increment the integer value of a field
+func HIncrBy(args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hincrby' command")
+	}
+
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+
+	newVal, err := Store.HIncrBy(args[0], args[1], delta)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	// Log the resulting HSET command for idempotent replay
+	logCommand("HSET", args[0], args[1], strconv.FormatInt(newVal, 10))
+
+	return resp.IntValue(newVal)
+}
+
+// HINCRBYFLOAT key field increment
+// Increment the float value of a field
+func HIncrByFloat(args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hincrbyfloat' command")
+	}
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return resp.ErrorValue("ERR value is not a valid float")
+	}
+
+	newVal, err := Store.HIncrByFloat(args[0], args[1], delta)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	formatted := strconv.FormatFloat(newVal, 'f', -1, 64)
+
+	// Log the resulting HSET command for idempotent replay
+	logCommand("HSET", args[0], args[1], formatted)
+
+	return resp.BulkValue(formatted)
+}
+
+// HSTRLEN key field
+// Get the length of the value stored at a field
+func HStrLen(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hstrlen' command")
+	}
+
+	length, err := Store.HStrLen(args[0], args[1])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	return resp.IntValue(length)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// infoSections lists the INFO sections in output order
+var infoSections = []struct {
+	name  string
+	write func(b *strings.Builder)
+}{
+	{"persistence", infoPersistence},
+	{"replication", infoReplication},
+	{"cluster", infoCluster},
+	{"keyspace", infoKeyspace},
+}
+
+// INFO [section]
+// Return server information and statistics
+func Info(args []string) resp.Value {
+	if len(args) > 1 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	want := "default"
+	if len(args) == 1 {
+		want = strings.ToLower(args[0])
+	}
+
+	var b strings.Builder
+	for _, section := range infoSections {
+		if want != "default" && want != "all" && want != "everything" && want != section.name {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		section.write(&b)
+	}
+
+	return resp.VerbatimValue("txt", b.String())
+}
+
+func infoPersistence(b *strings.Builder) {
+	b.WriteString("# Persistence\r\n")
+
+	loading := 0
+	if commands.Loading() {
+		loading = 1
+	}
+	fmt.Fprintf(b, "loading:%d\r\n", loading)
+
+	rdb := RDB.Stats(Store.Changes())
+	saving, saveStatus := 0, "ok"
+	if rdb.Saving {
+		saving = 1
+	}
+	if rdb.LastSaveErr != nil {
+		saveStatus = "err"
+	}
+	fmt.Fprintf(b, "rdb_changes_since_last_save:%d\r\n", rdb.ChangesSinceSave)
+	fmt.Fprintf(b, "rdb_bgsave_in_progress:%d\r\n", saving)
+	fmt.Fprintf(b, "rdb_last_save_time:%d\r\n", rdb.LastSave.Unix())
+	fmt.Fprintf(b, "rdb_last_bgsave_status:%s\r\n", saveStatus)
+
+	if AOF == nil {
+		b.WriteString("aof_enabled:0\r\n")
+		return
+	}
+
+	stats := AOF.Stats()
+	rewriting := 0
+	if stats.Rewriting {
+		rewriting = 1
+	}
+	writeStatus := "ok"
+	if stats.LastWriteErr != nil {
+		writeStatus = "err"
+	}
+
+	fmt.Fprintf(b, "aof_enabled:1\r\n")
+	fmt.Fprintf(b, "aof_rewrite_in_progress:%d\r\n", rewriting)
+	fmt.Fprintf(b, "aof_fsync_policy:%s\r\n", stats.Fsync)
+	fmt.Fprintf(b, "aof_current_size:%d\r\n", stats.Size)
+	fmt.Fprintf(b, "aof_base_size:%d\r\n", stats.BaseSize)
+	fmt.Fprintf(b, "aof_last_fsync_time:%d\r\n", stats.LastFsync.Unix())
+	fmt.Fprintf(b, "aof_pending_bytes:%d\r\n", stats.PendingBytes)
+	fmt.Fprintf(b, "aof_backpressure_policy:%s\r\n", stats.Backpressure)
+	fmt.Fprintf(b, "aof_delayed_writes:%d\r\n", stats.DelayedWrites)
+	fmt.Fprintf(b, "aof_refused_writes:%d\r\n", stats.RefusedWrites)
+	fmt.Fprintf(b, "aof_write_errors:%d\r\n", stats.WriteErrors)
+	fmt.Fprintf(b, "aof_last_write_status:%s\r\n", writeStatus)
+}
+
+func infoReplication(b *strings.Builder) {
+	b.WriteString("# Replication\r\n")
+
+	stats := Repl.Stats()
+	if l := currentLink(); l != nil {
+		ls := l.Stats()
+		host, port, _ := net.SplitHostPort(ls.Addr)
+		linkStatus, syncing, lastIO := "down", 0, -1
+		if ls.Up {
+			linkStatus = "up"
+		}
+		if ls.Syncing {
+			syncing = 1
+		}
+		if !ls.LastIO.IsZero() {
+			lastIO = int(time.Since(ls.LastIO).Seconds())
+		}
+
+		b.WriteString("role:slave\r\n")
+		fmt.Fprintf(b, "master_host:%s\r\n", host)
+		fmt.Fprintf(b, "master_port:%s\r\n", port)
+		fmt.Fprintf(b, "master_link_status:%s\r\n", linkStatus)
+		fmt.Fprintf(b, "master_last_io_seconds_ago:%d\r\n", lastIO)
+		fmt.Fprintf(b, "master_sync_in_progress:%d\r\n", syncing)
+		fmt.Fprintf(b, "slave_repl_offset:%d\r\n", stats.Offset)
+	} else {
+		b.WriteString("role:master\r\n")
+	}
+
+	fmt.Fprintf(b, "connected_slaves:%d\r\n", len(stats.Replicas))
+	for i, r := range stats.Replicas {
+		host, _, _ := net.SplitHostPort(r.Addr)
+		fmt.Fprintf(b, "slave%d:ip=%s,port=%d,state=%s,offset=%d,lag=%d\r\n",
+			i, host, r.Port, r.State, r.Offset, int(r.Lag.Seconds()))
+	}
+
+	fmt.Fprintf(b, "master_replid:%s\r\n", stats.ReplID)
+	fmt.Fprintf(b, "master_replid2:%s\r\n", cmp.Or(stats.ReplID2, strings.Repeat("0", 40)))
+	fmt.Fprintf(b, "master_repl_offset:%d\r\n", stats.Offset)
+	fmt.Fprintf(b, "second_repl_offset:%d\r\n", stats.Offset2)
+	fmt.Fprintf(b, "repl_backlog_active:1\r\n")
+	fmt.Fprintf(b, "repl_backlog_size:%d\r\n", stats.BacklogSize)
+	fmt.Fprintf(b, "repl_backlog_first_byte_offset:%d\r\n", stats.BacklogStart+1)
+	fmt.Fprintf(b, "repl_backlog_histlen:%d\r\n", stats.BacklogLength)
+}
+
+func infoKeyspace(b *strings.Builder) {
+	b.WriteString("# Keyspace\r\n")
+
+	if keys := Store.KeyCount(); keys > 0 {
+		fmt.Fprintf(b, "db0:keys=%d\r\n", keys)
+	}
+}
+
+func infoCluster(b *strings.Builder) {
+	b.WriteString("# Cluster\r\n")
+	enabled := 0
+	if Cluster != nil {
+		enabled = 1
+	}
+	fmt.Fprintf(b, "cluster_enabled:%d\r\n", enabled)
+}
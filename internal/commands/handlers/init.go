@@ -4,35 +4,142 @@ import (
 	"github.com/Eahtasham/go-redis/internal/commands"
 )
 
-// RegisterAll registers all command handlers
+// RegisterAll registers all command handlers. Arity follows Redis: the
+// exact number of arguments including the command name, or -N for at least N.
+// Commands that modify the dataset are flagged as writes, the few that don't
+// need the dataset may run while it is loading.
 func RegisterAll() {
+	commands.SetExecHooks(beginExecLog, endExecLog)
+	commands.SetSyncHooks(aofMark, aofWait)
+	commands.SetWriteGuard(checkWritable)
+
 	// String commands
-	commands.Register("PING", Ping)
-	commands.Register("SET", Set)
-	commands.Register("GET", Get)
-	commands.Register("DEL", Del)
-	commands.Register("EXISTS", Exists)
-	commands.Register("EXPIRE", Expire)
-	commands.Register("TTL", TTL)
-	commands.Register("INCR", Incr)
-	commands.Register("DECR", Decr)
-	commands.Register("INCRBY", IncrBy)
+	commands.RegisterClient("PING", -1, 0, Ping)
+	commands.RegisterClient("HELLO", -1, commands.FlagLoadingOK, Hello)
+	commands.Register("SET", -3, commands.FlagWrite, Set)
+	commands.Register("GET", 2, 0, Get)
+	commands.Register("DEL", -2, commands.FlagWrite, Del)
+	commands.Register("EXISTS", -2, 0, Exists)
+	commands.Register("EXPIRE", 3, commands.FlagWrite, Expire)
+	commands.Register("EXPIREAT", 3, commands.FlagWrite, ExpireAt)
+	commands.Register("PEXPIREAT", 3, commands.FlagWrite, PExpireAt)
+	commands.Register("TTL", 2, 0, TTL)
+	commands.Register("INCR", 2, commands.FlagWrite, Incr)
+	commands.Register("DECR", 2, commands.FlagWrite, Decr)
+	commands.Register("INCRBY", 3, commands.FlagWrite, IncrBy)
+	commands.Register("KEYS", 2, 0, Keys)
 
 	// List commands
-	commands.Register("LPUSH", LPush)
-	commands.Register("RPUSH", RPush)
-	commands.Register("LPOP", LPop)
-	commands.Register("RPOP", RPop)
-	commands.Register("LRANGE", LRange)
-	commands.Register("LLEN", LLen)
-	commands.Register("LINDEX", LIndex)
+	commands.Register("LPUSH", -3, commands.FlagWrite, LPush)
+	commands.Register("RPUSH", -3, commands.FlagWrite, RPush)
+	commands.Register("LPOP", -2, commands.FlagWrite, LPop)
+	commands.Register("RPOP", -2, commands.FlagWrite, RPop)
+	commands.Register("LRANGE", 4, 0, LRange)
+	commands.Register("LLEN", 2, 0, LLen)
+	commands.Register("LINDEX", 3, 0, LIndex)
+	commands.Register("LMOVE", 5, commands.FlagWrite, LMove)
+	commands.Register("RPOPLPUSH", 3, commands.FlagWrite, RPopLPush)
+
+	// Blocking list commands
+	commands.RegisterClient("BLPOP", -3, commands.FlagWrite, BLPop)
+	commands.RegisterClient("BRPOP", -3, commands.FlagWrite, BRPop)
+	commands.RegisterClient("BLMOVE", 6, commands.FlagWrite, BLMove)
+	commands.RegisterClient("BRPOPLPUSH", 4, commands.FlagWrite, BRPopLPush)
 
 	// Set commands
-	commands.Register("SADD", SAdd)
-	commands.Register("SREM", SRem)
-	commands.Register("SMEMBERS", SMembers)
-	commands.Register("SISMEMBER", SIsMember)
-	commands.Register("SCARD", SCard)
-	commands.Register("SUNION", SUnion)
-	commands.Register("SINTER", SInter)
+	commands.Register("SADD", -3, commands.FlagWrite, SAdd)
+	commands.Register("SREM", -3, commands.FlagWrite, SRem)
+	commands.Register("SMEMBERS", 2, 0, SMembers)
+	commands.Register("SISMEMBER", 3, 0, SIsMember)
+	commands.Register("SCARD", 2, 0, SCard)
+	commands.Register("SUNION", -2, 0, SUnion)
+	commands.Register("SINTER", -2, 0, SInter)
+
+	// Hash commands
+	commands.Register("HSET", -4, commands.FlagWrite, HSet)
+	commands.Register("HSETNX", 4, commands.FlagWrite, HSetNX)
+	commands.Register("HGET", 3, 0, HGet)
+	commands.Register("HMGET", -3, 0, HMGet)
+	commands.Register("HDEL", -3, commands.FlagWrite, HDel)
+	commands.Register("HEXISTS", 3, 0, HExists)
+	commands.Register("HLEN", 2, 0, HLen)
+	commands.Register("HKEYS", 2, 0, HKeys)
+	commands.Register("HVALS", 2, 0, HVals)
+	commands.Register("HGETALL", 2, 0, HGetAll)
+	commands.Register("HINCRBY", 4, commands.FlagWrite, HIncrBy)
+	commands.Register("HINCRBYFLOAT", 4, commands.FlagWrite, HIncrByFloat)
+	commands.Register("HSTRLEN", 3, 0, HStrLen)
+
+	// Sorted set commands
+	commands.Register("ZADD", -4, commands.FlagWrite, ZAdd)
+	commands.Register("ZINCRBY", 4, commands.FlagWrite, ZIncrBy)
+	commands.Register("ZREM", -3, commands.FlagWrite, ZRem)
+	commands.Register("ZSCORE", 3, 0, ZScore)
+	commands.Register("ZCARD", 2, 0, ZCard)
+	commands.Register("ZRANK", 3, 0, ZRank)
+	commands.Register("ZREVRANK", 3, 0, ZRevRank)
+	commands.Register("ZRANGE", -4, 0, ZRange)
+	commands.Register("ZREVRANGE", -4, 0, ZRevRange)
+	commands.Register("ZRANGEBYSCORE", -4, 0, ZRangeByScore)
+	commands.Register("ZREVRANGEBYSCORE", -4, 0, ZRevRangeByScore)
+	commands.Register("ZCOUNT", 4, 0, ZCount)
+	commands.Register("ZPOPMIN", -2, commands.FlagWrite, ZPopMin)
+	commands.Register("ZPOPMAX", -2, commands.FlagWrite, ZPopMax)
+	commands.Register("ZUNIONSTORE", -4, commands.FlagWrite, ZUnionStore)
+	commands.Register("ZINTERSTORE", -4, commands.FlagWrite, ZInterStore)
+
+	// Transaction commands (MULTI/EXEC/DISCARD are handled by the dispatcher)
+	commands.RegisterClient("WATCH", -2, 0, Watch)
+	commands.RegisterClient("UNWATCH", 1, 0, Unwatch)
+
+	// Pub/Sub commands
+	commands.RegisterClient("SUBSCRIBE", -2, commands.FlagLoadingOK, Subscribe)
+	commands.RegisterClient("UNSUBSCRIBE", -1, commands.FlagLoadingOK, Unsubscribe)
+	commands.RegisterClient("PSUBSCRIBE", -2, commands.FlagLoadingOK, PSubscribe)
+	commands.RegisterClient("PUNSUBSCRIBE", -1, commands.FlagLoadingOK, PUnsubscribe)
+	commands.Register("PUBLISH", 3, commands.FlagLoadingOK, Publish)
+	commands.Register("PUBSUB", -2, commands.FlagLoadingOK, PubSubCommand)
+
+	// Persistence commands
+	commands.Register("BGREWRITEAOF", 1, 0, BgRewriteAOF)
+	commands.RegisterClient("SAVE", 1, 0, SaveCommand)
+	commands.Register("BGSAVE", 1, 0, BgSaveCommand)
+	commands.Register("LASTSAVE", 1, commands.FlagLoadingOK, LastSave)
+
+	// Replication commands
+	commands.RegisterClient("REPLICAOF", 3, commands.FlagLoadingOK, ReplicaOfCommand)
+	commands.RegisterClient("SLAVEOF", 3, commands.FlagLoadingOK, ReplicaOfCommand)
+	commands.RegisterClient("PSYNC", 3, 0, PSync)
+	commands.RegisterClient("REPLCONF", -1, commands.FlagLoadingOK, ReplConf)
+
+	// Cluster commands
+	commands.Register("CLUSTER", -2, 0, ClusterCommand)
+	commands.RegisterClient("ASKING", 1, 0, Asking)
+
+	// Migration commands
+	commands.Register("DUMP", 2, 0, Dump)
+	commands.Register("RESTORE", -4, commands.FlagWrite, Restore)
+	commands.Register("RESTORE-ASKING", -4, commands.FlagWrite|commands.FlagAsking, Restore)
+	commands.RegisterClient("MIGRATE", -5, commands.FlagWrite, Migrate)
+
+	// Server commands
+	commands.Register("INFO", -1, commands.FlagLoadingOK, Info)
+
+	// Key positions, for routing in cluster mode: first and last key
+	// counted from the command name (negative from the end) and the step
+	commands.SetKeys(1, 1, 1,
+		"SET", "GET", "EXPIRE", "EXPIREAT", "PEXPIREAT", "TTL", "INCR", "DECR", "INCRBY",
+		"LPUSH", "RPUSH", "LPOP", "RPOP", "LRANGE", "LLEN", "LINDEX",
+		"SADD", "SREM", "SMEMBERS", "SISMEMBER", "SCARD",
+		"HSET", "HSETNX", "HGET", "HMGET", "HDEL", "HEXISTS", "HLEN", "HKEYS", "HVALS", "HGETALL",
+		"HINCRBY", "HINCRBYFLOAT", "HSTRLEN",
+		"ZADD", "ZINCRBY", "ZREM", "ZSCORE", "ZCARD", "ZRANK", "ZREVRANK", "ZRANGE", "ZREVRANGE",
+		"ZRANGEBYSCORE", "ZREVRANGEBYSCORE", "ZCOUNT", "ZPOPMIN", "ZPOPMAX",
+		"DUMP", "RESTORE", "RESTORE-ASKING")
+	commands.SetKeys(1, -1, 1, "DEL", "EXISTS", "SUNION", "SINTER", "WATCH")
+	commands.SetKeys(1, 2, 1, "LMOVE", "RPOPLPUSH", "BLMOVE", "BRPOPLPUSH")
+	commands.SetKeys(1, -2, 1, "BLPOP", "BRPOP")
+	commands.SetKeysFunc("ZUNIONSTORE", zstoreKeys)
+	commands.SetKeysFunc("ZINTERSTORE", zstoreKeys)
+	commands.SetKeysFunc("MIGRATE", migrateKeys)
 }
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
 )
@@ -24,6 +25,9 @@ func LPush(args []string) resp.Value {
 	// Log to AOF
 	logCommand("LPUSH", args...)
 
+	// Hand the new elements to clients blocked on this key
+	serveBlocked(key)
+
 	return resp.IntValue(length)
 }
 
@@ -45,6 +49,9 @@ func RPush(args []string) resp.Value {
 	// Log to AOF
 	logCommand("RPUSH", args...)
 
+	// Hand the new elements to clients blocked on this key
+	serveBlocked(key)
+
 	return resp.IntValue(length)
 }
 
@@ -71,17 +78,15 @@ func LPop(args []string) resp.Value {
 	}
 
 	if popped == nil {
-		return resp.Value{Type: resp.BulkString, Str: ""} // nil
+		// With a count the reply is an array, so its nil is a null array
+		if len(args) == 2 {
+			return resp.NullArrayValue()
+		}
+		return resp.NullBulkValue()
 	}
 
-	// Log state for AOF (get remaining list or DEL if empty)
-	if remaining, exists := Store.GetListCopy(key); exists {
-		logCommand("DEL", key)
-		rpushArgs := append([]string{key}, remaining...)
-		logCommand("RPUSH", rpushArgs...)
-	} else {
-		logCommand("DEL", key)
-	}
+	// Pops are deterministic, so the AOF records the pop itself
+	logCommand("LPOP", key, strconv.Itoa(len(popped)))
 
 	// Return single element or array based on count
 	if len(args) == 1 {
@@ -118,17 +123,15 @@ func RPop(args []string) resp.Value {
 	}
 
 	if popped == nil {
-		return resp.Value{Type: resp.BulkString, Str: ""} // nil
+		// With a count the reply is an array, so its nil is a null array
+		if len(args) == 2 {
+			return resp.NullArrayValue()
+		}
+		return resp.NullBulkValue()
 	}
 
-	// Log state for AOF (get remaining list or DEL if empty)
-	if remaining, exists := Store.GetListCopy(key); exists {
-		logCommand("DEL", key)
-		rpushArgs := append([]string{key}, remaining...)
-		logCommand("RPUSH", rpushArgs...)
-	} else {
-		logCommand("DEL", key)
-	}
+	// Pops are deterministic, so the AOF records the pop itself
+	logCommand("RPOP", key, strconv.Itoa(len(popped)))
 
 	// Return single element or array based on count
 	if len(args) == 1 {
@@ -204,8 +207,68 @@ func LIndex(args []string) resp.Value {
 	}
 
 	if !exists {
-		return resp.Value{Type: resp.BulkString, Str: ""} // nil
+		return resp.NullBulkValue()
 	}
 
 	return resp.BulkValue(value)
 }
+
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+// Atomically pop an element from one list and push it to another
+func LMove(args []string) resp.Value {
+	if len(args) != 4 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'lmove' command")
+	}
+
+	fromLeft, ok1 := parseListSide(args[2])
+	toLeft, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	return lmove(args[0], args[1], fromLeft, toLeft)
+}
+
+// RPOPLPUSH source destination
+// Same as LMOVE source destination RIGHT LEFT
+func RPopLPush(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'rpoplpush' command")
+	}
+
+	return lmove(args[0], args[1], false, true)
+}
+
+func lmove(src, dest string, fromLeft, toLeft bool) resp.Value {
+	pop, err := Store.LMove(src, dest, fromLeft, toLeft)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if pop == nil {
+		return resp.NullBulkValue()
+	}
+
+	logListPop(*pop)
+	serveBlocked(dest)
+
+	return resp.BulkValue(pop.Value)
+}
+
+// parseListSide parses a LEFT|RIGHT argument, returns true for LEFT
+func parseListSide(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+func listSideName(left bool) string {
+	if left {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
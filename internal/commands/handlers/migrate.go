@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/persistence"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+const (
+	// MIGRATE keeps its connection to a target this long after the last
+	// use, so moving many keys doesn't reconnect for every batch
+	migrateConnIdle = 10 * time.Second
+
+	// Timeout when MIGRATE is given 0
+	migrateDefaultTimeout = time.Second
+)
+
+// DUMP key
+// Serialize the value of a key, for RESTORE
+func Dump(args []string) resp.Value {
+	e, ok := Store.Clone(args[0])
+	if !ok {
+		return resp.NullBulkValue()
+	}
+	return resp.BulkValue(string(persistence.Dump(e)))
+}
+
+// RESTORE key ttl serialized-value [REPLACE] [ABSTTL]
+// Create a key from a DUMP payload. ttl is in milliseconds, 0 for none,
+// and a Unix time in milliseconds with ABSTTL.
+func Restore(args []string) resp.Value {
+	key := args[0]
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+	if ttl < 0 {
+		return resp.ErrorValue("ERR Invalid TTL value, must be >= 0")
+	}
+
+	replace, absTTL := false, false
+	for _, opt := range args[3:] {
+		switch strings.ToUpper(opt) {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		default:
+			return resp.ErrorValue("ERR syntax error")
+		}
+	}
+
+	t, val, err := persistence.Undump([]byte(args[2]))
+	if err != nil {
+		return resp.ErrorValue("ERR " + err.Error())
+	}
+
+	var expiry time.Time
+	switch {
+	case ttl == 0:
+	case absTTL:
+		expiry = time.UnixMilli(ttl)
+	default:
+		var ok bool
+		if expiry, ok = expiryIn(ttl, time.Millisecond); !ok {
+			return resp.ErrorValue("ERR Invalid TTL value, must be >= 0")
+		}
+	}
+
+	if err := Store.RestoreKey(key, t, val, expiry, replace); err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	// Logged with the absolute expiry, so replaying it never extends the
+	// key's life
+	abs := "0"
+	if !expiry.IsZero() {
+		abs = strconv.FormatInt(expiry.UnixMilli(), 10)
+	}
+	logCommand("RESTORE", key, abs, args[2], "REPLACE", "ABSTTL")
+
+	if t == store.ListType {
+		serveBlocked(key)
+	}
+	return resp.SimpleValue("OK")
+}
+
+// MIGRATE host port key|"" timeout [COPY] [REPLACE] [KEYS key ...]
+// Move keys to another server: they are sent with their TTLs and deleted
+// here once the target stored them. Redis' form, with a destination-db of
+// 0 before the timeout, is accepted too.
+func Migrate(ctx *commands.ClientContext, args []string) resp.Value {
+	addr := net.JoinHostPort(args[0], args[1])
+	key, timeoutArg, opts := args[2], args[3], args[4:]
+	if len(opts) > 0 {
+		if _, err := strconv.Atoi(opts[0]); err == nil {
+			if args[3] != "0" {
+				return resp.ErrorValue("ERR DB index is out of range")
+			}
+			timeoutArg, opts = opts[0], opts[1:]
+		}
+	}
+
+	timeout, err := strconv.ParseInt(timeoutArg, 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+	wait := time.Duration(timeout) * time.Millisecond
+	if timeout <= 0 {
+		wait = migrateDefaultTimeout
+	}
+
+	copyKeys, replace := false, false
+	keys := []string{key}
+	for i := 0; i < len(opts); i++ {
+		switch strings.ToUpper(opts[i]) {
+		case "COPY":
+			copyKeys = true
+		case "REPLACE":
+			replace = true
+		case "KEYS":
+			if key != "" {
+				return resp.ErrorValue("ERR When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			keys = opts[i+1:]
+			i = len(opts)
+		default:
+			return resp.ErrorValue("ERR syntax error")
+		}
+	}
+	if len(keys) == 0 || (len(keys) == 1 && keys[0] == "") {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	// The keys are serialized under the command's locks, which are released
+	// while the target stores them so a slow target holds up no other
+	// client. A key written to meanwhile is kept here rather than deleted.
+	sent, buf := dumpKeys(keys, replace)
+	if len(sent) == 0 {
+		return resp.SimpleValue("NOKEY")
+	}
+	defer func() {
+		for _, k := range sent {
+			k.watch.Reset()
+		}
+	}()
+
+	var replies []resp.Value
+	var ioErr string
+	send := func() {
+		replies, ioErr = sendKeys(addr, buf, len(sent), wait)
+	}
+	if ctx.CanBlock() {
+		ctx.WaitUnlocked(send)
+	} else {
+		send()
+	}
+
+	var targetErr string
+	for i, v := range replies {
+		if v.Type == resp.Error {
+			if targetErr == "" {
+				targetErr = v.Str
+			}
+			continue
+		}
+		if key := sent[i].key; !copyKeys && !sent[i].watch.Dirty() && Store.Delete(key) {
+			logCommand("DEL", key)
+		}
+	}
+
+	if ioErr != "" {
+		return resp.ErrorValue(ioErr)
+	}
+	if targetErr != "" {
+		return resp.ErrorValue("ERR Target instance replied with error: " + targetErr)
+	}
+	return resp.SimpleValue("OK")
+}
+
+// migrateKeys finds the keys of MIGRATE: the key argument, or the ones
+// after KEYS when it is empty
+func migrateKeys(args []string) []string {
+	if len(args) > 2 && args[2] != "" {
+		return args[2:3]
+	}
+	for i := 4; i < len(args); i++ {
+		if strings.EqualFold(args[i], "KEYS") {
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// migrateKey is a key sent by MIGRATE, watched so it is only deleted if
+// nothing changed it while the target stored it
+type migrateKey struct {
+	key   string
+	watch *store.Watch
+}
+
+// dumpKeys encodes a RESTORE-ASKING for each of keys that exists
+func dumpKeys(keys []string, replace bool) ([]migrateKey, []byte) {
+	var sent []migrateKey
+	var buf []byte
+	for _, key := range keys {
+		w := Store.NewWatch()
+		w.Add([]string{key})
+		e, ok := Store.Clone(key)
+		if !ok {
+			w.Reset()
+			continue
+		}
+
+		ttl := int64(0)
+		if !e.Expiry.IsZero() {
+			ttl = max(e.Expiry.UnixMilli()-time.Now().UnixMilli(), 1)
+		}
+		cmd := []string{key, strconv.FormatInt(ttl, 10), string(persistence.Dump(e))}
+		if replace {
+			cmd = append(cmd, "REPLACE")
+		}
+		buf = append(buf, persistence.EncodeCommand("RESTORE-ASKING", cmd)...)
+		sent = append(sent, migrateKey{key: key, watch: w})
+	}
+	return sent, buf
+}
+
+// sendKeys writes buf to the target and reads its n replies. On a network
+// error it returns the replies read so far along with an IOERR message.
+func sendKeys(addr string, buf []byte, n int, timeout time.Duration) ([]resp.Value, string) {
+	mc, err := getMigrateConn(addr, timeout)
+	if err != nil {
+		return nil, "IOERR error or timeout connecting to the client"
+	}
+
+	mc.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := mc.conn.Write(buf); err != nil {
+		mc.close()
+		return nil, "IOERR error or timeout writing to target instance"
+	}
+
+	replies := make([]resp.Value, 0, n)
+	for range n {
+		v, err := mc.reader.ReadValue()
+		if err != nil {
+			mc.close()
+			return replies, "IOERR error or timeout reading to target instance"
+		}
+		replies = append(replies, v)
+	}
+	mc.release()
+	return replies, ""
+}
+
+// migrateConn is a cached connection to a MIGRATE target
+type migrateConn struct {
+	addr   string
+	conn   net.Conn
+	reader *resp.Reader
+	idle   *time.Timer
+}
+
+var migrateConns = struct {
+	sync.Mutex
+	m map[string]*migrateConn
+}{m: make(map[string]*migrateConn)}
+
+// getMigrateConn returns the cached connection to addr or dials a new one.
+// Connections are closed once idle for migrateConnIdle.
+func getMigrateConn(addr string, timeout time.Duration) (*migrateConn, error) {
+	migrateConns.Lock()
+	mc := migrateConns.m[addr]
+	if mc != nil && !mc.idle.Stop() {
+		// In use by another MIGRATE, or already being closed for idling
+		mc = nil
+	}
+	migrateConns.Unlock()
+	if mc != nil {
+		return mc, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	mc = &migrateConn{addr: addr, conn: conn, reader: resp.NewReader(conn)}
+	mc.idle = time.AfterFunc(migrateConnIdle, mc.close)
+	mc.idle.Stop()
+
+	migrateConns.Lock()
+	migrateConns.m[addr] = mc
+	migrateConns.Unlock()
+	return mc, nil
+}
+
+// release starts the idle countdown after a MIGRATE used the connection
+func (mc *migrateConn) release() {
+	mc.idle.Reset(migrateConnIdle)
+}
+
+// close drops a broken or idle connection from the cache
+func (mc *migrateConn) close() {
+	migrateConns.Lock()
+	if migrateConns.m[mc.addr] == mc {
+		delete(migrateConns.m, mc.addr)
+	}
+	migrateConns.Unlock()
+	mc.conn.Close()
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/persistence"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// RewriteAOF starts a background AOF rewrite from a point-in-time view of
+// the store. It waits for running commands to finish, so it must not be
+// called from a handler.
+func RewriteAOF() error {
+	var err error
+	commands.Exclusive(func() {
+		err = AOF.StartRewrite(Store.Snapshot)
+	})
+	return err
+}
+
+// RDB is the snapshot file, initialized at server startup
+var RDB *persistence.RDB
+
+// InitRDB sets the global snapshot instance
+func InitRDB(r *persistence.RDB) {
+	RDB = r
+}
+
+// snapshotLocked takes a point-in-time copy of the dataset together with the
+// AOF position it corresponds to. No command may run meanwhile.
+func snapshotLocked() (persistence.SnapshotInfo, map[string]*store.Entry, int64) {
+	info := persistence.SnapshotInfo{Created: time.Now()}
+	if AOF != nil {
+		pos := AOF.Position()
+		info.AOF = &pos
+	}
+	return info, Store.Snapshot(), Store.Changes()
+}
+
+// Save writes a snapshot in the foreground. It waits for running commands
+// to finish, so it must not be called from a handler.
+func Save() error {
+	var err error
+	commands.Exclusive(func() {
+		err = RDB.Save(snapshotLocked())
+	})
+	return err
+}
+
+// BgSave starts a background snapshot, see Save
+func BgSave() error {
+	var err error
+	commands.Exclusive(func() {
+		err = RDB.StartBackground(snapshotLocked())
+	})
+	return err
+}
+
+// SAVE
+// Write a snapshot of the dataset, blocking every client until it's done
+func SaveCommand(ctx *commands.ClientContext, args []string) resp.Value {
+	var err error
+	ctx.Exclusive(func() {
+		err = RDB.Save(snapshotLocked())
+	})
+
+	if errors.Is(err, persistence.ErrSaveInProgress) {
+		return resp.ErrorValue(err.Error())
+	}
+	if err != nil {
+		return resp.ErrorValue("ERR " + err.Error())
+	}
+	return resp.SimpleValue("OK")
+}
+
+// BGSAVE
+// Write a snapshot of the dataset in the background
+func BgSaveCommand(args []string) resp.Value {
+	if RDB.Saving() {
+		return resp.ErrorValue(persistence.ErrSaveInProgress.Error())
+	}
+
+	// The handler runs while holding the execution lock, BgSave needs it exclusively
+	go func() {
+		if err := BgSave(); err != nil {
+			log.Printf("BGSAVE: %v", err)
+		}
+	}()
+
+	return resp.SimpleValue("Background saving started")
+}
+
+// LASTSAVE
+// Unix time of the last successful snapshot
+func LastSave(args []string) resp.Value {
+	return resp.IntValue(RDB.LastSave().Unix())
+}
+
+// checkWritable refuses write commands on a replica, whose dataset only
+// follows its master, and while the AOF can't take them, see
+// persistence.AOF.Writable
+func checkWritable() error {
+	if Repl != nil && Repl.Following() {
+		return errReadOnly
+	}
+	if AOF == nil {
+		return nil
+	}
+	return AOF.Writable()
+}
+
+// BGREWRITEAOF
+// Compact the append only file in the background
+func BgRewriteAOF(args []string) resp.Value {
+	if len(args) != 0 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'bgrewriteaof' command")
+	}
+	if AOF == nil {
+		return resp.ErrorValue("ERR append only file is disabled")
+	}
+	if AOF.Rewriting() {
+		return resp.ErrorValue("ERR Background append only file rewriting already in progress")
+	}
+
+	// The handler runs while holding the execution lock, RewriteAOF needs it exclusively
+	go func() {
+		if err := RewriteAOF(); err != nil {
+			log.Printf("BGREWRITEAOF: %v", err)
+		}
+	}()
+
+	return resp.SimpleValue("Background append only file rewriting started")
+}
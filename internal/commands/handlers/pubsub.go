@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/pubsub"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// Global pub/sub broker - will be initialized at server startup
+var PubSub *pubsub.Broker
+
+// InitPubSub sets the global pub/sub broker
+func InitPubSub(b *pubsub.Broker) {
+	PubSub = b
+}
+
+// pushConfirms writes one (un)subscribe confirmation per channel or pattern
+func pushConfirms(ctx *commands.ClientContext, confirms []pubsub.Message) resp.Value {
+	for _, m := range confirms {
+		if err := ctx.Push(m.Value()); err != nil {
+			break
+		}
+	}
+	return commands.NoReply
+}
+
+// subscriber returns the client's subscriber, creating it on first use
+func subscriber(ctx *commands.ClientContext) *pubsub.Subscriber {
+	if ctx.Sub == nil {
+		ctx.Sub = PubSub.NewSubscriber()
+	}
+	return ctx.Sub
+}
+
+// SUBSCRIBE channel [channel ...]
+// Listen for messages published to the given channels
+func Subscribe(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'subscribe' command")
+	}
+	if !ctx.Live() {
+		return resp.ErrorValue("ERR SUBSCRIBE isn't allowed in this context")
+	}
+
+	return pushConfirms(ctx, PubSub.Subscribe(subscriber(ctx), args))
+}
+
+// UNSUBSCRIBE [channel ...]
+// Stop listening to the given channels, or to all of them
+func Unsubscribe(ctx *commands.ClientContext, args []string) resp.Value {
+	if !ctx.Live() {
+		return resp.ErrorValue("ERR UNSUBSCRIBE isn't allowed in this context")
+	}
+
+	return pushConfirms(ctx, PubSub.Unsubscribe(subscriber(ctx), args))
+}
+
+// PSUBSCRIBE pattern [pattern ...]
+// Listen for messages published to channels matching the given glob patterns
+func PSubscribe(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'psubscribe' command")
+	}
+	if !ctx.Live() {
+		return resp.ErrorValue("ERR PSUBSCRIBE isn't allowed in this context")
+	}
+
+	return pushConfirms(ctx, PubSub.PSubscribe(subscriber(ctx), args))
+}
+
+// PUNSUBSCRIBE [pattern ...]
+// Stop listening to the given patterns, or to all of them
+func PUnsubscribe(ctx *commands.ClientContext, args []string) resp.Value {
+	if !ctx.Live() {
+		return resp.ErrorValue("ERR PUNSUBSCRIBE isn't allowed in this context")
+	}
+
+	return pushConfirms(ctx, PubSub.PUnsubscribe(subscriber(ctx), args))
+}
+
+// PUBLISH channel message
+// Post a message to a channel, returns the number of clients that received it
+func Publish(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'publish' command")
+	}
+
+	return resp.IntValue(int64(PubSub.Publish(args[0], args[1])))
+}
+
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+// Inspect the state of the pub/sub subsystem
+func PubSubCommand(args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'pubsub' command")
+	}
+
+	switch sub := strings.ToUpper(args[0]); {
+	case sub == "CHANNELS" && len(args) <= 2:
+		pattern := ""
+		if len(args) == 2 {
+			pattern = args[1]
+		}
+
+		channels := PubSub.Channels(pattern)
+		result := make([]resp.Value, len(channels))
+		for i, ch := range channels {
+			result[i] = resp.BulkValue(ch)
+		}
+		return resp.ArrayValue(result)
+
+	case sub == "NUMSUB":
+		counts := PubSub.NumSub(args[1:])
+		result := make([]resp.Value, 0, len(counts)*2)
+		for i, ch := range args[1:] {
+			result = append(result, resp.BulkValue(ch), resp.IntValue(int64(counts[i])))
+		}
+		return resp.MapValue(result)
+
+	case sub == "NUMPAT" && len(args) == 1:
+		return resp.IntValue(int64(PubSub.NumPat()))
+	}
+
+	return resp.ErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0] + "'. Try PUBSUB HELP.")
+}
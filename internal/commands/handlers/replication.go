@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+	"github.com/Eahtasham/go-redis/internal/replication"
+)
+
+// Repl is the replication state, initialized at server startup
+var Repl *replication.Master
+
+// The link to the master while this server is a replica
+var (
+	linkMu        sync.Mutex
+	link          *replication.Link
+	listeningPort int
+)
+
+var errReadOnly = errors.New("READONLY You can't write against a read only replica.")
+
+// InitReplication sets the replication state and the port this server
+// announces to its master
+func InitReplication(m *replication.Master, port int) {
+	Repl = m
+	listeningPort = port
+}
+
+// ReplicaOf makes this server follow the master at addr, replacing the
+// current master if any. An empty addr stops following and turns the
+// server back into a master, keeping its dataset.
+func ReplicaOf(addr string) {
+	linkMu.Lock()
+	defer linkMu.Unlock()
+
+	if link != nil {
+		link.Stop()
+		link = nil
+	}
+	if addr == "" {
+		Repl.Promote()
+		return
+	}
+
+	Repl.Follow()
+	link = replication.NewLink(addr, listeningPort, Repl, replication.Hooks{
+		BeginSync: beginSync,
+		Restore: func(key string, t store.ValueType, val any, expiry time.Time) {
+			Store.Restore(key, t, val, expiry)
+		},
+		EndSync: endSync,
+		Apply: func(v resp.Value, relay func()) {
+			commands.Apply(v, relay)
+		},
+		ApplyTxn: commands.ApplyTxn,
+	})
+	link.Run()
+}
+
+// StopReplication disconnects from the master on shutdown
+func StopReplication() {
+	linkMu.Lock()
+	defer linkMu.Unlock()
+
+	if link != nil {
+		link.Stop()
+		link = nil
+	}
+}
+
+// currentLink returns the link to the master, nil on a master
+func currentLink() *replication.Link {
+	linkMu.Lock()
+	defer linkMu.Unlock()
+	return link
+}
+
+// beginSync drops the dataset before a full sync loads the master's.
+// Clients get -LOADING until it's done.
+func beginSync() {
+	commands.Exclusive(func() {
+		commands.SetLoading(true)
+		Store.Flush()
+	})
+}
+
+// endSync leaves the LOADING state after a full sync. The AOF still
+// describes the old dataset, so it is rewritten from the new one.
+func endSync() {
+	commands.SetLoading(false)
+	if AOF == nil {
+		return
+	}
+
+	for AOF.Rewriting() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := RewriteAOF(); err != nil {
+		log.Printf("Rewriting AOF after full resync: %v", err)
+	}
+}
+
+// REPLICAOF host port | REPLICAOF NO ONE
+// Follow a master, or stop following one
+func ReplicaOfCommand(ctx *commands.ClientContext, args []string) resp.Value {
+	if !ctx.Live() {
+		return resp.ErrorValue("ERR REPLICAOF is not allowed in transactions")
+	}
+	if Cluster != nil {
+		return resp.ErrorValue("ERR REPLICAOF not allowed in cluster mode.")
+	}
+
+	addr := ""
+	if !strings.EqualFold(args[0], "no") || !strings.EqualFold(args[1], "one") {
+		if port, err := strconv.Atoi(args[1]); err != nil || port <= 0 || port > 65535 {
+			return resp.ErrorValue("ERR Invalid master port")
+		}
+		addr = net.JoinHostPort(args[0], args[1])
+
+		if l := currentLink(); l != nil && l.Addr() == addr {
+			return resp.SimpleValue("OK Already connected to specified master")
+		}
+	}
+
+	// Stopping the link waits for the command it applies, which may need
+	// the execution lock
+	ctx.WaitUnlocked(func() {
+		ReplicaOf(addr)
+	})
+	return resp.SimpleValue("OK")
+}
+
+// PSYNC replid offset
+// Turn the connection into a replica. It continues from offset when the
+// backlog still has it, otherwise it gets a snapshot first.
+func PSync(ctx *commands.ClientContext, args []string) resp.Value {
+	if !ctx.Live() || ctx.Replica != nil {
+		return resp.ErrorValue("ERR PSYNC is not allowed in this context")
+	}
+
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+
+	// The snapshot for a full sync must match the stream offset exactly
+	ctx.Exclusive(func() {
+		ctx.Replica = Repl.Attach(args[0], offset, ctx.Addr, ctx.ListeningPort, Store.Snapshot)
+	})
+
+	// The connection layer sends the reply along with the stream
+	return commands.NoReply
+}
+
+// REPLCONF option value [option value ...]
+// Settings a replica sends during the handshake, and its ACKs afterwards
+func ReplConf(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args)%2 != 0 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "listening-port":
+			port, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return resp.ErrorValue("ERR value is not an integer or out of range")
+			}
+			ctx.ListeningPort = port
+		case "ack":
+			// Replicas don't read replies to their ACKs
+			if offset, err := strconv.ParseInt(args[i+1], 10, 64); err == nil && ctx.Replica != nil {
+				ctx.Replica.Ack(offset)
+			}
+			return commands.NoReply
+		case "capa":
+			// Nothing to negotiate
+		default:
+			return resp.ErrorValue("ERR Unrecognized REPLCONF option: " + args[i])
+		}
+	}
+	return resp.SimpleValue("OK")
+}
@@ -66,7 +66,7 @@ func SMembers(args []string) resp.Value {
 		result[i] = resp.BulkValue(member)
 	}
 
-	return resp.ArrayValue(result)
+	return resp.SetValue(result)
 }
 
 // SISMEMBER key member
@@ -134,7 +134,7 @@ func SUnion(args []string) resp.Value {
 		arr = append(arr, resp.BulkValue(member))
 	}
 
-	return resp.ArrayValue(arr)
+	return resp.SetValue(arr)
 }
 
 // SINTER key [key ...]
@@ -150,7 +150,7 @@ func SInter(args []string) resp.Value {
 		if err == store.ErrWrongType {
 			return resp.ErrorValue(err.Error())
 		}
-		return resp.ArrayValue([]resp.Value{})
+		return resp.SetValue([]resp.Value{})
 	}
 
 	result := make(map[string]struct{})
@@ -165,7 +165,7 @@ func SInter(args []string) resp.Value {
 			if err == store.ErrWrongType {
 				return resp.ErrorValue(err.Error())
 			}
-			return resp.ArrayValue([]resp.Value{}) // Empty intersection
+			return resp.SetValue([]resp.Value{}) // Empty intersection
 		}
 
 		setMembers := make(map[string]struct{})
@@ -186,5 +186,5 @@ func SInter(args []string) resp.Value {
 		arr = append(arr, resp.BulkValue(member))
 	}
 
-	return resp.ArrayValue(arr)
+	return resp.SetValue(arr)
 }
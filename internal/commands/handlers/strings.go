@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/engine/pubsub"
 	"github.com/Eahtasham/go-redis/internal/engine/store"
 	"github.com/Eahtasham/go-redis/internal/persistence"
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
@@ -25,15 +30,107 @@ func InitAOF(a *persistence.AOF) {
 	AOF = a
 }
 
-// logCommand logs a command to AOF if persistence is enabled
-func logCommand(cmd string, args ...string) {
+// Writes of the running EXEC, propagated as one MULTI ... EXEC block. EXEC
+// holds the execution lock exclusively, so there is only ever one.
+var execLog struct {
+	active bool
+	data   []byte
+}
+
+// propagate hands a write to the AOF and to attached replicas. Commands
+// replayed while loading are already in the file and aren't logged again.
+func propagate(data []byte) {
+	if commands.Loading() {
+		return
+	}
+	if execLog.active {
+		execLog.data = append(execLog.data, data...)
+		return
+	}
+
 	if AOF != nil {
-		AOF.Append(persistence.EncodeCommand(cmd, args))
+		AOF.Append(data)
 	}
+	if Repl != nil {
+		Repl.Feed(data)
+	}
+}
+
+// aofMark and aofWait let a write's reply wait until its AOF records are on
+// disk, see persistence.AOF.WaitSynced
+func aofMark() int64 {
+	if AOF == nil {
+		return 0
+	}
+	return AOF.Mark()
+}
+
+func aofWait(mark int64) error {
+	if AOF == nil {
+		return nil
+	}
+	return AOF.WaitSynced(mark)
+}
+
+// logCommand logs a command to the AOF and the replication stream
+func logCommand(cmd string, args ...string) {
+	propagate(persistence.EncodeCommand(cmd, args))
+}
+
+// logSet logs a string write, followed by its absolute expiry if it has
+// one. Both go out in one append so a crash can't separate them.
+func logSet(key, value string, expiry time.Time) {
+	data := persistence.EncodeCommand("SET", []string{key, value})
+	if !expiry.IsZero() {
+		data = append(data, persistence.EncodeExpireAt(key, expiry)...)
+	}
+	propagate(data)
+}
+
+// logExpireAt logs an expiry as PEXPIREAT, never as a relative TTL
+func logExpireAt(key string, at time.Time) {
+	propagate(persistence.EncodeExpireAt(key, at))
+}
+
+// beginExecLog and endExecLog wrap the writes of one EXEC in MULTI/EXEC,
+// so neither replay nor a replica ever applies half a transaction
+func beginExecLog() {
+	execLog.active = true
+	execLog.data = nil
+}
+
+func endExecLog() {
+	data := execLog.data
+	execLog.active = false
+	execLog.data = nil
+
+	// A transaction without writes leaves no trace
+	if len(data) == 0 {
+		return
+	}
+
+	block := persistence.EncodeCommand("MULTI", nil)
+	block = append(block, data...)
+	block = append(block, persistence.EncodeCommand("EXEC", nil)...)
+	propagate(block)
 }
 
 // Ping handles the PING command
-func Ping(args []string) resp.Value {
+func Ping(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) > 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'ping' command")
+	}
+
+	// RESP2 subscribers get a multi-bulk reply so it can't be confused with
+	// a message
+	if ctx.Subscribed() && !ctx.RESP3() {
+		msg := ""
+		if len(args) > 0 {
+			msg = args[0]
+		}
+		return resp.ArrayValue([]resp.Value{resp.BulkValue("pong"), resp.BulkValue(msg)})
+	}
+
 	if len(args) > 0 {
 		return resp.BulkValue(args[0])
 	}
@@ -50,36 +147,44 @@ func Set(args []string) resp.Value {
 	key := args[0]
 	value := args[1]
 
-	Store.Set(key, store.StringType, value)
-
-	// Handle optional EX/PX arguments
+	// Parse the options first, so a bad one leaves the key untouched
+	var expiry time.Time
 	for i := 2; i < len(args); i++ {
-		switch args[i] {
-		case "EX", "ex":
-			if i+1 >= len(args) {
-				return resp.ErrorValue("ERR syntax error")
-			}
-			seconds, err := strconv.Atoi(args[i+1])
-			if err != nil {
-				return resp.ErrorValue("ERR value is not an integer or out of range")
-			}
-			Store.SetExpiry(key, time.Duration(seconds)*time.Second)
-			i++
-		case "PX", "px":
-			if i+1 >= len(args) {
-				return resp.ErrorValue("ERR syntax error")
-			}
-			ms, err := strconv.Atoi(args[i+1])
-			if err != nil {
-				return resp.ErrorValue("ERR value is not an integer or out of range")
-			}
-			Store.SetExpiry(key, time.Duration(ms)*time.Millisecond)
-			i++
+		var unit time.Duration
+		switch strings.ToUpper(args[i]) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return resp.ErrorValue("ERR syntax error")
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return resp.ErrorValue("ERR value is not an integer or out of range")
 		}
+		if n <= 0 {
+			return resp.ErrorValue("ERR invalid expire time in 'set' command")
+		}
+		at, ok := expiryIn(n, unit)
+		if !ok {
+			return resp.ErrorValue("ERR invalid expire time in 'set' command")
+		}
+		expiry = at
+		i++
+	}
+
+	Store.Set(key, store.StringType, value)
+	if !expiry.IsZero() {
+		Store.SetExpiryAt(key, expiry)
 	}
 
 	// Log to AOF after successful execution
-	logCommand("SET", args...)
+	logSet(key, value, expiry)
 
 	return resp.SimpleValue("OK")
 }
@@ -93,7 +198,7 @@ func Get(args []string) resp.Value {
 	key := args[0]
 	entry, ok := Store.Get(key)
 	if !ok {
-		return resp.Value{Type: resp.BulkString, Str: ""} // nil bulk string
+		return resp.NullBulkValue()
 	}
 
 	if entry.Type != store.StringType {
@@ -137,21 +242,119 @@ func Exists(args []string) resp.Value {
 	return resp.IntValue(count)
 }
 
+// KEYS pattern
+// Return the keys matching a glob pattern. It scans the whole keyspace.
+func Keys(args []string) resp.Value {
+	keys := Store.Keys(func(key string) bool {
+		return pubsub.Match(args[0], key)
+	}, 0)
+	sort.Strings(keys)
+
+	result := make([]resp.Value, len(keys))
+	for i, key := range keys {
+		result[i] = resp.BulkValue(key)
+	}
+	return resp.ArrayValue(result)
+}
+
 // Expire handles the EXPIRE command
+// EXPIRE key seconds
 func Expire(args []string) resp.Value {
 	if len(args) != 2 {
 		return resp.ErrorValue("ERR wrong number of arguments for 'expire' command")
 	}
 
-	key := args[0]
-	seconds, err := strconv.Atoi(args[1])
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+
+	at, ok := expiryIn(seconds, time.Second)
+	if !ok {
+		return resp.ErrorValue("ERR invalid expire time in 'expire' command")
+	}
+	return expireAt(args[0], at)
+}
+
+// ExpireAt handles the EXPIREAT command
+// EXPIREAT key unix-time-seconds
+func ExpireAt(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'expireat' command")
+	}
+
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+
+	at, ok := unixTime(seconds, time.Second)
+	if !ok {
+		return resp.ErrorValue("ERR invalid expire time in 'expireat' command")
+	}
+	return expireAt(args[0], at)
+}
+
+// PExpireAt handles the PEXPIREAT command
+// PEXPIREAT key unix-time-milliseconds
+func PExpireAt(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'pexpireat' command")
+	}
+
+	ms, err := strconv.ParseInt(args[1], 10, 64)
 	if err != nil {
 		return resp.ErrorValue("ERR value is not an integer or out of range")
 	}
 
-	if Store.SetExpiry(key, time.Duration(seconds)*time.Second) {
+	at, ok := unixTime(ms, time.Millisecond)
+	if !ok {
+		return resp.ErrorValue("ERR invalid expire time in 'pexpireat' command")
+	}
+	return expireAt(args[0], at)
+}
+
+// unixTime returns the time n units after the epoch. Like Redis, expiry
+// times are kept in milliseconds: ok is false when that count doesn't fit
+// in an int64, so a huge value can't wrap around into the past.
+func unixTime(n int64, unit time.Duration) (time.Time, bool) {
+	perUnit := int64(unit / time.Millisecond)
+	if n > math.MaxInt64/perUnit || n < math.MinInt64/perUnit {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(n * perUnit), true
+}
+
+// expiryIn returns the time n units from now, see unixTime
+func expiryIn(n int64, unit time.Duration) (time.Time, bool) {
+	rel, ok := unixTime(n, unit)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	ms, now := rel.UnixMilli(), time.Now().UnixMilli()
+	if (ms > 0 && now > math.MaxInt64-ms) || (ms < 0 && now < math.MinInt64-ms) {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(now + ms), true
+}
+
+// expireAt sets an absolute expiry and logs it as PEXPIREAT. A time in the
+// past deletes the key right away, which is also how replay skips keys that
+// expired while the server was down.
+func expireAt(key string, at time.Time) resp.Value {
+	if !at.After(time.Now()) {
+		if _, ok := Store.Get(key); !ok {
+			return resp.IntValue(0)
+		}
+		Store.Delete(key)
+		logCommand("DEL", key)
+		return resp.IntValue(1)
+	}
+
+	if Store.SetExpiryAt(key, at) {
 		// Log to AOF after successful expiry set
-		logCommand("EXPIRE", args...)
+		logExpireAt(key, at)
 		return resp.IntValue(1)
 	}
 	return resp.IntValue(0)
@@ -173,12 +376,13 @@ func TTL(args []string) resp.Value {
 		return resp.IntValue(-1) // key exists but has no expiry
 	}
 
-	ttl := time.Until(entry.Expiry).Seconds()
+	// In milliseconds, time.Until tops out at 292 years
+	ttl := entry.Expiry.UnixMilli() - time.Now().UnixMilli()
 	if ttl < 0 {
 		return resp.IntValue(-2)
 	}
 
-	return resp.IntValue(int64(ttl))
+	return resp.IntValue(ttl / 1000)
 }
 
 // Incr handles the INCR command
@@ -186,10 +390,10 @@ func Incr(args []string) resp.Value {
 	if len(args) != 1 {
 		return resp.ErrorValue("ERR wrong number of arguments for 'incr' command")
 	}
-	result := incrBy(args[0], 1)
+	result, expiry := incrBy(args[0], 1)
 	if result.Type != resp.Error {
 		// Log the resulting SET command for idempotent replay
-		logCommand("SET", args[0], strconv.FormatInt(result.Int, 10))
+		logSet(args[0], strconv.FormatInt(result.Int, 10), expiry)
 	}
 	return result
 }
@@ -199,10 +403,10 @@ func Decr(args []string) resp.Value {
 	if len(args) != 1 {
 		return resp.ErrorValue("ERR wrong number of arguments for 'decr' command")
 	}
-	result := incrBy(args[0], -1)
+	result, expiry := incrBy(args[0], -1)
 	if result.Type != resp.Error {
 		// Log the resulting SET command for idempotent replay
-		logCommand("SET", args[0], strconv.FormatInt(result.Int, 10))
+		logSet(args[0], strconv.FormatInt(result.Int, 10), expiry)
 	}
 	return result
 }
@@ -218,32 +422,33 @@ func IncrBy(args []string) resp.Value {
 		return resp.ErrorValue("ERR value is not an integer or out of range")
 	}
 
-	result := incrBy(args[0], delta)
+	result, expiry := incrBy(args[0], delta)
 	if result.Type != resp.Error {
 		// Log the resulting SET command for idempotent replay
-		logCommand("SET", args[0], strconv.FormatInt(result.Int, 10))
+		logSet(args[0], strconv.FormatInt(result.Int, 10), expiry)
 	}
 	return result
 }
 
-// incrBy is the internal helper for INCR/DECR/INCRBY
-func incrBy(key string, delta int64) resp.Value {
+// incrBy is the internal helper for INCR/DECR/INCRBY. The key keeps its
+// expiry, which is returned for logging.
+func incrBy(key string, delta int64) (resp.Value, time.Time) {
 	entry, ok := Store.Get(key)
 
 	var current int64 = 0
 	if ok {
 		if entry.Type != store.StringType {
-			return resp.ErrorValue("WRONGTYPE Operation against a key holding the wrong kind of value")
+			return resp.ErrorValue("WRONGTYPE Operation against a key holding the wrong kind of value"), time.Time{}
 		}
 		val, err := strconv.ParseInt(entry.Value.(string), 10, 64)
 		if err != nil {
-			return resp.ErrorValue("ERR value is not an integer or out of range")
+			return resp.ErrorValue("ERR value is not an integer or out of range"), time.Time{}
 		}
 		current = val
 	}
 
 	newVal := current + delta
-	Store.Set(key, store.StringType, strconv.FormatInt(newVal, 10))
+	expiry := Store.SetKeepTTL(key, store.StringType, strconv.FormatInt(newVal, 10))
 
-	return resp.IntValue(newVal)
+	return resp.IntValue(newVal), expiry
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/Eahtasham/go-redis/internal/commands"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// WATCH key [key ...]
+// Mark keys to be watched, the next EXEC aborts if any of them changes
+func Watch(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'watch' command")
+	}
+	if ctx == nil {
+		return resp.ErrorValue("ERR WATCH isn't allowed in this context")
+	}
+
+	if ctx.Watch == nil {
+		ctx.Watch = Store.NewWatch()
+	}
+	ctx.Watch.Add(args)
+
+	return resp.SimpleValue("OK")
+}
+
+// UNWATCH
+// Forget all watched keys
+func Unwatch(ctx *commands.ClientContext, args []string) resp.Value {
+	if len(args) != 0 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'unwatch' command")
+	}
+
+	if ctx != nil && ctx.Watch != nil {
+		ctx.Watch.Reset()
+	}
+
+	return resp.SimpleValue("OK")
+}
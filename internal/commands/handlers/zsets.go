@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// parseScore parses a score, accepting inf/+inf/-inf but not NaN
+func parseScore(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// formatScore formats a score the way Redis replies with it
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// parseScoreRange parses ZRANGEBYSCORE style bounds like "1", "(1" or "-inf"
+func parseScoreRange(min, max string) (store.ScoreRange, bool) {
+	var r store.ScoreRange
+	var ok bool
+
+	if strings.HasPrefix(min, "(") {
+		r.MinEx = true
+		min = min[1:]
+	}
+	if r.Min, ok = parseScore(min); !ok {
+		return r, false
+	}
+
+	if strings.HasPrefix(max, "(") {
+		r.MaxEx = true
+		max = max[1:]
+	}
+	if r.Max, ok = parseScore(max); !ok {
+		return r, false
+	}
+
+	return r, true
+}
+
+// parseLexRange parses ZRANGEBYLEX style bounds like "[a", "(a", "-" or "+"
+func parseLexRange(min, max string) (store.LexRange, bool) {
+	var r store.LexRange
+
+	switch {
+	case min == "-":
+		r.MinInf = true
+	case min == "+":
+		// Nothing sorts after +, so the range is empty
+		r.Min, r.Max, r.MinEx = "", "", true
+		return r, true
+	case strings.HasPrefix(min, "["):
+		r.Min = min[1:]
+	case strings.HasPrefix(min, "("):
+		r.Min, r.MinEx = min[1:], true
+	default:
+		return r, false
+	}
+
+	switch {
+	case max == "+":
+		r.MaxInf = true
+	case max == "-":
+		// Nothing sorts before -, so the range is empty
+		r.MinInf, r.Min, r.Max, r.MaxEx = false, "", "", true
+		return r, true
+	case strings.HasPrefix(max, "["):
+		r.Max = max[1:]
+	case strings.HasPrefix(max, "("):
+		r.Max, r.MaxEx = max[1:], true
+	default:
+		return r, false
+	}
+
+	return r, true
+}
+
+// scoredMembersValue converts members into a flat array, optionally
+// interleaving each member with its score
+func scoredMembersValue(members []store.ScoredMember, withScores bool) resp.Value {
+	result := make([]resp.Value, 0, len(members))
+	for _, m := range members {
+		result = append(result, resp.BulkValue(m.Member))
+		if withScores {
+			result = append(result, resp.BulkValue(formatScore(m.Score)))
+		}
+	}
+	return resp.ArrayValue(result)
+}
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+// Add members to a sorted set or update their scores
+func ZAdd(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zadd' command")
+	}
+
+	key := args[0]
+	var opts store.ZAddOptions
+	ch := false
+
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			ch = true
+		case "INCR":
+			opts.Incr = true
+		default:
+			break flags
+		}
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	if opts.NX && opts.XX {
+		return resp.ErrorValue("ERR XX and NX options at the same time are not compatible")
+	}
+	if (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)) {
+		return resp.ErrorValue("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if opts.Incr && len(rest) != 2 {
+		return resp.ErrorValue("ERR INCR option supports a single increment-element pair")
+	}
+
+	members := make([]store.ScoredMember, 0, len(rest)/2)
+	for j := 0; j < len(rest); j += 2 {
+		score, ok := parseScore(rest[j])
+		if !ok {
+			return resp.ErrorValue("ERR value is not a valid float")
+		}
+		members = append(members, store.ScoredMember{Member: rest[j+1], Score: score})
+	}
+
+	result, err := Store.ZAdd(key, opts, members)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if opts.Incr {
+		if result.Skipped {
+			return resp.NullBulkValue()
+		}
+
+		// Log the resulting score for idempotent replay
+		logCommand("ZADD", key, formatScore(result.Score), members[0].Member)
+
+		return resp.DoubleValue(result.Score)
+	}
+
+	if result.Added+result.Updated > 0 {
+		logCommand("ZADD", args...)
+	}
+
+	if ch {
+		return resp.IntValue(result.Added + result.Updated)
+	}
+	return resp.IntValue(result.Added)
+}
+
+// ZINCRBY key increment member
+// Increment the score of a member
+func ZIncrBy(args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zincrby' command")
+	}
+
+	delta, ok := parseScore(args[1])
+	if !ok {
+		return resp.ErrorValue("ERR value is not a valid float")
+	}
+
+	member := store.ScoredMember{Member: args[2], Score: delta}
+	result, err := Store.ZAdd(args[0], store.ZAddOptions{Incr: true}, []store.ScoredMember{member})
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	// Log the resulting score for idempotent replay
+	logCommand("ZADD", args[0], formatScore(result.Score), args[2])
+
+	return resp.DoubleValue(result.Score)
+}
+
+// ZREM key member [member ...]
+// Remove members from a sorted set
+func ZRem(args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrem' command")
+	}
+
+	removed, err := Store.ZRem(args[0], args[1:])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if removed > 0 {
+		logCommand("ZREM", args...)
+	}
+
+	return resp.IntValue(removed)
+}
+
+// ZSCORE key member
+// Get the score of a member
+func ZScore(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zscore' command")
+	}
+
+	score, exists, err := Store.ZScore(args[0], args[1])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if !exists {
+		return resp.NullBulkValue()
+	}
+
+	return resp.DoubleValue(score)
+}
+
+// ZCARD key
+// Get the number of members in a sorted set
+func ZCard(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zcard' command")
+	}
+
+	count, err := Store.ZCard(args[0])
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	return resp.IntValue(count)
+}
+
+// ZRANK key member
+// Get the rank of a member, ordered from the lowest score
+func ZRank(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrank' command")
+	}
+	return zrank(args[0], args[1], false)
+}
+
+// ZREVRANK key member
+// Get the rank of a member, ordered from the highest score
+func ZRevRank(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrevrank' command")
+	}
+	return zrank(args[0], args[1], true)
+}
+
+func zrank(key, member string, reverse bool) resp.Value {
+	rank, exists, err := Store.ZRank(key, member, reverse)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if !exists {
+		return resp.NullBulkValue()
+	}
+
+	return resp.IntValue(rank)
+}
+
+// zrangeMode selects how ZRANGE interprets its start and stop arguments
+type zrangeMode int
+
+const (
+	zrangeByRank zrangeMode = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+// Get a range of members by rank, score or lexicographical order
+func ZRange(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrange' command")
+	}
+	return zrangeGeneric(args, zrangeByRank, false, true)
+}
+
+// ZREVRANGE key start stop [WITHSCORES]
+// Get a range of members by rank, ordered from the highest score
+func ZRevRange(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrevrange' command")
+	}
+	return zrangeGeneric(args, zrangeByRank, true, false)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+// Get members with scores within a range
+func ZRangeByScore(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrangebyscore' command")
+	}
+	return zrangeGeneric(args, zrangeByScore, false, false)
+}
+
+// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+// Get members with scores within a range, ordered from the highest score
+func ZRevRangeByScore(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zrevrangebyscore' command")
+	}
+	return zrangeGeneric(args, zrangeByScore, true, false)
+}
+
+// zrangeGeneric implements the ZRANGE family. When parseMode is set the
+// BYSCORE/BYLEX/REV options are accepted, as in the unified ZRANGE syntax.
+func zrangeGeneric(args []string, mode zrangeMode, reverse, parseMode bool) resp.Value {
+	key, start, stop := args[0], args[1], args[2]
+
+	withScores := false
+	hasLimit := false
+	offset, count := 0, -1
+
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "WITHSCORES":
+			withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return resp.ErrorValue("ERR value is not an integer or out of range")
+			}
+			hasLimit = true
+			i += 2
+		case parseMode && opt == "BYSCORE":
+			mode = zrangeByScore
+		case parseMode && opt == "BYLEX":
+			mode = zrangeByLex
+		case parseMode && opt == "REV":
+			reverse = true
+		default:
+			return resp.ErrorValue("ERR syntax error")
+		}
+	}
+
+	if hasLimit && mode == zrangeByRank {
+		return resp.ErrorValue("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && mode == zrangeByLex {
+		return resp.ErrorValue("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// Reversed score and lex ranges take the upper bound first
+	if reverse && mode != zrangeByRank {
+		start, stop = stop, start
+	}
+
+	// A negative offset yields an empty result in Redis
+	if offset < 0 {
+		return resp.ArrayValue([]resp.Value{})
+	}
+
+	var members []store.ScoredMember
+	var err error
+
+	switch mode {
+	case zrangeByScore:
+		r, ok := parseScoreRange(start, stop)
+		if !ok {
+			return resp.ErrorValue("ERR min or max is not a float")
+		}
+		members, err = Store.ZRangeByScore(key, r, reverse, offset, count)
+	case zrangeByLex:
+		r, ok := parseLexRange(start, stop)
+		if !ok {
+			return resp.ErrorValue("ERR min or max not valid string range item")
+		}
+		members, err = Store.ZRangeByLex(key, r, reverse, offset, count)
+	default:
+		startIdx, err1 := strconv.Atoi(start)
+		stopIdx, err2 := strconv.Atoi(stop)
+		if err1 != nil || err2 != nil {
+			return resp.ErrorValue("ERR value is not an integer or out of range")
+		}
+		members, err = Store.ZRangeByRank(key, startIdx, stopIdx, reverse)
+	}
+
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	return scoredMembersValue(members, withScores)
+}
+
+// ZCOUNT key min max
+// Count members with scores within a range
+func ZCount(args []string) resp.Value {
+	if len(args) != 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zcount' command")
+	}
+
+	r, ok := parseScoreRange(args[1], args[2])
+	if !ok {
+		return resp.ErrorValue("ERR min or max is not a float")
+	}
+
+	count, err := Store.ZCount(args[0], r)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	return resp.IntValue(count)
+}
+
+// ZPOPMIN key [count]
+// Remove and return the members with the lowest scores
+func ZPopMin(args []string) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zpopmin' command")
+	}
+	return zpop(args, false)
+}
+
+// ZPOPMAX key [count]
+// Remove and return the members with the highest scores
+func ZPopMax(args []string) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zpopmax' command")
+	}
+	return zpop(args, true)
+}
+
+func zpop(args []string, max bool) resp.Value {
+	key := args[0]
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(args[1])
+		if err != nil {
+			return resp.ErrorValue("ERR value is not an integer or out of range")
+		}
+		if count < 0 {
+			return resp.ErrorValue("ERR value is out of range, must be positive")
+		}
+	}
+
+	popped, err := Store.ZPop(key, count, max)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	if len(popped) > 0 {
+		// Log the removed members so replay does not depend on ordering
+		remArgs := make([]string, 0, len(popped)+1)
+		remArgs = append(remArgs, key)
+		for _, m := range popped {
+			remArgs = append(remArgs, m.Member)
+		}
+		logCommand("ZREM", remArgs...)
+	}
+
+	return scoredMembersValue(popped, true)
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX]
+// Store the union of sorted sets
+func ZUnionStore(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zunionstore' command")
+	}
+	return zsetStore("zunionstore", args, Store.ZUnionStore)
+}
+
+// ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX]
+// Store the intersection of sorted sets
+func ZInterStore(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'zinterstore' command")
+	}
+	return zsetStore("zinterstore", args, Store.ZInterStore)
+}
+
+// zstoreKeys finds the keys of ZUNIONSTORE and ZINTERSTORE: the destination
+// and the numkeys sources. A bad numkeys leaves only the destination, the
+// command then fails on its own.
+func zstoreKeys(args []string) []string {
+	if len(args) < 2 {
+		return args
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 1 || numKeys > len(args)-2 {
+		return args[:1]
+	}
+	return append([]string{args[0]}, args[2:2+numKeys]...)
+}
+
+func zsetStore(name string, args []string, op func(string, []string, []float64, store.Aggregate) (int64, error)) resp.Value {
+	dest := args[0]
+
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.ErrorValue("ERR value is not an integer or out of range")
+	}
+	if numKeys < 1 {
+		return resp.ErrorValue("ERR at least 1 input key is needed for '" + name + "' command")
+	}
+	if numKeys > len(args)-2 {
+		return resp.ErrorValue("ERR syntax error")
+	}
+
+	keys := args[2 : 2+numKeys]
+	var weights []float64
+	agg := store.AggregateSum
+
+	for i := 2 + numKeys; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WEIGHTS":
+			if i+numKeys >= len(args) {
+				return resp.ErrorValue("ERR syntax error")
+			}
+			weights = make([]float64, numKeys)
+			for j := 0; j < numKeys; j++ {
+				w, ok := parseScore(args[i+1+j])
+				if !ok {
+					return resp.ErrorValue("ERR weight value is not a float")
+				}
+				weights[j] = w
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(args) {
+				return resp.ErrorValue("ERR syntax error")
+			}
+			switch strings.ToUpper(args[i+1]) {
+			case "SUM":
+				agg = store.AggregateSum
+			case "MIN":
+				agg = store.AggregateMin
+			case "MAX":
+				agg = store.AggregateMax
+			default:
+				return resp.ErrorValue("ERR syntax error")
+			}
+			i++
+		default:
+			return resp.ErrorValue("ERR syntax error")
+		}
+	}
+
+	count, err := op(dest, keys, weights, agg)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	// The result only depends on the source keys, so replaying it is safe
+	logCommand(strings.ToUpper(name), args...)
+
+	return resp.IntValue(count)
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"sync/atomic"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// loading is set while the dataset is being restored from disk. Client
+// commands are rejected with -LOADING until it is cleared.
+var loading atomic.Bool
+
+// SetLoading enters or leaves the LOADING state
+func SetLoading(on bool) {
+	loading.Store(on)
+}
+
+// Loading reports whether the dataset is still being restored. Handlers
+// don't log to the AOF while loading, everything they run comes from it.
+func Loading() bool {
+	return loading.Load()
+}
+
+// Load applies a command read back from persistence, without a client.
+// Only valid while loading, so the command isn't appended to the AOF again.
+func Load(v resp.Value) resp.Value {
+	execMu.RLock()
+	defer execMu.RUnlock()
+
+	reply, _ := dispatch(v, nil)
+	return reply
+}
+
+// checkLoading returns the -LOADING error for commands that need the
+// dataset while it is still being restored
+func checkLoading(name string) (resp.Value, bool) {
+	if !Loading() || handlers[name].flags&FlagLoadingOK != 0 {
+		return resp.Value{}, true
+	}
+	return resp.ErrorValue("LOADING Redis is loading the dataset in memory"), false
+}
@@ -8,13 +8,190 @@ import (
 
 type Handler func(args []string) resp.Value
 
-var handlers = map[string]Handler{}
+// ClientHandler is a handler that needs the calling client's context,
+// e.g. blocking commands that have to notice a closed connection
+type ClientHandler func(ctx *ClientContext, args []string) resp.Value
 
-func Register(name string, h Handler) {
-	handlers[strings.ToUpper(name)] = h
+// Flags describe how a command behaves, so the dispatcher can gate it
+type Flags uint8
+
+const (
+	FlagWrite     Flags = 1 << iota // may modify the dataset
+	FlagLoadingOK                   // allowed while the dataset is loading
+	FlagAsking                      // runs as if sent after ASKING, like RESTORE-ASKING
+)
+
+type command struct {
+	handler ClientHandler
+	arity   int // Redis style: exact argc including the name, -N means at least N
+	flags   Flags
+	keys    func(args []string) []string // the key arguments, nil for keyless commands
+}
+
+var handlers = map[string]command{}
+
+func Register(name string, arity int, flags Flags, h Handler) {
+	RegisterClient(name, arity, flags, func(_ *ClientContext, args []string) resp.Value {
+		return h(args)
+	})
+}
+
+func RegisterClient(name string, arity int, flags Flags, h ClientHandler) {
+	handlers[strings.ToUpper(name)] = command{handler: h, arity: arity, flags: flags}
+}
+
+// IsWrite reports whether the named command may modify the dataset
+func IsWrite(name string) bool {
+	return handlers[strings.ToUpper(name)].flags&FlagWrite != 0
+}
+
+func Get(name string) (ClientHandler, bool) {
+	c, ok := handlers[strings.ToUpper(name)]
+	return c.handler, ok
+}
+
+// validate checks that a command exists and is called with a valid number
+// of arguments, returning the error reply otherwise
+func validate(cmd Command) (resp.Value, bool) {
+	c, ok := handlers[cmd.Name]
+	if !ok {
+		return resp.ErrorValue("ERR unknown command '" + cmd.Name + "'"), false
+	}
+
+	argc := len(cmd.Args) + 1
+	if (c.arity > 0 && argc != c.arity) || (c.arity < 0 && argc < -c.arity) {
+		return resp.ErrorValue("ERR wrong number of arguments for '" + strings.ToLower(cmd.Name) + "' command"), false
+	}
+
+	return resp.Value{}, true
+}
+
+// SetKeys records where the keys of the named commands are in their
+// arguments, Redis style: first and last are positions counted from the
+// command name (negative counts back from the end) and step the distance
+// between keys. Must be called after the commands are registered.
+func SetKeys(first, last, step int, names ...string) {
+	for _, name := range names {
+		SetKeysFunc(name, func(args []string) []string {
+			end := last
+			if end < 0 {
+				end += len(args) + 1
+			}
+			var keys []string
+			for i := first; i <= end && i <= len(args); i += step {
+				keys = append(keys, args[i-1])
+			}
+			return keys
+		})
+	}
+}
+
+// SetKeysFunc records how to find the keys of a command whose key positions
+// depend on its arguments, e.g. ZUNIONSTORE's numkeys
+func SetKeysFunc(name string, fn func(args []string) []string) {
+	name = strings.ToUpper(name)
+	c := handlers[name]
+	c.keys = fn
+	handlers[name] = c
+}
+
+// Keys returns the keys a command touches
+func Keys(name string, args []string) []string {
+	c := handlers[strings.ToUpper(name)]
+	if c.keys == nil {
+		return nil
+	}
+	return c.keys(args)
+}
+
+var router func(keys []string, asking bool) error
+
+// SetRouter registers a check run on the keys of every command from
+// clients, e.g. to redirect clients to the cluster node owning the keys.
+// When it returns an error the command is refused with that error. asking
+// is set when the client sent ASKING just before the command.
+func SetRouter(fn func(keys []string, asking bool) error) {
+	router = fn
+}
+
+// checkRoute returns the error reply when a command's keys are served
+// elsewhere
+func checkRoute(cmd Command, asking bool) (resp.Value, bool) {
+	if router == nil {
+		return resp.Value{}, true
+	}
+	keys := Keys(cmd.Name, cmd.Args)
+	if len(keys) == 0 {
+		return resp.Value{}, true
+	}
+	if err := router(keys, asking); err != nil {
+		return resp.ErrorValue(err.Error()), false
+	}
+	return resp.Value{}, true
+}
+
+var writeGuard func() error
+
+// SetWriteGuard registers a check run before write commands from clients.
+// When it returns an error the command is refused with that error.
+func SetWriteGuard(fn func() error) {
+	writeGuard = fn
+}
+
+// checkWrite returns the error reply when a write command must be refused
+func checkWrite(name string) (resp.Value, bool) {
+	if writeGuard == nil || !IsWrite(name) {
+		return resp.Value{}, true
+	}
+	if err := writeGuard(); err != nil {
+		return resp.ErrorValue(err.Error()), false
+	}
+	return resp.Value{}, true
+}
+
+var execHooks struct {
+	begin, end func()
+}
+
+// SetExecHooks registers functions called before and after the queued
+// commands of an EXEC run, e.g. to wrap their AOF records in MULTI/EXEC
+func SetExecHooks(begin, end func()) {
+	execHooks.begin = begin
+	execHooks.end = end
+}
+
+var syncHooks struct {
+	mark func() int64
+	wait func(int64) error
+}
+
+// SetSyncHooks registers how replies wait for writes to reach the disk.
+// mark is called under the write lock once a command logged its writes,
+// wait with that mark after the locks are released, so the writes of other
+// clients can share the fsync. When wait returns an error the command's
+// reply is replaced with it.
+func SetSyncHooks(mark func() int64, wait func(int64) error) {
+	syncHooks.mark = mark
+	syncHooks.wait = wait
+}
+
+// syncMark returns the mark covering the writes logged so far, 0 without
+// hooks
+func syncMark() int64 {
+	if syncHooks.mark == nil {
+		return 0
+	}
+	return syncHooks.mark()
 }
 
-func Get(name string) (Handler, bool) {
-	h, ok := handlers[strings.ToUpper(name)]
-	return h, ok
+// waitSynced waits for the writes up to mark, 0 waits for nothing. It
+// returns reply, or the error reply when the writes didn't make it to disk.
+func waitSynced(mark int64, reply resp.Value) resp.Value {
+	if mark == 0 || syncHooks.wait == nil {
+		return reply
+	}
+	if err := syncHooks.wait(mark); err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	return reply
 }
@@ -0,0 +1,292 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// How many undelivered messages a subscriber may have queued before it is
+// considered too slow and disconnected, so publishers never block on it
+const subscriberQueueSize = 1024
+
+// Message is a frame pushed to a subscriber: a published message or a
+// (un)subscribe confirmation
+type Message struct {
+	Kind    string // message, pmessage, subscribe, unsubscribe, psubscribe, punsubscribe
+	Pattern string // set for pmessage
+	Channel string
+	Payload string
+	Count   int // subscriptions left, for confirmations
+	NoName  bool
+}
+
+// Value encodes the message the way Redis pushes it to subscribers
+func (m Message) Value() resp.Value {
+	switch m.Kind {
+	case "message":
+		return resp.PushValue([]resp.Value{
+			resp.BulkValue(m.Kind),
+			resp.BulkValue(m.Channel),
+			resp.BulkValue(m.Payload),
+		})
+	case "pmessage":
+		return resp.PushValue([]resp.Value{
+			resp.BulkValue(m.Kind),
+			resp.BulkValue(m.Pattern),
+			resp.BulkValue(m.Channel),
+			resp.BulkValue(m.Payload),
+		})
+	}
+
+	channel := resp.BulkValue(m.Channel)
+	if m.NoName {
+		channel = resp.NullBulkValue()
+	}
+
+	return resp.PushValue([]resp.Value{
+		resp.BulkValue(m.Kind),
+		channel,
+		resp.IntValue(int64(m.Count)),
+	})
+}
+
+// Subscriber is the pub/sub state of one client connection
+type Subscriber struct {
+	broker   *Broker
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	messages chan Message
+	dropped  chan struct{}
+	done     chan struct{}
+	dropOnce sync.Once
+	doneOnce sync.Once
+}
+
+// Messages delivers published messages in order
+func (sub *Subscriber) Messages() <-chan Message {
+	return sub.messages
+}
+
+// Dropped is closed when the subscriber fell too far behind and should be disconnected
+func (sub *Subscriber) Dropped() <-chan struct{} {
+	return sub.dropped
+}
+
+// Done is closed once the subscriber has been closed
+func (sub *Subscriber) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Count returns the number of channels and patterns the subscriber listens to
+func (sub *Subscriber) Count() int {
+	sub.broker.mu.RLock()
+	defer sub.broker.mu.RUnlock()
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// Close removes every subscription, called when the client disconnects
+func (sub *Subscriber) Close() {
+	sub.broker.Unsubscribe(sub, nil)
+	sub.broker.PUnsubscribe(sub, nil)
+	sub.doneOnce.Do(func() { close(sub.done) })
+}
+
+// deliver queues msg without ever blocking the publisher
+func (sub *Subscriber) deliver(msg Message) {
+	select {
+	case sub.messages <- msg:
+	default:
+		sub.dropOnce.Do(func() { close(sub.dropped) })
+	}
+}
+
+// Broker routes published messages to channel and pattern subscribers
+type Broker struct {
+	mu       sync.RWMutex
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+// NewSubscriber creates an empty subscriber for one client
+func (b *Broker) NewSubscriber() *Subscriber {
+	return &Subscriber{
+		broker:   b,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		messages: make(chan Message, subscriberQueueSize),
+		dropped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Subscribe adds channel subscriptions and returns one confirmation per channel
+func (b *Broker) Subscribe(sub *Subscriber, channels []string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	confirms := make([]Message, 0, len(channels))
+	for _, ch := range channels {
+		if _, ok := sub.channels[ch]; !ok {
+			sub.channels[ch] = struct{}{}
+			addSubscriber(b.channels, ch, sub)
+		}
+		confirms = append(confirms, Message{Kind: "subscribe", Channel: ch, Count: len(sub.channels) + len(sub.patterns)})
+	}
+	return confirms
+}
+
+// Unsubscribe removes channel subscriptions, all of them when channels is empty
+func (b *Broker) Unsubscribe(sub *Subscriber, channels []string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(channels) == 0 {
+		channels = sortedKeys(sub.channels)
+		if len(channels) == 0 {
+			return []Message{{Kind: "unsubscribe", NoName: true, Count: len(sub.patterns)}}
+		}
+	}
+
+	confirms := make([]Message, 0, len(channels))
+	for _, ch := range channels {
+		if _, ok := sub.channels[ch]; ok {
+			delete(sub.channels, ch)
+			removeSubscriber(b.channels, ch, sub)
+		}
+		confirms = append(confirms, Message{Kind: "unsubscribe", Channel: ch, Count: len(sub.channels) + len(sub.patterns)})
+	}
+	return confirms
+}
+
+// PSubscribe adds pattern subscriptions and returns one confirmation per pattern
+func (b *Broker) PSubscribe(sub *Subscriber, patterns []string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	confirms := make([]Message, 0, len(patterns))
+	for _, p := range patterns {
+		if _, ok := sub.patterns[p]; !ok {
+			sub.patterns[p] = struct{}{}
+			addSubscriber(b.patterns, p, sub)
+		}
+		confirms = append(confirms, Message{Kind: "psubscribe", Channel: p, Count: len(sub.channels) + len(sub.patterns)})
+	}
+	return confirms
+}
+
+// PUnsubscribe removes pattern subscriptions, all of them when patterns is empty
+func (b *Broker) PUnsubscribe(sub *Subscriber, patterns []string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(patterns) == 0 {
+		patterns = sortedKeys(sub.patterns)
+		if len(patterns) == 0 {
+			return []Message{{Kind: "punsubscribe", NoName: true, Count: len(sub.channels)}}
+		}
+	}
+
+	confirms := make([]Message, 0, len(patterns))
+	for _, p := range patterns {
+		if _, ok := sub.patterns[p]; ok {
+			delete(sub.patterns, p)
+			removeSubscriber(b.patterns, p, sub)
+		}
+		confirms = append(confirms, Message{Kind: "punsubscribe", Channel: p, Count: len(sub.channels) + len(sub.patterns)})
+	}
+	return confirms
+}
+
+// Publish sends payload to every subscriber of channel and of any matching
+// pattern, returns the number of deliveries
+func (b *Broker) Publish(channel, payload string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	receivers := 0
+	for sub := range b.channels[channel] {
+		sub.deliver(Message{Kind: "message", Channel: channel, Payload: payload})
+		receivers++
+	}
+
+	for pattern, subs := range b.patterns {
+		if !Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			sub.deliver(Message{Kind: "pmessage", Pattern: pattern, Channel: channel, Payload: payload})
+			receivers++
+		}
+	}
+
+	return receivers
+}
+
+// Channels returns the active channels, optionally filtered by a glob pattern
+func (b *Broker) Channels(pattern string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := []string{}
+	for ch := range b.channels {
+		if pattern == "" || Match(pattern, ch) {
+			result = append(result, ch)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// NumSub returns the number of subscribers of each channel
+func (b *Broker) NumSub(channels []string) []int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	counts := make([]int, len(channels))
+	for i, ch := range channels {
+		counts[i] = len(b.channels[ch])
+	}
+	return counts
+}
+
+// NumPat returns the number of distinct patterns subscribed to
+func (b *Broker) NumPat() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.patterns)
+}
+
+func addSubscriber(index map[string]map[*Subscriber]struct{}, name string, sub *Subscriber) {
+	subs, ok := index[name]
+	if !ok {
+		subs = make(map[*Subscriber]struct{})
+		index[name] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+func removeSubscriber(index map[string]map[*Subscriber]struct{}, name string, sub *Subscriber) {
+	subs := index[name]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(index, name)
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
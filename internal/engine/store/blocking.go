@@ -0,0 +1,240 @@
+package store
+
+// ListPop describes an element handed to a client by a (blocking) list pop
+// or move. Dest is empty for plain pops.
+type ListPop struct {
+	Key      string
+	Value    string
+	FromLeft bool
+	Dest     string
+	ToLeft   bool
+	Err      error // set when the move could not be applied, e.g. WRONGTYPE
+}
+
+// ListWaiter is a client parked on one or more empty lists by BLPOP,
+// BRPOP or BLMOVE. Waiters are served in the order they started waiting.
+type ListWaiter struct {
+	keys     []string
+	fromLeft bool
+	dest     string
+	toLeft   bool
+	move     bool
+	ch       chan ListPop
+}
+
+// C delivers the element once the waiter has been served
+func (w *ListWaiter) C() <-chan ListPop {
+	return w.ch
+}
+
+// ==================== BLOCKING LIST OPERATIONS ====================
+
+// PopOrWait pops from the first non-empty list in keys. When every list is
+// empty and block is set, a waiter is registered and returned instead; the
+// caller must then receive from it or call CancelWait.
+func (s *Store) PopOrWait(keys []string, fromLeft, block bool) (*ListPop, *ListWaiter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		list, err := s.listForRead(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(list) > 0 {
+			value := s.popListLocked(key, fromLeft)
+			return &ListPop{Key: key, Value: value, FromLeft: fromLeft}, nil, nil
+		}
+	}
+
+	if !block {
+		return nil, nil, nil
+	}
+
+	w := &ListWaiter{keys: keys, fromLeft: fromLeft, ch: make(chan ListPop, 1)}
+	s.addWaiterLocked(w)
+	return nil, w, nil
+}
+
+// LMove atomically pops an element from src and pushes it to dest
+func (s *Store) LMove(src, dest string, fromLeft, toLeft bool) (*ListPop, error) {
+	pop, _, err := s.MoveOrWait(src, dest, fromLeft, toLeft, false)
+	return pop, err
+}
+
+// MoveOrWait is the LMOVE counterpart of PopOrWait
+func (s *Store) MoveOrWait(src, dest string, fromLeft, toLeft, block bool) (*ListPop, *ListWaiter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.listForRead(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(list) > 0 {
+		if _, err := s.listForRead(dest); err != nil {
+			return nil, nil, err
+		}
+		value := s.popListLocked(src, fromLeft)
+		s.pushListLocked(dest, value, toLeft)
+		return &ListPop{Key: src, Value: value, FromLeft: fromLeft, Dest: dest, ToLeft: toLeft}, nil, nil
+	}
+
+	if !block {
+		return nil, nil, nil
+	}
+
+	w := &ListWaiter{
+		keys:     []string{src},
+		fromLeft: fromLeft,
+		dest:     dest,
+		toLeft:   toLeft,
+		move:     true,
+		ch:       make(chan ListPop, 1),
+	}
+	s.addWaiterLocked(w)
+	return nil, w, nil
+}
+
+// CancelWait removes a waiter that timed out or whose client went away.
+// It returns false if the waiter was served concurrently, in which case
+// the element is waiting on w.C().
+func (s *Store) CancelWait(w *ListWaiter) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.removeWaiterLocked(w)
+}
+
+// ServeListWaiters hands elements of key to parked clients in FIFO order.
+// It is called after a push so the caller can log the push first and the
+// returned pops right after it, keeping the AOF in execution order.
+func (s *Store) ServeListWaiters(key string) []ListPop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var served []ListPop
+	ready := []string{key}
+
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+
+		for len(s.listWaiters[key]) > 0 {
+			list, err := s.listForRead(key)
+			if err != nil || len(list) == 0 {
+				break
+			}
+
+			w := s.listWaiters[key][0]
+			s.removeWaiterLocked(w)
+
+			pop := ListPop{Key: key, FromLeft: w.fromLeft}
+			if w.move {
+				pop.Dest, pop.ToLeft = w.dest, w.toLeft
+				if _, err := s.listForRead(w.dest); err != nil {
+					pop.Err = err
+					w.ch <- pop
+					continue
+				}
+			}
+
+			pop.Value = s.popListLocked(key, w.fromLeft)
+			if w.move {
+				s.pushListLocked(w.dest, pop.Value, w.toLeft)
+				// The destination may have clients waiting on it too
+				ready = append(ready, w.dest)
+			}
+
+			served = append(served, pop)
+			w.ch <- pop
+		}
+	}
+
+	return served
+}
+
+// listForRead returns the live list at key (nil if missing), caller holds mu
+func (s *Store) listForRead(key string) ([]string, error) {
+	e, ok := s.get(key)
+	if !ok {
+		return nil, nil
+	}
+
+	if e.Type != ListType {
+		return nil, ErrWrongType
+	}
+
+	return e.Value.([]string), nil
+}
+
+// popListLocked removes one element from a non-empty list, caller holds mu
+func (s *Store) popListLocked(key string, fromLeft bool) string {
+	e := s.data[key]
+	list := e.Value.([]string)
+
+	var value string
+	if fromLeft {
+		value, list = list[0], list[1:]
+	} else {
+		value, list = list[len(list)-1], list[:len(list)-1]
+	}
+
+	if len(list) == 0 {
+		delete(s.data, key)
+	} else {
+		e.Value = list
+	}
+	s.touch(key)
+
+	return value
+}
+
+// pushListLocked adds one element to a list, creating it if needed, caller holds mu
+func (s *Store) pushListLocked(key, value string, toLeft bool) {
+	s.touch(key)
+
+	e, ok := s.get(key)
+	if !ok {
+		s.data[key] = &Entry{Type: ListType, Value: []string{value}}
+		return
+	}
+
+	list := e.Value.([]string)
+	if toLeft {
+		list = append([]string{value}, list...)
+	} else {
+		list = append(list, value)
+	}
+	e.Value = list
+}
+
+func (s *Store) addWaiterLocked(w *ListWaiter) {
+	for _, key := range w.keys {
+		s.listWaiters[key] = append(s.listWaiters[key], w)
+	}
+}
+
+// removeWaiterLocked unlinks w from every key it waits on, returns false
+// if it was no longer registered
+func (s *Store) removeWaiterLocked(w *ListWaiter) bool {
+	found := false
+	for _, key := range w.keys {
+		queue := s.listWaiters[key]
+		for i, other := range queue {
+			if other == w {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				found = true
+				break
+			}
+		}
+
+		if len(queue) == 0 {
+			delete(s.listWaiters, key)
+		} else {
+			s.listWaiters[key] = queue
+		}
+	}
+	return found
+}
@@ -4,4 +4,11 @@ import "errors"
 
 var (
 	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrKeyExists = errors.New("BUSYKEY Target key name already exists.")
+
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+	ErrOverflow       = errors.New("ERR increment or decrement would overflow")
+	ErrNaNOrInfinity  = errors.New("ERR increment would produce NaN or Infinity")
+	ErrScoreNaN       = errors.New("ERR resulting score is not a number (NaN)")
 )
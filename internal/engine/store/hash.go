@@ -0,0 +1,298 @@
+package store
+
+import (
+	"math"
+	"strconv"
+)
+
+// ==================== ATOMIC HASH OPERATIONS ====================
+
+// hashForWrite returns the hash stored at key, creating it if missing
+func (s *Store) hashForWrite(key string) (map[string]string, error) {
+	e, ok := s.get(key)
+	if !ok {
+		hash := make(map[string]string)
+		s.data[key] = &Entry{Type: HashType, Value: hash}
+		return hash, nil
+	}
+
+	if e.Type != HashType {
+		return nil, ErrWrongType
+	}
+
+	return e.Value.(map[string]string), nil
+}
+
+// hashForRead returns the hash stored at key, or nil if missing
+func (s *Store) hashForRead(key string) (map[string]string, error) {
+	e, ok := s.peek(key)
+	if !ok {
+		return nil, nil
+	}
+
+	if e.Type != HashType {
+		return nil, ErrWrongType
+	}
+
+	return e.Value.(map[string]string), nil
+}
+
+// HSet atomically sets field/value pairs in a hash, returns count of new fields
+func (s *Store) HSet(key string, pairs []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.hashForWrite(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := int64(0)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if _, exists := hash[pairs[i]]; !exists {
+			added++
+		}
+		hash[pairs[i]] = pairs[i+1]
+	}
+	s.touch(key)
+
+	return added, nil
+}
+
+// HSetNX sets a field only if it does not exist yet, returns true if it was set
+func (s *Store) HSetNX(key, field, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.hashForWrite(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, exists := hash[field]; exists {
+		return false, nil
+	}
+
+	hash[field] = value
+	s.touch(key)
+	return true, nil
+}
+
+// HGet returns the value of a field in a hash
+func (s *Store) HGet(key, field string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, exists := hash[field]
+	return value, exists, nil
+}
+
+// HMGet returns the values of several fields, found[i] reports whether fields[i] exists
+func (s *Store) HMGet(key string, fields []string) ([]string, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]string, len(fields))
+	found := make([]bool, len(fields))
+	for i, field := range fields {
+		values[i], found[i] = hash[field]
+	}
+
+	return values, found, nil
+}
+
+// HDel atomically removes fields from a hash, returns count removed
+func (s *Store) HDel(key string, fields []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key)
+	if !ok {
+		return 0, nil
+	}
+
+	if e.Type != HashType {
+		return 0, ErrWrongType
+	}
+
+	hash := e.Value.(map[string]string)
+	removed := int64(0)
+
+	for _, field := range fields {
+		if _, exists := hash[field]; exists {
+			delete(hash, field)
+			removed++
+		}
+	}
+
+	// Delete key if hash is empty
+	if len(hash) == 0 {
+		delete(s.data, key)
+	}
+
+	if removed > 0 {
+		s.touch(key)
+	}
+
+	return removed, nil
+}
+
+// HExists checks if a field exists in a hash
+func (s *Store) HExists(key, field string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := hash[field]
+	return exists, nil
+}
+
+// HLen returns the number of fields in a hash
+func (s *Store) HLen(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(hash)), nil
+}
+
+// HKeys returns all field names of a hash
+func (s *Store) HKeys(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(hash))
+	for field := range hash {
+		result = append(result, field)
+	}
+
+	return result, nil
+}
+
+// HVals returns all values of a hash
+func (s *Store) HVals(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(hash))
+	for _, value := range hash {
+		result = append(result, value)
+	}
+
+	return result, nil
+}
+
+// HGetAll returns a copy of all field/value pairs of a hash
+func (s *Store) HGetAll(key string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(hash))
+	for field, value := range hash {
+		result[field] = value
+	}
+
+	return result, nil
+}
+
+// HIncrBy atomically increments the integer value of a field, returns the new value
+func (s *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.hashForWrite(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if raw, exists := hash[field]; exists {
+		current, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	current += delta
+	hash[field] = strconv.FormatInt(current, 10)
+	s.touch(key)
+	return current, nil
+}
+
+// HIncrByFloat atomically increments the float value of a field, returns the new value
+func (s *Store) HIncrByFloat(key, field string, delta float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.hashForWrite(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current float64
+	if raw, exists := hash[field]; exists {
+		current, err = strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return 0, ErrHashNotFloat
+		}
+	}
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return 0, ErrNaNOrInfinity
+	}
+
+	hash[field] = strconv.FormatFloat(current, 'f', -1, 64)
+	s.touch(key)
+	return current, nil
+}
+
+// HStrLen returns the length of the value stored at a field
+func (s *Store) HStrLen(key, field string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.hashForRead(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(hash[field])), nil
+}
@@ -0,0 +1,145 @@
+package store
+
+import "time"
+
+// ==================== SNAPSHOTS ====================
+
+// Snapshot returns a deep copy of every live key. It is a point-in-time view
+// that background jobs such as the AOF rewrite can read without holding
+// the store lock.
+func (s *Store) Snapshot() map[string]*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := make(map[string]*Entry, len(s.data))
+	for key, e := range s.data {
+		if e.IsExpired() {
+			continue
+		}
+		snap[key] = e.clone()
+	}
+
+	return snap
+}
+
+// clone deep-copies an entry so later writes to the store don't affect it
+func (e *Entry) clone() *Entry {
+	c := &Entry{Type: e.Type, Expiry: e.Expiry}
+
+	switch e.Type {
+	case StringType:
+		c.Value = e.Value
+	case ListType:
+		list := e.Value.([]string)
+		c.Value = append(make([]string, 0, len(list)), list...)
+	case SetType:
+		set := e.Value.(map[string]struct{})
+		cp := make(map[string]struct{}, len(set))
+		for member := range set {
+			cp[member] = struct{}{}
+		}
+		c.Value = cp
+	case HashType:
+		hash := e.Value.(map[string]string)
+		cp := make(map[string]string, len(hash))
+		for field, value := range hash {
+			cp[field] = value
+		}
+		c.Value = cp
+	case ZSetType:
+		z := newZSet()
+		for member, score := range e.Value.(*zset).dict {
+			z.set(member, score)
+		}
+		c.Value = z
+	}
+
+	return c
+}
+
+// ZSetMembers returns the members of a sorted set entry in ascending score order
+func (e *Entry) ZSetMembers() []ScoredMember {
+	if e.Type != ZSetType {
+		return nil
+	}
+	return e.Value.(*zset).members()
+}
+
+// Clone returns a deep copy of a live key, which stays valid while the
+// store changes
+func (s *Store) Clone(key string) (*Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.peek(key)
+	if !ok {
+		return nil, false
+	}
+	return e.clone(), true
+}
+
+// Restore inserts a key read back from a snapshot, replacing any existing
+// value. Sorted sets are given as []ScoredMember. Keys that already expired
+// are skipped and false is returned. Loading doesn't count as a change.
+func (s *Store) Restore(key string, t ValueType, val any, expiry time.Time) bool {
+	if !expiry.IsZero() && time.Now().After(expiry) {
+		return false
+	}
+	val = restoredValue(t, val)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = &Entry{Type: t, Value: val, Expiry: expiry}
+	return true
+}
+
+// RestoreKey inserts a key received from another server, like RESTORE does.
+// Unlike Restore it is a write clients see: it fails with ErrKeyExists when
+// the key exists and replace is false. A key that already expired only
+// removes the existing one.
+func (s *Store) RestoreKey(key string, t ValueType, val any, expiry time.Time, replace bool) error {
+	val = restoredValue(t, val)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.get(key); ok && !replace {
+		return ErrKeyExists
+	}
+	if !expiry.IsZero() && time.Now().After(expiry) {
+		if _, ok := s.data[key]; ok {
+			delete(s.data, key)
+			s.touch(key)
+		}
+		return nil
+	}
+
+	s.data[key] = &Entry{Type: t, Value: val, Expiry: expiry}
+	s.touch(key)
+	return nil
+}
+
+// restoredValue builds the in-memory form of a restored sorted set
+func restoredValue(t ValueType, val any) any {
+	if t != ZSetType {
+		return val
+	}
+	z := newZSet()
+	for _, m := range val.([]ScoredMember) {
+		z.set(m.Member, m.Score)
+	}
+	return z
+}
+
+// Flush removes every key, e.g. before a replica loads its master's
+// snapshot. Watches on the removed keys are marked dirty.
+func (s *Store) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.data {
+		s.touch(key)
+	}
+	s.data = make(map[string]*Entry)
+}
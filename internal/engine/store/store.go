@@ -18,17 +18,22 @@ const (
 )
 
 type Store struct {
-	mu     sync.RWMutex
-	data   map[string]*Entry
-	stopCh chan struct{}
-	doneCh chan struct{}
+	mu          sync.RWMutex
+	data        map[string]*Entry
+	listWaiters map[string][]*ListWaiter       // clients blocked on empty lists, FIFO per key
+	watchers    map[string]map[*Watch]struct{} // WATCHes per key, see touch
+	changes     int64                          // writes since startup, see Changes
+	stopCh      chan struct{}
+	doneCh      chan struct{}
 }
 
 func NewStore() *Store {
 	return &Store{
-		data:   make(map[string]*Entry),
-		stopCh: make(chan struct{}),
-		doneCh: make(chan struct{}),
+		data:        make(map[string]*Entry),
+		listWaiters: make(map[string][]*ListWaiter),
+		watchers:    make(map[string]map[*Watch]struct{}),
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
 	}
 }
 
@@ -40,6 +45,17 @@ func (s *Store) get(key string) (*Entry, bool) {
 	//Lazy delete, if the entry is expired
 	if e.IsExpired() {
 		delete(s.data, key)
+		s.touch(key)
+		return nil, false
+	}
+
+	return e, true
+}
+
+// peek returns a live entry without deleting it, safe to call under a read lock
+func (s *Store) peek(key string) (*Entry, bool) {
+	e, ok := s.data[key]
+	if !ok || e.IsExpired() {
 		return nil, false
 	}
 
@@ -54,10 +70,32 @@ func (s *Store) Set(key string, t ValueType, val any) bool {
 		Type:  t,
 		Value: val,
 	}
+	s.touch(key)
 
 	return true
 }
 
+// SetKeepTTL replaces the value of key but keeps its expiry, like INCR
+// does. Returns the expiry, zero if the key has none.
+func (s *Store) SetKeepTTL(key string, t ValueType, val any) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiry time.Time
+	if e, ok := s.get(key); ok {
+		expiry = e.Expiry
+	}
+
+	s.data[key] = &Entry{
+		Type:   t,
+		Value:  val,
+		Expiry: expiry,
+	}
+	s.touch(key)
+
+	return expiry
+}
+
 func (s *Store) Get(key string) (*Entry, bool) {
 	s.mu.Lock() //read mutex as using lazy delete (it requires write lock)
 	defer s.mu.Unlock()
@@ -71,6 +109,7 @@ func (s *Store) Delete(key string) bool {
 
 	if _, ok := s.data[key]; ok {
 		delete(s.data, key)
+		s.touch(key)
 		return true
 	}
 
@@ -78,11 +117,17 @@ func (s *Store) Delete(key string) bool {
 }
 
 func (s *Store) SetExpiry(key string, ttl time.Duration) bool {
+	return s.SetExpiryAt(key, time.Now().Add(ttl))
+}
+
+// SetExpiryAt makes key expire at an absolute time, false if it doesn't exist
+func (s *Store) SetExpiryAt(key string, at time.Time) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if e, ok := s.data[key]; ok {
-		e.Expiry = time.Now().Add(ttl)
+	if e, ok := s.get(key); ok {
+		e.Expiry = at
+		s.touch(key)
 		return true
 	}
 
@@ -163,6 +208,7 @@ func (s *Store) sampleAndExpire() int {
 		key := keysWithExpiry[i]
 		if e, ok := s.data[key]; ok && e.IsExpired() {
 			delete(s.data, key)
+			s.touch(key)
 			expired++
 		}
 	}
@@ -177,6 +223,33 @@ func (s *Store) KeyCount() int {
 	return len(s.data)
 }
 
+// Keys returns up to limit live keys that match reports true for, all of
+// them when limit is 0. It scans the whole keyspace.
+func (s *Store) Keys(match func(key string) bool, limit int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.data {
+		if _, ok := s.peek(key); !ok || !match(key) {
+			continue
+		}
+		keys = append(keys, key)
+		if len(keys) == limit {
+			break
+		}
+	}
+	return keys
+}
+
+// Changes returns the number of writes since startup, scheduled snapshots
+// compare it against the value at the last save
+func (s *Store) Changes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changes
+}
+
 // ==================== ATOMIC SET OPERATIONS ====================
 
 // SAdd atomically adds members to a set, returns count of new members added
@@ -205,6 +278,10 @@ func (s *Store) SAdd(key string, members []string) (int64, error) {
 		}
 	}
 
+	if added > 0 {
+		s.touch(key)
+	}
+
 	return added, nil
 }
 
@@ -237,6 +314,10 @@ func (s *Store) SRem(key string, members []string) (int64, bool) {
 		delete(s.data, key)
 	}
 
+	if removed > 0 {
+		s.touch(key)
+	}
+
 	return removed, true
 }
 
@@ -323,6 +404,7 @@ func (s *Store) LPush(key string, values []string) (int64, error) {
 	}
 
 	s.data[key] = &Entry{Type: ListType, Value: list}
+	s.touch(key)
 	return int64(len(list)), nil
 }
 
@@ -343,6 +425,7 @@ func (s *Store) RPush(key string, values []string) (int64, error) {
 
 	list = append(list, values...)
 	s.data[key] = &Entry{Type: ListType, Value: list}
+	s.touch(key)
 	return int64(len(list)), nil
 }
 
@@ -378,6 +461,7 @@ func (s *Store) LPop(key string, count int) ([]string, error) {
 	} else {
 		s.data[key] = &Entry{Type: ListType, Value: remaining}
 	}
+	s.touch(key)
 
 	return popped, nil
 }
@@ -420,6 +504,7 @@ func (s *Store) RPop(key string, count int) ([]string, error) {
 	} else {
 		s.data[key] = &Entry{Type: ListType, Value: remaining}
 	}
+	s.touch(key)
 
 	return popped, nil
 }
@@ -511,19 +596,3 @@ func (s *Store) LIndex(key string, index int) (string, bool, error) {
 
 	return list[index], true, nil
 }
-
-// GetListCopy returns a copy of the list for AOF logging
-func (s *Store) GetListCopy(key string) ([]string, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	e, ok := s.data[key]
-	if !ok || e.Type != ListType {
-		return nil, false
-	}
-
-	list := e.Value.([]string)
-	result := make([]string, len(list))
-	copy(result, list)
-	return result, true
-}
@@ -7,4 +7,5 @@ const (
 	ListType
 	SetType
 	HashType
+	ZSetType
 )
@@ -0,0 +1,87 @@
+package store
+
+// Watch holds the keys a client WATCHes for an optimistic MULTI/EXEC.
+// It becomes dirty as soon as any of them is modified, deleted or expires.
+type Watch struct {
+	store *Store
+	keys  map[string]bool // key -> whether it existed when watched
+	dirty bool
+}
+
+// NewWatch creates an empty watch for one client
+func (s *Store) NewWatch() *Watch {
+	return &Watch{store: s, keys: make(map[string]bool)}
+}
+
+// ==================== WATCH OPERATIONS ====================
+
+// Add starts tracking modifications of keys
+func (w *Watch) Add(keys []string) {
+	s := w.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := w.keys[key]; ok {
+			continue
+		}
+
+		// get drops an already expired key first, so it can't dirty the watch later
+		_, exists := s.get(key)
+		w.keys[key] = exists
+
+		watchers, ok := s.watchers[key]
+		if !ok {
+			watchers = make(map[*Watch]struct{})
+			s.watchers[key] = watchers
+		}
+		watchers[w] = struct{}{}
+	}
+}
+
+// Reset stops tracking every key, used by UNWATCH, EXEC, DISCARD and on disconnect
+func (w *Watch) Reset() {
+	s := w.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range w.keys {
+		watchers := s.watchers[key]
+		delete(watchers, w)
+		if len(watchers) == 0 {
+			delete(s.watchers, key)
+		}
+	}
+
+	w.keys = make(map[string]bool)
+	w.dirty = false
+}
+
+// Dirty reports whether any watched key changed since it was watched.
+// Keys that expired without being removed yet count as changed too.
+func (w *Watch) Dirty() bool {
+	s := w.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if w.dirty {
+		return true
+	}
+
+	for key, existed := range w.keys {
+		if e, ok := s.data[key]; ok && existed && e.IsExpired() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// touch records a write to key: it counts towards Changes and marks every
+// watch on key as dirty. Caller holds mu.
+func (s *Store) touch(key string) {
+	s.changes++
+	for w := range s.watchers[key] {
+		w.dirty = true
+	}
+}
@@ -0,0 +1,604 @@
+package store
+
+import "math"
+
+// ScoredMember is a sorted set member together with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreRange is a score interval, MinEx/MaxEx make a bound exclusive
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+func (r ScoreRange) gteMin(v float64) bool {
+	if r.MinEx {
+		return v > r.Min
+	}
+	return v >= r.Min
+}
+
+func (r ScoreRange) lteMax(v float64) bool {
+	if r.MaxEx {
+		return v < r.Max
+	}
+	return v <= r.Max
+}
+
+// LexRange is a member interval for lexicographical queries.
+// MinInf/MaxInf stand for the special "-" and "+" bounds.
+type LexRange struct {
+	Min, Max       string
+	MinEx, MaxEx   bool
+	MinInf, MaxInf bool
+}
+
+func (r LexRange) gteMin(v string) bool {
+	if r.MinInf {
+		return true
+	}
+	if r.MinEx {
+		return v > r.Min
+	}
+	return v >= r.Min
+}
+
+func (r LexRange) lteMax(v string) bool {
+	if r.MaxInf {
+		return true
+	}
+	if r.MaxEx {
+		return v < r.Max
+	}
+	return v <= r.Max
+}
+
+func (r LexRange) empty() bool {
+	if r.MinInf || r.MaxInf {
+		return false
+	}
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+// ZAddOptions mirrors the ZADD flags
+type ZAddOptions struct {
+	NX, XX bool // only add new members / only update existing ones
+	GT, LT bool // only update when the new score is greater / less
+	Incr   bool // increment the score instead of replacing it
+}
+
+// ZAddResult describes what a ZADD call changed
+type ZAddResult struct {
+	Added   int64
+	Updated int64
+	Score   float64 // resulting score in INCR mode
+	Skipped bool    // true when INCR was not applied because of NX/XX/GT/LT
+}
+
+// Aggregate selects how ZUNIONSTORE/ZINTERSTORE combine scores
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+// zset pairs a member->score map with a skiplist ordered by score,
+// giving O(1) score lookups and O(log n) rank and range queries
+type zset struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func newZSet() *zset {
+	return &zset{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (z *zset) set(member string, score float64) {
+	if cur, exists := z.dict[member]; exists {
+		if cur == score {
+			return
+		}
+		z.zsl.delete(cur, member)
+	}
+	z.dict[member] = score
+	z.zsl.insert(score, member)
+}
+
+func (z *zset) remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	delete(z.dict, member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+// members returns every member in ascending score order
+func (z *zset) members() []ScoredMember {
+	result := make([]ScoredMember, 0, z.zsl.length)
+	for x := z.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		result = append(result, ScoredMember{Member: x.member, Score: x.score})
+	}
+	return result
+}
+
+// walk collects up to count nodes (all if count < 0) starting at x,
+// skipping offset nodes first and stopping as soon as inRange fails
+func (z *zset) walk(x *skiplistNode, reverse bool, offset, count int, inRange func(*skiplistNode) bool) []ScoredMember {
+	result := []ScoredMember{}
+	if x == nil {
+		return result
+	}
+
+	if offset > 0 {
+		rank := z.zsl.rank(x.score, x.member)
+		if reverse {
+			rank -= offset
+		} else {
+			rank += offset
+		}
+		if rank < 1 || rank > z.zsl.length {
+			return result
+		}
+		x = z.zsl.byRank(rank)
+	}
+
+	for x != nil && count != 0 && inRange(x) {
+		result = append(result, ScoredMember{Member: x.member, Score: x.score})
+		count--
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+
+	return result
+}
+
+// zsetForWrite returns the sorted set stored at key, creating it if missing
+func (s *Store) zsetForWrite(key string) (*zset, error) {
+	e, ok := s.get(key)
+	if !ok {
+		z := newZSet()
+		s.data[key] = &Entry{Type: ZSetType, Value: z}
+		return z, nil
+	}
+
+	if e.Type != ZSetType {
+		return nil, ErrWrongType
+	}
+
+	return e.Value.(*zset), nil
+}
+
+// zsetForRead returns the sorted set stored at key, or nil if missing
+func (s *Store) zsetForRead(key string) (*zset, error) {
+	e, ok := s.peek(key)
+	if !ok {
+		return nil, nil
+	}
+
+	if e.Type != ZSetType {
+		return nil, ErrWrongType
+	}
+
+	return e.Value.(*zset), nil
+}
+
+// ==================== ATOMIC SORTED SET OPERATIONS ====================
+
+// ZAdd atomically adds or updates members according to opts
+func (s *Store) ZAdd(key string, opts ZAddOptions, members []ScoredMember) (ZAddResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result ZAddResult
+
+	e, ok := s.get(key)
+	if ok && e.Type != ZSetType {
+		return result, ErrWrongType
+	}
+
+	// XX never creates the key
+	if !ok && opts.XX {
+		result.Skipped = true
+		return result, nil
+	}
+
+	z, _ := s.zsetForWrite(key)
+	defer func() {
+		if len(z.dict) == 0 {
+			delete(s.data, key)
+		}
+		if result.Added > 0 || result.Updated > 0 {
+			s.touch(key)
+		}
+	}()
+
+	for _, m := range members {
+		cur, exists := z.dict[m.Member]
+
+		if !exists {
+			if opts.XX {
+				result.Skipped = true
+				continue
+			}
+			z.set(m.Member, m.Score)
+			result.Added++
+			result.Score = m.Score
+			continue
+		}
+
+		if opts.NX {
+			result.Skipped = true
+			continue
+		}
+
+		score := m.Score
+		if opts.Incr {
+			score = cur + m.Score
+			if math.IsNaN(score) {
+				return result, ErrScoreNaN
+			}
+		}
+
+		if (opts.GT && score <= cur) || (opts.LT && score >= cur) {
+			result.Skipped = true
+			continue
+		}
+
+		result.Score = score
+		if score != cur {
+			z.set(m.Member, score)
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// ZRem atomically removes members from a sorted set, returns count removed
+func (s *Store) ZRem(key string, members []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key)
+	if !ok {
+		return 0, nil
+	}
+
+	if e.Type != ZSetType {
+		return 0, ErrWrongType
+	}
+
+	z := e.Value.(*zset)
+	removed := int64(0)
+	for _, member := range members {
+		if z.remove(member) {
+			removed++
+		}
+	}
+
+	// Delete key if sorted set is empty
+	if len(z.dict) == 0 {
+		delete(s.data, key)
+	}
+
+	if removed > 0 {
+		s.touch(key)
+	}
+
+	return removed, nil
+}
+
+// ZScore returns the score of a member
+func (s *Store) ZScore(key, member string) (float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return 0, false, err
+	}
+
+	score, exists := z.dict[member]
+	return score, exists, nil
+}
+
+// ZCard returns the number of members in a sorted set
+func (s *Store) ZCard(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	return int64(len(z.dict)), nil
+}
+
+// ZRank returns the 0-based rank of a member, counted from the highest
+// score when reverse is set
+func (s *Store) ZRank(key, member string, reverse bool) (int64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return 0, false, err
+	}
+
+	score, exists := z.dict[member]
+	if !exists {
+		return 0, false, nil
+	}
+
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return int64(z.zsl.length - rank), true, nil
+	}
+	return int64(rank - 1), true, nil
+}
+
+// ZRangeByRank returns members between two 0-based ranks (negative counts from the end)
+func (s *Store) ZRangeByRank(key string, start, stop int, reverse bool) ([]ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return []ScoredMember{}, err
+	}
+
+	length := z.zsl.length
+
+	// Handle negative indices
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+
+	// Clamp
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	if start > stop || start >= length {
+		return []ScoredMember{}, nil
+	}
+
+	var x *skiplistNode
+	if reverse {
+		x = z.zsl.byRank(length - start)
+	} else {
+		x = z.zsl.byRank(start + 1)
+	}
+
+	return z.walk(x, reverse, 0, stop-start+1, func(*skiplistNode) bool { return true }), nil
+}
+
+// ZRangeByScore returns members with scores within r, applying a LIMIT
+// style offset and count (count < 0 means no limit)
+func (s *Store) ZRangeByScore(key string, r ScoreRange, reverse bool, offset, count int) ([]ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return []ScoredMember{}, err
+	}
+
+	if reverse {
+		return z.walk(z.zsl.lastInRange(r), true, offset, count, func(n *skiplistNode) bool {
+			return r.gteMin(n.score)
+		}), nil
+	}
+
+	return z.walk(z.zsl.firstInRange(r), false, offset, count, func(n *skiplistNode) bool {
+		return r.lteMax(n.score)
+	}), nil
+}
+
+// ZRangeByLex returns members within the lexicographical range r
+func (s *Store) ZRangeByLex(key string, r LexRange, reverse bool, offset, count int) ([]ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return []ScoredMember{}, err
+	}
+
+	if reverse {
+		return z.walk(z.zsl.lastInLexRange(r), true, offset, count, func(n *skiplistNode) bool {
+			return r.gteMin(n.member)
+		}), nil
+	}
+
+	return z.walk(z.zsl.firstInLexRange(r), false, offset, count, func(n *skiplistNode) bool {
+		return r.lteMax(n.member)
+	}), nil
+}
+
+// ZCount returns the number of members with scores within r
+func (s *Store) ZCount(key string, r ScoreRange) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, err := s.zsetForRead(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	first := z.zsl.firstInRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.zsl.lastInRange(r)
+
+	// Ranks make this O(log n) regardless of how many members match
+	return int64(z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1), nil
+}
+
+// ZPop atomically removes and returns up to count members with the
+// lowest scores, or the highest ones when max is set
+func (s *Store) ZPop(key string, count int, max bool) ([]ScoredMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key)
+	if !ok {
+		return []ScoredMember{}, nil
+	}
+
+	if e.Type != ZSetType {
+		return nil, ErrWrongType
+	}
+
+	z := e.Value.(*zset)
+	popped := []ScoredMember{}
+	for len(popped) < count && z.zsl.length > 0 {
+		x := z.zsl.header.level[0].forward
+		if max {
+			x = z.zsl.tail
+		}
+		popped = append(popped, ScoredMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+
+	// Delete key if sorted set is empty
+	if len(z.dict) == 0 {
+		delete(s.data, key)
+	}
+
+	if len(popped) > 0 {
+		s.touch(key)
+	}
+
+	return popped, nil
+}
+
+// ZUnionStore stores the union of the given sorted sets (or plain sets) at
+// dest and returns its cardinality
+func (s *Store) ZUnionStore(dest string, keys []string, weights []float64, agg Aggregate) (int64, error) {
+	return s.zsetStore(dest, keys, weights, agg, false)
+}
+
+// ZInterStore stores the intersection of the given sorted sets (or plain
+// sets) at dest and returns its cardinality
+func (s *Store) ZInterStore(dest string, keys []string, weights []float64, agg Aggregate) (int64, error) {
+	return s.zsetStore(dest, keys, weights, agg, true)
+}
+
+func (s *Store) zsetStore(dest string, keys []string, weights []float64, agg Aggregate, inter bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Read every source first so WRONGTYPE leaves dest untouched
+	sources := make([]map[string]float64, len(keys))
+	for i, key := range keys {
+		e, ok := s.get(key)
+		if !ok {
+			sources[i] = map[string]float64{}
+			continue
+		}
+
+		switch e.Type {
+		case ZSetType:
+			sources[i] = e.Value.(*zset).dict
+		case SetType:
+			members := make(map[string]float64)
+			for member := range e.Value.(map[string]struct{}) {
+				members[member] = 1
+			}
+			sources[i] = members
+		default:
+			return 0, ErrWrongType
+		}
+	}
+
+	result := make(map[string]float64)
+	for i, src := range sources {
+		weight := 1.0
+		if i < len(weights) {
+			weight = weights[i]
+		}
+
+		for member, score := range src {
+			if inter && i > 0 {
+				if _, seen := result[member]; !seen {
+					continue
+				}
+			}
+
+			weighted := weight * score
+			if math.IsNaN(weighted) {
+				// 0 * inf, Redis treats this as 0
+				weighted = 0
+			}
+
+			cur, seen := result[member]
+			if !seen {
+				result[member] = weighted
+				continue
+			}
+			result[member] = aggregateScores(agg, cur, weighted)
+		}
+
+		if inter && i > 0 {
+			// Drop members missing from this source
+			for member := range result {
+				if _, ok := src[member]; !ok {
+					delete(result, member)
+				}
+			}
+		}
+	}
+
+	s.touch(dest)
+
+	if len(result) == 0 {
+		delete(s.data, dest)
+		return 0, nil
+	}
+
+	z := newZSet()
+	for member, score := range result {
+		z.set(member, score)
+	}
+	s.data[dest] = &Entry{Type: ZSetType, Value: z}
+
+	return int64(len(result)), nil
+}
+
+func aggregateScores(agg Aggregate, a, b float64) float64 {
+	switch agg {
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	default:
+		sum := a + b
+		if math.IsNaN(sum) {
+			// inf + -inf
+			return 0
+		}
+		return sum
+	}
+}
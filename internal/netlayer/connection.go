@@ -1,38 +1,253 @@
 package netlayer
 
 import (
+	"errors"
 	"io"
+	"log"
 	"net"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/Eahtasham/go-redis/internal/commands"
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
 )
 
+// nextClientID numbers connections, starting at 1
+var nextClientID atomic.Int64
+
+// How many bytes of commands a client may have waiting behind the one
+// running, like Redis' client-query-buffer-limit. A client over it is
+// disconnected.
+const maxQueryBuffer = 1 << 30
+
+var errQueryBufferFull = errors.New("client reached max query buffer length")
+
+// request is a command read from the client, or the error that ended
+// reading
+type request struct {
+	value resp.Value
+	size  int64 // bytes the command took on the wire
+	err   error
+}
+
+// commandQueue hands the commands read from a client to the connection
+// loop. Pushing never waits, so the reader keeps reading, and sees the
+// client go away, even while a blocking command holds up the commands
+// pipelined after it.
+type commandQueue struct {
+	mu    sync.Mutex
+	reqs  []request
+	bytes int64
+	ready chan struct{} // holds a token once reqs may be non-empty
+}
+
+func newCommandQueue() *commandQueue {
+	return &commandQueue{ready: make(chan struct{}, 1)}
+}
+
+// push queues r and returns the bytes now waiting
+func (q *commandQueue) push(r request) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reqs = append(q.reqs, r)
+	q.bytes += r.size
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return q.bytes
+}
+
+// pop waits for the next request
+func (q *commandQueue) pop() request {
+	for {
+		q.mu.Lock()
+		if len(q.reqs) > 0 {
+			r := q.reqs[0]
+			q.reqs[0] = request{}
+			q.reqs = q.reqs[1:]
+			q.bytes -= r.size
+			q.mu.Unlock()
+			return r
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+// len returns the number of requests waiting
+func (q *commandQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.reqs)
+}
+
 type ClientConn struct {
 	conn   net.Conn
 	Closed atomic.Bool
 }
 
-func HandleConn(conn net.Conn) {
+// HandleConn serves one client connection, reading its commands within
+// limits
+func HandleConn(conn net.Conn, limits resp.Limits) {
 	defer conn.Close()
 
 	reader := resp.NewReader(conn)
+	reader.SetLimits(limits)
 	writer := resp.NewWriter(conn)
 
-	// Per-client context for transactions
-	ctx := &commands.ClientContext{}
+	// Replies and pub/sub messages are written from different goroutines.
+	// The mutex is held while a command runs so that a message can't get
+	// ahead of the SUBSCRIBE confirmation for its channel.
+	var writeMu sync.Mutex
+	write := func(v resp.Value) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return writer.WriteValue(v)
+	}
+
+	// Commands are read on their own goroutine so that a client parked in a
+	// blocking command (BLPOP & co) still notices when the connection
+	// closes. The reader runs ahead of the commands being executed, which
+	// also lets the replies to a pipeline go out in one write.
+	queue := newCommandQueue()
+	closed := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for {
+			start := reader.Offset()
+			value, err := reader.ReadCommand()
+			if err != nil {
+				// Blocked commands give up right away, whatever is still
+				// queued in front of the error
+				close(closed)
+				queue.push(request{err: err})
+				return
+			}
+
+			if queue.push(request{value: value, size: reader.Offset() - start}) > maxQueryBuffer {
+				log.Printf("Closing client %s that reached max query buffer length", conn.RemoteAddr())
+				close(closed)
+				queue.push(request{err: errQueryBufferFull})
+				return
+			}
+		}
+	}()
+
+	// Per-client context for transactions, blocking commands and pub/sub
+	ctx := &commands.ClientContext{
+		Closed:   closed,
+		Push:     writer.WriteValue,
+		Flush:    writer.Flush,
+		Addr:     conn.RemoteAddr().String(),
+		ID:       nextClientID.Add(1),
+		Protocol: 2,
+	}
+	defer ctx.Close()
+
+	pumping, streaming := false, false
 
 	for {
-		value, err := reader.ReadValue()
-		if err != nil {
-			if err != io.EOF {
-				// Log error if needed
+		req := queue.pop()
+		if req.err != nil {
+			// io.EOF is a normal disconnect, anything else ends the connection
+			// too. Broken input is answered first, so the client learns why.
+			var protoErr *resp.ProtocolError
+			if errors.As(req.err, &protoErr) {
+				write(resp.ErrorValue("ERR " + protoErr.Error()))
+			} else {
+				writeMu.Lock()
+				writer.Flush()
+				writeMu.Unlock()
 			}
 			return
 		}
 
+		value := req.value
+		if isQuit(value) {
+			write(resp.SimpleValue("OK"))
+			return
+		}
+
+		writeMu.Lock()
 		res := commands.DispatchWithContext(value, ctx)
-		writer.WriteValue(res)
+		writer.SetProtocol(ctx.Protocol) // HELLO already replies in the new version
+		var err error
+		if res.Type != commands.NoReply.Type {
+			err = writer.Buffer(res)
+		}
+		// Flush once no pipelined command is waiting, and before the
+		// replication stream takes over the connection
+		if err == nil && (queue.len() == 0 || ctx.Replica != nil) {
+			err = writer.Flush()
+		}
+		writeMu.Unlock()
+		if err != nil {
+			return
+		}
+
+		// First SUBSCRIBE: start forwarding published messages
+		if ctx.Sub != nil && !pumping {
+			pumping = true
+			go pumpMessages(conn, ctx, write, stop)
+		}
+
+		// PSYNC: the connection now carries the replication stream
+		if ctx.Replica != nil && !streaming {
+			streaming = true
+			go streamReplica(conn, ctx, &writeMu, stop)
+		}
+	}
+}
+
+// streamReplica sends the replication stream to a replica. The replica
+// only sends REPLCONF ACKs from now on, which get no reply, so the stream
+// has the connection to itself.
+func streamReplica(conn net.Conn, ctx *commands.ClientContext, writeMu *sync.Mutex, stop <-chan struct{}) {
+	err := ctx.Replica.Serve(&lockedWriter{w: conn, mu: writeMu}, stop)
+	if err != nil {
+		log.Printf("Replica %s disconnected: %v", ctx.Addr, err)
 	}
+	conn.Close()
+}
+
+// lockedWriter holds the connection's write mutex for every write
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// pumpMessages forwards published messages to the client. A subscriber that
+// falls too far behind is disconnected rather than slowing down publishers.
+func pumpMessages(conn net.Conn, ctx *commands.ClientContext, write func(resp.Value) error, stop <-chan struct{}) {
+	sub := ctx.Sub
+	for {
+		select {
+		case msg := <-sub.Messages():
+			if err := write(msg.Value()); err != nil {
+				conn.Close()
+				return
+			}
+		case <-sub.Dropped():
+			conn.Close()
+			return
+		case <-sub.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+func isQuit(v resp.Value) bool {
+	return v.Type == resp.Array && len(v.Array) > 0 && strings.EqualFold(v.Array[0].Str, "QUIT")
 }
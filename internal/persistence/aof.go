@@ -1,58 +1,614 @@
 package persistence
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
+// Options tunes the AOF, zero values give the defaults
+type Options struct {
+	// When to fsync, everysec by default
+	Fsync FsyncPolicy
+
+	// What to do when the write queue is full, block by default
+	Backpressure BackpressurePolicy
+
+	// Start rewritten files with a binary snapshot instead of commands
+	Preamble bool
+
+	// Rewrite automatically once the file grew by this percentage since the
+	// last rewrite (or since startup)...
+	AutoRewritePercentage int
+	// ...and is at least this many bytes large
+	AutoRewriteMinSize int64
+}
+
+// record is one queued write. A record with next set carries no data, it
+// switches the writer to the next incremental file and closes done once it
+// did.
+type record struct {
+	data []byte
+	done chan struct{}
+	next *os.File
+}
+
+// Stats is a point-in-time view of the AOF state, reported by INFO
+type Stats struct {
+	Fsync        FsyncPolicy
+	Size         int64
+	BaseSize     int64
+	Rewriting    bool
+	LastFsync    time.Time
+	PendingBytes int64 // accepted but not fsynced yet
+
+	Backpressure  BackpressurePolicy
+	DelayedWrites int64 // records that found the queue full
+	RefusedWrites int64 // write commands rejected under the refuse policy
+	WriteErrors   int64 // failed writes and fsyncs
+	LastWriteErr  error // nil once the last write succeeded
+}
+
+// Size of the queue between appenders and the writer goroutine
+const queueSize = 1024
+
+var errQueueFull = errors.New("ERR AOF write queue is full, write commands are refused until it drains")
+
+// AOF is a multi-part append only file: a directory holding a base file,
+// the incremental files appended after it and a manifest listing them, see
+// Manifest. Writes go to the last incremental file. A rewrite starts a new
+// one and writes a new base next to it, then drops the files the base
+// replaces, so it never has to copy what was appended meanwhile.
 type AOF struct {
-	file   *os.File
-	ch     chan []byte
+	dir    string
+	name   string
+	opts   Options
+	file   *os.File // last incremental file, only used by the writer
+	ch     chan record
+	wake   chan struct{} // nudges the writer when records went to overflow
 	stopCh chan struct{}
 	doneCh chan struct{} // signals when background writer has finished
+
+	size     atomic.Int64 // size of all files
+	baseSize atomic.Int64 // size after the last rewrite, for auto-rewrite
+
+	// Byte counters since startup, pending = appended - synced
+	appended  atomic.Int64
+	written   atomic.Int64
+	synced    atomic.Int64
+	lastFsync atomic.Int64 // unix nanoseconds
+
+	// Bytes of the records whose batch the writer finished, and a channel
+	// closed and replaced after every batch, see WaitSynced
+	batched   atomic.Int64
+	batchMu   sync.Mutex
+	batchDone chan struct{}
+
+	delayed     atomic.Int64
+	refused     atomic.Int64
+	writeErrors atomic.Int64
+
+	// Records queued behind a full channel under the grow and refuse
+	// policies. Appenders only use the channel while this is empty, which
+	// keeps records in order.
+	overflowMu sync.Mutex
+	overflow   []record
+	overflowed atomic.Int64 // len(overflow), readable without the lock
+
+	// Bytes a failed write left behind, retried before anything else.
+	// Only touched by the writer goroutine.
+	unwritten []byte
+
+	errMu   sync.Mutex
+	lastErr error // last write or fsync error, nil after a success
+
+	// mu orders appends against the file switches below
+	mu sync.Mutex
+
+	// The files in replay order, rewritten on every change
+	manifest *Manifest
+
+	// Name, size and CRC64 of the last incremental file once every
+	// accepted record is written, see Position
+	incr   string
+	offset int64
+	crc    uint64
+
+	rewriting bool
+}
+
+// NewAOF opens the AOF called name in dir, creating both on first start.
+// A single-file AOF from before manifests is taken over as the base file.
+func NewAOF(dir, name string, opts Options) (*AOF, error) {
+	m, err := openManifest(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	removeStale(dir, name, m)
+
+	a := &AOF{
+		dir:      dir,
+		name:     name,
+		opts:     opts,
+		manifest: m,
+		ch:       make(chan record, queueSize),
+		wake:     make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+
+		batchDone: make(chan struct{}),
+	}
+
+	// Keep appending to the last incremental file, or start one
+	if last := m.Last(); last != nil {
+		a.incr = last.Name
+		a.file, err = os.OpenFile(a.path(last.Name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	} else {
+		a.file, err = a.newIncrLocked()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := a.file.Stat()
+	if err != nil {
+		a.file.Close()
+		return nil, err
+	}
+	crc, err := fileCRC(a.path(a.incr), info.Size())
+	if err != nil {
+		a.file.Close()
+		return nil, err
+	}
+
+	var size int64
+	for _, f := range a.manifest.Files {
+		if info, err := os.Stat(a.path(f.Name)); err == nil {
+			size += info.Size()
+		}
+	}
+
+	a.size.Store(size)
+	a.baseSize.Store(size)
+	a.lastFsync.Store(time.Now().UnixNano())
+	a.offset = info.Size()
+	a.crc = crc
+
+	return a, nil
 }
 
-func NewAOF(path string) (*AOF, error) {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+// path returns the path of one of the AOF's files
+func (a *AOF) path(name string) string {
+	return filepath.Join(a.dir, name)
+}
+
+// newIncrLocked creates the next incremental file and lists it in the
+// manifest, caller holds mu or has the AOF to itself. Records accepted from
+// here on belong to the new file.
+func (a *AOF) newIncrLocked() (*os.File, error) {
+	seq := a.manifest.nextSeq(IncrFile)
+	name := incrFileName(a.name, seq)
 
+	f, err := os.OpenFile(a.path(name), os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, err
 	}
 
-	return &AOF{
-		file:   f,
-		ch:     make(chan []byte, 1024),
-		stopCh: make(chan struct{}),
-		doneCh: make(chan struct{}),
-	}, nil
+	// The file exists before the manifest lists it, so a crash in between
+	// leaves at most an unlisted empty file
+	m := &Manifest{Files: append(append([]ManifestFile(nil), a.manifest.Files...),
+		ManifestFile{Name: name, Seq: seq, Type: IncrFile})}
+	if err := m.Write(a.dir, a.name); err != nil {
+		f.Close()
+		os.Remove(a.path(name))
+		return nil, err
+	}
+
+	a.manifest = m
+	a.incr = name
+	a.offset = 0
+	a.crc = 0
+	return f, nil
+}
+
+// Dir returns the directory holding the AOF's files
+func (a *AOF) Dir() string {
+	return a.dir
+}
+
+// Name returns the base name of the AOF's files
+func (a *AOF) Name() string {
+	return a.name
+}
+
+// Position returns where the last incremental file will end once every
+// record accepted so far is written. Taken together with a snapshot while
+// no command runs, it tells startup which part of the AOF the snapshot
+// already covers.
+func (a *AOF) Position() AOFPosition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AOFPosition{File: a.incr, Offset: a.offset, CRC: a.crc}
+}
+
+// Continues reports whether the AOF called name in dir still continues
+// from pos: the manifest lists its file, which still starts with the bytes
+// pos was taken at. Once a rewrite dropped the file, the AOF holds the
+// whole dataset on its own.
+func Continues(dir, name string, pos AOFPosition) bool {
+	m, err := ReadManifest(dir, name)
+	if err != nil || pos.File == "" || !m.Contains(pos.File) {
+		return false
+	}
+
+	crc, err := fileCRC(filepath.Join(dir, pos.File), pos.Offset)
+	return err == nil && crc == pos.CRC
+}
+
+// fileCRC returns the CRC64 of the first n bytes of the file at path
+func fileCRC(path string, n int64) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := crc64.New(crcTable)
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
 }
 
 func (a *AOF) Run() {
 	go func() {
 		defer close(a.doneCh)
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
 		for {
 			select {
-			case data := <-a.ch:
-				a.file.Write(data)
+			case rec := <-a.ch:
+				a.writeBatch(append([]record{rec}, a.next()...))
+			case <-a.wake:
+				a.writeBatch(a.next())
+			case <-ticker.C:
+				if len(a.unwritten) > 0 {
+					a.write(nil)
+					a.finishBatch(0)
+				}
+				if a.opts.Fsync == FsyncEverySec {
+					a.sync()
+				}
 			case <-a.stopCh:
 				// Drain remaining commands before closing
-				for {
-					select {
-					case data := <-a.ch:
-						a.file.Write(data)
-					default:
-						a.file.Sync()
-						a.file.Close()
-						return
-					}
-				}
+				a.drain()
+				a.sync()
+				a.file.Close()
+				return
 			}
 		}
 	}()
 }
 
+// writeBatch writes batch, then fsyncs once for the whole batch under
+// appendfsync always. Records queued meanwhile wait for the next call, so
+// the writer gets back to its ticker even under steady traffic. Only
+// called by the writer goroutine.
+func (a *AOF) writeBatch(batch []record) {
+	var n int64
+	for _, rec := range batch {
+		if rec.next != nil {
+			a.rotate(rec.next)
+			close(rec.done)
+		} else {
+			a.write(rec.data)
+			n += int64(len(rec.data))
+		}
+	}
+
+	a.finishBatch(n)
+
+	// Records left in overflow send no wake of their own, nudge the writer
+	// for them
+	if a.overflowed.Load() > 0 {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// finishBatch fsyncs under appendfsync always and wakes WaitSynced for the
+// n bytes just written. Only called by the writer goroutine.
+func (a *AOF) finishBatch(n int64) {
+	if a.opts.Fsync == FsyncAlways {
+		a.sync()
+	}
+	a.batched.Add(n)
+	a.batchMu.Lock()
+	close(a.batchDone)
+	a.batchDone = make(chan struct{})
+	a.batchMu.Unlock()
+}
+
+// next returns the records queued behind the current batch: the channel
+// first, then whatever overflowed it. Holding overflowMu keeps appenders
+// from adding to either while we look.
+func (a *AOF) next() []record {
+	a.overflowMu.Lock()
+	defer a.overflowMu.Unlock()
+
+	var batch []record
+	for more := true; more; {
+		select {
+		case rec := <-a.ch:
+			batch = append(batch, rec)
+		default:
+			more = false
+		}
+	}
+
+	if len(batch) == 0 {
+		batch = a.overflow
+		a.overflow = nil
+		a.overflowed.Store(0)
+	}
+	return batch
+}
+
+// write appends data to the file after anything a failed write left
+// behind. On error the rest is kept for the next attempt, so a short write
+// never leaves a hole in the file. Only called by the writer goroutine.
+func (a *AOF) write(data []byte) {
+	if len(a.unwritten) > 0 {
+		data = append(a.unwritten, data...)
+	}
+
+	n, err := a.file.Write(data)
+	a.size.Add(int64(n))
+	a.written.Add(int64(n))
+
+	if err != nil {
+		a.unwritten = append([]byte(nil), data[n:]...)
+		a.setError(err)
+		return
+	}
+	a.unwritten = nil
+	a.setError(nil)
+}
+
+// rotate finishes the current incremental file and continues in next.
+// Bytes a failed write still holds back can't go to the new file, which
+// must start with a whole record; they are dropped, and the base of the
+// rewrite that started the new file covers them. Only called by the writer
+// goroutine.
+func (a *AOF) rotate(next *os.File) {
+	if len(a.unwritten) > 0 {
+		a.write(nil)
+	}
+	if n := len(a.unwritten); n > 0 {
+		log.Printf("AOF: dropping %d unwritten bytes, the rewrite will cover them", n)
+		a.written.Add(int64(n))
+		a.unwritten = nil
+	}
+
+	a.sync()
+	a.file.Close()
+	a.file = next
+}
+
+// drain writes every queued record, only called by the writer goroutine
+// when it stops
+func (a *AOF) drain() {
+	for batch := a.next(); len(batch) > 0; batch = a.next() {
+		a.writeBatch(batch)
+	}
+	if len(a.unwritten) > 0 {
+		a.write(nil)
+	}
+}
+
+// sync flushes the file to disk if anything was written since the last
+// fsync, only called by the writer goroutine
+func (a *AOF) sync() {
+	written := a.written.Load()
+	if written == a.synced.Load() {
+		return
+	}
+
+	if err := a.file.Sync(); err != nil {
+		a.setError(err)
+		return
+	}
+	a.synced.Store(written)
+	a.lastFsync.Store(time.Now().UnixNano())
+}
+
+func (a *AOF) setError(err error) {
+	if err != nil {
+		a.writeErrors.Add(1)
+	}
+
+	a.errMu.Lock()
+	a.lastErr = err
+	a.errMu.Unlock()
+}
+
+func (a *AOF) lastError() error {
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	return a.lastErr
+}
+
+// Writable reports whether write commands should be accepted right now.
+// They are refused while the last write to the file failed, and under the
+// refuse policy while the queue is full.
+func (a *AOF) Writable() error {
+	if err := a.lastError(); err != nil {
+		return misconf(err)
+	}
+
+	if a.opts.Backpressure == BackpressureRefuse &&
+		(len(a.ch) == cap(a.ch) || a.overflowed.Load() > 0) {
+		a.refused.Add(1)
+		return errQueueFull
+	}
+	return nil
+}
+
+// Append queues data for the writer. It doesn't wait for the data to reach
+// the disk, see Mark and WaitSynced.
 func (a *AOF) Append(data []byte) {
+	a.mu.Lock()
+	a.enqueueLocked(data)
+	a.mu.Unlock()
+}
+
+// Mark returns a position covering everything appended so far, to pass to
+// WaitSynced
+func (a *AOF) Mark() int64 {
+	return a.appended.Load()
+}
+
+// WaitSynced blocks under appendfsync always until everything appended
+// before mark was taken is on disk. Writes of many clients waiting at once
+// share one fsync. When the writer got to the data but failed to write or
+// fsync it, the error is returned.
+func (a *AOF) WaitSynced(mark int64) error {
+	if a.opts.Fsync != FsyncAlways {
+		return nil
+	}
+
+	for {
+		a.batchMu.Lock()
+		done := a.batchDone
+		a.batchMu.Unlock()
+		if a.synced.Load() >= mark {
+			return nil
+		}
+		if a.batched.Load() >= mark {
+			if err := a.lastError(); err != nil {
+				return misconf(err)
+			}
+		}
+
+		select {
+		case <-done:
+		case <-a.doneCh:
+			return nil
+		}
+	}
+}
+
+// misconf is the error write commands get while the AOF can't be written
+func misconf(err error) error {
+	return fmt.Errorf("MISCONF Errors writing to the AOF file: %v", err)
+}
+
+// wait blocks until the writer switched files, see record.next
+func (a *AOF) wait(done chan struct{}) {
+	select {
+	case <-done:
+	case <-a.doneCh:
+	}
+}
+
+// Stats returns the current AOF state
+func (a *AOF) Stats() Stats {
+	return Stats{
+		Fsync:         a.opts.Fsync,
+		Size:          a.size.Load(),
+		BaseSize:      a.baseSize.Load(),
+		Rewriting:     a.Rewriting(),
+		LastFsync:     time.Unix(0, a.lastFsync.Load()),
+		PendingBytes:  a.appended.Load() - a.synced.Load(),
+		Backpressure:  a.opts.Backpressure,
+		DelayedWrites: a.delayed.Load(),
+		RefusedWrites: a.refused.Load(),
+		WriteErrors:   a.writeErrors.Load(),
+		LastWriteErr:  a.lastError(),
+	}
+}
+
+// Truncate cuts the last incremental file to size, dropping a torn tail
+// found while loading. Only valid before anything was appended.
+func (a *AOF) Truncate(size int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.file.Truncate(size); err != nil {
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	crc, err := fileCRC(a.path(a.incr), size)
+	if err != nil {
+		return err
+	}
+
+	a.size.Add(size - a.offset)
+	a.baseSize.Store(a.size.Load())
+	a.offset = size
+	a.crc = crc
+	return nil
+}
+
+// enqueueLocked hands data to the writer, caller holds mu. A record is
+// never dropped: when the queue is full it waits or overflows, depending on
+// the backpressure policy.
+func (a *AOF) enqueueLocked(data []byte) {
+	rec := record{data: data}
+	a.appended.Add(int64(len(data)))
+	a.offset += int64(len(data))
+	a.crc = crc64.Update(a.crc, crcTable, data)
+
+	a.queueLocked(rec)
+}
+
+// queueLocked puts rec behind every record queued so far, caller holds mu
+func (a *AOF) queueLocked(rec record) {
+	if a.opts.Backpressure == BackpressureBlock {
+		select {
+		case a.ch <- rec:
+			return
+		default:
+		}
+
+		a.delayed.Add(1)
+		select {
+		case a.ch <- rec:
+		case <-a.doneCh:
+		}
+		return
+	}
+
+	a.overflowMu.Lock()
+	if len(a.overflow) == 0 {
+		select {
+		case a.ch <- rec:
+			a.overflowMu.Unlock()
+			return
+		default:
+		}
+	}
+	a.overflow = append(a.overflow, rec)
+	a.overflowed.Store(int64(len(a.overflow)))
+	a.overflowMu.Unlock()
+
+	a.delayed.Add(1)
 	select {
-	case a.ch <- data:
+	case a.wake <- struct{}{}:
 	default:
-		// drop or block later; for now, drop is acceptable
 	}
 }
 
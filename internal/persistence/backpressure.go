@@ -0,0 +1,42 @@
+package persistence
+
+import "fmt"
+
+// BackpressurePolicy decides what happens when commands are appended faster
+// than the writer can put them on disk and its queue fills up
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock makes the appending client wait for room in the
+	// queue, slowing write commands down to the speed of the disk
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureGrow queues the record in an unbounded overflow buffer,
+	// trading memory for latency
+	BackpressureGrow
+	// BackpressureRefuse buffers like grow but rejects new write commands
+	// until the queue has drained
+	BackpressureRefuse
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureGrow:
+		return "grow"
+	case BackpressureRefuse:
+		return "refuse"
+	}
+	return "block"
+}
+
+// ParseBackpressurePolicy parses an aof-backpressure setting
+func ParseBackpressurePolicy(s string) (BackpressurePolicy, error) {
+	switch s {
+	case "block":
+		return BackpressureBlock, nil
+	case "grow":
+		return BackpressureGrow, nil
+	case "refuse":
+		return BackpressureRefuse, nil
+	}
+	return 0, fmt.Errorf("invalid aof backpressure policy %q, want block, grow or refuse", s)
+}
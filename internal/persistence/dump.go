@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc64"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+)
+
+// DUMP payload format, the value of one key as DUMP returns it and RESTORE
+// and MIGRATE take it:
+//
+//	type value         encoded as in a snapshot
+//	version            2 bytes
+//	crc64              8 bytes, over everything before
+const dumpVersion = 1
+
+// ErrBadPayload is returned for a DUMP payload that is corrupted or comes
+// from an unknown version
+var ErrBadPayload = errors.New("DUMP payload version or checksum are wrong")
+
+// Dump serializes the value of an entry, without its key or expiry
+func Dump(e *store.Entry) []byte {
+	var buf bytes.Buffer
+	enc := &rdbEncoder{w: bufio.NewWriter(&buf)}
+	enc.byte(rdbType(e.Type))
+	enc.value(e)
+	binary.LittleEndian.PutUint16(enc.buf[:2], dumpVersion)
+	enc.raw(enc.buf[:2])
+	enc.w.Flush()
+
+	return binary.LittleEndian.AppendUint64(buf.Bytes(), crc64.Checksum(buf.Bytes(), crcTable))
+}
+
+// Undump decodes a payload made by Dump. Sorted sets are returned as
+// []store.ScoredMember, like snapshots hand them to a RestoreFunc.
+func Undump(payload []byte) (store.ValueType, any, error) {
+	if len(payload) < 11 {
+		return 0, nil, ErrBadPayload
+	}
+	body, footer := payload[:len(payload)-10], payload[len(payload)-10:]
+	if binary.LittleEndian.Uint16(footer) != dumpVersion ||
+		binary.LittleEndian.Uint64(footer[2:]) != crc64.Checksum(payload[:len(payload)-8], crcTable) {
+		return 0, nil, ErrBadPayload
+	}
+
+	dec := &rdbDecoder{r: bufio.NewReader(bytes.NewReader(body)), h: crc64.New(crcTable)}
+	t, val := dec.value(dec.byte())
+	if dec.err != nil {
+		return 0, nil, ErrBadPayload
+	}
+	if _, err := dec.r.ReadByte(); err == nil {
+		// Trailing bytes the value didn't account for
+		return 0, nil, ErrBadPayload
+	}
+	return t, val, nil
+}
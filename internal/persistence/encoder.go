@@ -2,6 +2,8 @@ package persistence
 
 import (
 	"bytes"
+	"strconv"
+	"time"
 
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
 )
@@ -24,3 +26,9 @@ func EncodeCommand(cmd string, args []string) []byte {
 
 	return buf.Bytes()
 }
+
+// EncodeExpireAt encodes an expiry as an absolute PEXPIREAT, so replaying
+// it later never gives the key a fresh TTL
+func EncodeExpireAt(key string, at time.Time) []byte {
+	return EncodeCommand("PEXPIREAT", []string{key, strconv.FormatInt(at.UnixMilli(), 10)})
+}
@@ -0,0 +1,39 @@
+package persistence
+
+import "fmt"
+
+// FsyncPolicy controls when the AOF is flushed to disk, like Redis' appendfsync
+type FsyncPolicy int
+
+const (
+	// FsyncEverySec fsyncs from the writer once per second, losing at most
+	// about a second of writes on power loss
+	FsyncEverySec FsyncPolicy = iota
+	// FsyncAlways fsyncs before a write command gets its reply
+	FsyncAlways
+	// FsyncNo leaves flushing to the operating system
+	FsyncNo
+)
+
+func (p FsyncPolicy) String() string {
+	switch p {
+	case FsyncAlways:
+		return "always"
+	case FsyncNo:
+		return "no"
+	}
+	return "everysec"
+}
+
+// ParseFsyncPolicy parses an appendfsync setting
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch s {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	}
+	return 0, fmt.Errorf("invalid appendfsync policy %q, want always, everysec or no", s)
+}
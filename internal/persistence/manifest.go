@@ -0,0 +1,303 @@
+package persistence
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileType is the role of a file in a multi-part AOF
+type FileType byte
+
+const (
+	// BaseFile holds the dataset as of the last rewrite, either a snapshot
+	// or the commands that rebuild it. There is at most one.
+	BaseFile FileType = 'b'
+
+	// IncrFile holds the commands appended after the base, in seq order.
+	// The last one is the file being written.
+	IncrFile FileType = 'i'
+)
+
+// ManifestFile is one part of a multi-part AOF
+type ManifestFile struct {
+	Name string
+	Seq  int64
+	Type FileType
+}
+
+// Manifest lists the files of a multi-part AOF in replay order: the base
+// file, if any, then the incremental files. It is stored next to them, one
+// line per file in the same format as Redis:
+//
+//	file appendonly.aof.1.base.rdb seq 1 type b
+//	file appendonly.aof.1.incr.aof seq 1 type i
+type Manifest struct {
+	Files []ManifestFile
+}
+
+// Base returns the base file, nil when there is none yet
+func (m *Manifest) Base() *ManifestFile {
+	for i := range m.Files {
+		if m.Files[i].Type == BaseFile {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// Incrs returns the incremental files in seq order
+func (m *Manifest) Incrs() []ManifestFile {
+	var incrs []ManifestFile
+	for _, f := range m.Files {
+		if f.Type == IncrFile {
+			incrs = append(incrs, f)
+		}
+	}
+	return incrs
+}
+
+// Last returns the file being appended to, nil without incremental files
+func (m *Manifest) Last() *ManifestFile {
+	if n := len(m.Files); n > 0 && m.Files[n-1].Type == IncrFile {
+		return &m.Files[n-1]
+	}
+	return nil
+}
+
+// Contains reports whether the manifest lists a file with that name
+func (m *Manifest) Contains(name string) bool {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSeq returns the seq for a new file of type t
+func (m *Manifest) nextSeq(t FileType) int64 {
+	var seq int64
+	for _, f := range m.Files {
+		if f.Type == t {
+			seq = max(seq, f.Seq)
+		}
+	}
+	return seq + 1
+}
+
+// ManifestPath returns the manifest of the AOF called name in dir
+func ManifestPath(dir, name string) string {
+	return filepath.Join(dir, name+".manifest")
+}
+
+func baseFileName(name string, seq int64, preamble bool) string {
+	if preamble {
+		return fmt.Sprintf("%s.%d.base.rdb", name, seq)
+	}
+	return fmt.Sprintf("%s.%d.base.aof", name, seq)
+}
+
+func incrFileName(name string, seq int64) string {
+	return fmt.Sprintf("%s.%d.incr.aof", name, seq)
+}
+
+// ReadManifest reads the manifest of the AOF called name in dir. Returns
+// an error wrapping fs.ErrNotExist when there is none.
+func ReadManifest(dir, name string) (*Manifest, error) {
+	f, err := os.Open(ManifestPath(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := ParseManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("AOF manifest %s: %w", ManifestPath(dir, name), err)
+	}
+	return m, nil
+}
+
+// ParseManifest parses a manifest and checks that the files are in replay
+// order
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	sc := bufio.NewScanner(r)
+
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields)%2 != 0 {
+			return nil, fmt.Errorf("line %d: invalid entry %q", line, text)
+		}
+
+		var f ManifestFile
+		for i := 0; i < len(fields); i += 2 {
+			switch value := fields[i+1]; fields[i] {
+			case "file":
+				f.Name = value
+			case "seq":
+				seq, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || seq <= 0 {
+					return nil, fmt.Errorf("line %d: invalid seq %q", line, value)
+				}
+				f.Seq = seq
+			case "type":
+				if len(value) != 1 {
+					return nil, fmt.Errorf("line %d: invalid type %q", line, value)
+				}
+				f.Type = FileType(value[0])
+			}
+		}
+
+		// Names come from the manifest, never let them point outside dir
+		if f.Name == "" || f.Name != filepath.Base(f.Name) || f.Seq == 0 {
+			return nil, fmt.Errorf("line %d: invalid entry %q", line, text)
+		}
+		if f.Type != BaseFile && f.Type != IncrFile {
+			return nil, fmt.Errorf("line %d: unknown file type %q", line, string(f.Type))
+		}
+		m.Files = append(m.Files, f)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, m.check()
+}
+
+// check verifies the files are in replay order: at most one base file,
+// first, then incremental files with increasing seq
+func (m *Manifest) check() error {
+	var last int64
+	for i, f := range m.Files {
+		if f.Type == BaseFile {
+			if i != 0 {
+				return errors.New("base file must be listed first")
+			}
+			continue
+		}
+		if f.Seq <= last {
+			return fmt.Errorf("incremental file %s is out of order", f.Name)
+		}
+		last = f.Seq
+	}
+	return nil
+}
+
+// Write replaces the manifest of the AOF called name in dir through a
+// temp file and a rename, so readers always see a complete manifest
+func (m *Manifest) Write(dir, name string) error {
+	path := ManifestPath(dir, name)
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+
+	var b strings.Builder
+	for _, f := range m.Files {
+		fmt.Fprintf(&b, "file %s seq %d type %c\n", f.Name, f.Seq, f.Type)
+	}
+
+	err := writeFileSync(tmp, []byte(b.String()))
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// writeFileSync writes data to a new file at path and fsyncs it
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir fsyncs a directory, making renames and new files in it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, fs.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// openManifest loads the manifest in dir, creating the directory and an
+// empty manifest on first start. A single-file AOF called name in the
+// working directory, the layout before manifests, is moved into dir as the
+// base file.
+func openManifest(dir, name string) (*Manifest, error) {
+	m, err := ReadManifest(dir, name)
+	if err == nil {
+		return m, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	moved := filepath.Join(dir, name)
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		if err := os.Rename(name, moved); err != nil {
+			return nil, fmt.Errorf("moving %s into %s: %w", name, dir, err)
+		}
+	}
+
+	// The manifest listing the moved file is only written once the first
+	// incremental file exists. If a crash came in between, the file is
+	// already in dir and still the base.
+	m = &Manifest{}
+	if info, err := os.Stat(moved); err == nil && info.Mode().IsRegular() {
+		m.Files = append(m.Files, ManifestFile{Name: name, Seq: 1, Type: BaseFile})
+	}
+	return m, nil
+}
+
+// removeStale deletes files of the AOF called name in dir that the manifest
+// no longer lists, left behind when a crash interrupted a rewrite. A base
+// file taken over from before manifests is never one of them.
+func removeStale(dir, name string, m *Manifest) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		n := e.Name()
+		if !strings.HasPrefix(n, name+".") || n == name+".manifest" || m.Contains(n) {
+			continue
+		}
+		if strings.HasSuffix(n, ".base.rdb") || strings.HasSuffix(n, ".base.aof") ||
+			strings.HasSuffix(n, ".incr.aof") || strings.HasSuffix(n, ".tmp") {
+			os.Remove(filepath.Join(dir, n))
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+)
+
+// Binary snapshot format, loosely modelled on Redis' RDB:
+//
+//	"GORDB" version          9 bytes
+//	0xFA key value           aux field, repeated
+//	[0xFC ms] type key value one per key, ms is an absolute expiry
+//	0xFF crc64               end of file
+//
+// Lengths are uvarints, strings are a length followed by the bytes, and
+// fixed size numbers are little endian. The CRC64 (ECMA) covers every byte
+// before it.
+const (
+	rdbMagic   = "GORDB"
+	rdbVersion = "0001"
+
+	rdbOpAux    = 0xFA
+	rdbOpExpiry = 0xFC
+	rdbOpEOF    = 0xFF
+
+	rdbTypeString = 0
+	rdbTypeList   = 1
+	rdbTypeSet    = 2
+	rdbTypeHash   = 3
+	rdbTypeZSet   = 4
+
+	// Larger lengths can only come from a corrupted file
+	rdbMaxLen = 512 << 20
+
+	// Strings up to this size are read into a buffer allocated up front,
+	// longer ones grow as their bytes arrive, so a RESTORE payload claiming
+	// a huge length it doesn't hold costs no memory
+	rdbPrealloc = 64 << 10
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// ErrBadSnapshot is returned for a truncated or corrupted snapshot
+var ErrBadSnapshot = errors.New("bad snapshot file format")
+
+// SnapshotInfo is the metadata stored in a snapshot's aux fields
+type SnapshotInfo struct {
+	Created time.Time
+
+	// Where the AOF stood when the snapshot was taken, nil without an AOF
+	AOF *AOFPosition
+}
+
+// AOFPosition identifies a point in the AOF: the incremental file being
+// written, its size once every record accepted so far is written, and the
+// CRC64 of those bytes. A snapshot only continues into an AOF that still
+// lists the file and whose prefix still matches.
+type AOFPosition struct {
+	File   string
+	Offset int64
+	CRC    uint64
+}
+
+// RestoreFunc receives each key read from a snapshot. Sorted sets are
+// passed as []store.ScoredMember, see store.Store.Restore.
+type RestoreFunc func(key string, t store.ValueType, val any, expiry time.Time)
+
+// SaveSnapshot writes data to path through a temp file and a rename, so a
+// crash never leaves a half written snapshot behind
+func SaveSnapshot(path string, info SnapshotInfo, data map[string]*store.Entry) error {
+	tmp := fmt.Sprintf("%s.save-%d.tmp", path, os.Getpid())
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = WriteSnapshot(f, info, data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// WriteSnapshot encodes data in the snapshot format
+func WriteSnapshot(w io.Writer, info SnapshotInfo, data map[string]*store.Entry) error {
+	h := crc64.New(crcTable)
+	enc := &rdbEncoder{w: bufio.NewWriter(io.MultiWriter(w, h))}
+
+	enc.raw([]byte(rdbMagic + rdbVersion))
+	enc.aux("ctime", strconv.FormatInt(info.Created.Unix(), 10))
+	if info.AOF != nil {
+		enc.aux("aof-file", info.AOF.File)
+		enc.aux("aof-offset", strconv.FormatInt(info.AOF.Offset, 10))
+		enc.aux("aof-crc", strconv.FormatUint(info.AOF.CRC, 10))
+	}
+
+	for key, e := range data {
+		enc.entry(key, e)
+	}
+	enc.byte(rdbOpEOF)
+
+	if enc.err != nil {
+		return enc.err
+	}
+	if err := enc.w.Flush(); err != nil {
+		return err
+	}
+
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], h.Sum64())
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// LoadSnapshot reads the snapshot at path and hands every key to restore.
+// Returns an error wrapping os.ErrNotExist when there is no snapshot.
+func LoadSnapshot(path string, restore RestoreFunc) (SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f, restore)
+}
+
+// ReadSnapshotInfo reads only the aux fields at the start of a snapshot,
+// without loading or verifying the keys
+func ReadSnapshotInfo(path string) (SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f, nil)
+}
+
+// ReadSnapshot decodes a snapshot from r, handing every key to restore.
+// With a nil restore it stops after the aux fields.
+func ReadSnapshot(r io.Reader, restore RestoreFunc) (SnapshotInfo, error) {
+	dec := &rdbDecoder{r: bufio.NewReader(r), h: crc64.New(crcTable)}
+	var info SnapshotInfo
+
+	if magic := dec.bytes(len(rdbMagic) + len(rdbVersion)); dec.err == nil && string(magic) != rdbMagic+rdbVersion {
+		return info, fmt.Errorf("%w: unknown header %q", ErrBadSnapshot, magic)
+	}
+
+	var aofFile, aofOffset, aofCRC string
+	for dec.err == nil {
+		op := dec.byte()
+		if dec.err != nil {
+			break
+		}
+
+		switch op {
+		case rdbOpAux:
+			key, value := dec.string(), dec.string()
+			switch key {
+			case "ctime":
+				sec, _ := strconv.ParseInt(value, 10, 64)
+				info.Created = time.Unix(sec, 0)
+			case "aof-file":
+				aofFile = value
+			case "aof-offset":
+				aofOffset = value
+			case "aof-crc":
+				aofCRC = value
+			}
+			continue
+		}
+
+		// Past the aux fields
+		if aofOffset != "" {
+			offset, err1 := strconv.ParseInt(aofOffset, 10, 64)
+			crc, err2 := strconv.ParseUint(aofCRC, 10, 64)
+			if err1 != nil || err2 != nil {
+				return info, fmt.Errorf("%w: invalid AOF position", ErrBadSnapshot)
+			}
+			info.AOF = &AOFPosition{File: aofFile, Offset: offset, CRC: crc}
+			aofOffset = ""
+		}
+		if restore == nil {
+			return info, nil
+		}
+
+		if op == rdbOpEOF {
+			want := dec.h.Sum64()
+			sum := make([]byte, 8)
+			if _, err := io.ReadFull(dec.r, sum); err != nil {
+				return info, fmt.Errorf("%w: missing checksum", ErrBadSnapshot)
+			}
+			if binary.LittleEndian.Uint64(sum) != want {
+				return info, fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+			}
+			return info, nil
+		}
+
+		var expiry time.Time
+		if op == rdbOpExpiry {
+			expiry = time.UnixMilli(int64(dec.uint64()))
+			op = dec.byte()
+		}
+
+		key := dec.string()
+		t, val := dec.value(op)
+		if dec.err == nil {
+			restore(key, t, val, expiry)
+		}
+	}
+
+	if dec.err == io.EOF || dec.err == io.ErrUnexpectedEOF {
+		return info, fmt.Errorf("%w: unexpected end of file", ErrBadSnapshot)
+	}
+	return info, dec.err
+}
+
+// rdbEncoder writes snapshot primitives, keeping the first error
+type rdbEncoder struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *rdbEncoder) raw(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *rdbEncoder) byte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *rdbEncoder) uvarint(n uint64) {
+	e.raw(e.buf[:binary.PutUvarint(e.buf[:], n)])
+}
+
+func (e *rdbEncoder) uint64(n uint64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], n)
+	e.raw(e.buf[:8])
+}
+
+func (e *rdbEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+func (e *rdbEncoder) aux(key, value string) {
+	e.byte(rdbOpAux)
+	e.string(key)
+	e.string(value)
+}
+
+func (e *rdbEncoder) entry(key string, entry *store.Entry) {
+	if !entry.Expiry.IsZero() {
+		e.byte(rdbOpExpiry)
+		e.uint64(uint64(entry.Expiry.UnixMilli()))
+	}
+	e.byte(rdbType(entry.Type))
+	e.string(key)
+	e.value(entry)
+}
+
+func rdbType(t store.ValueType) byte {
+	switch t {
+	case store.ListType:
+		return rdbTypeList
+	case store.SetType:
+		return rdbTypeSet
+	case store.HashType:
+		return rdbTypeHash
+	case store.ZSetType:
+		return rdbTypeZSet
+	}
+	return rdbTypeString
+}
+
+// value writes an entry's value, the decoder needs its rdbType to read it
+func (e *rdbEncoder) value(entry *store.Entry) {
+	switch entry.Type {
+	case store.StringType:
+		e.string(entry.Value.(string))
+
+	case store.ListType:
+		list := entry.Value.([]string)
+		e.uvarint(uint64(len(list)))
+		for _, item := range list {
+			e.string(item)
+		}
+
+	case store.SetType:
+		set := entry.Value.(map[string]struct{})
+		e.uvarint(uint64(len(set)))
+		for member := range set {
+			e.string(member)
+		}
+
+	case store.HashType:
+		hash := entry.Value.(map[string]string)
+		e.uvarint(uint64(len(hash)))
+		for field, value := range hash {
+			e.string(field)
+			e.string(value)
+		}
+
+	case store.ZSetType:
+		members := entry.ZSetMembers()
+		e.uvarint(uint64(len(members)))
+		for _, m := range members {
+			e.string(m.Member)
+			e.uint64(math.Float64bits(m.Score))
+		}
+	}
+}
+
+// rdbDecoder reads snapshot primitives, keeping the first error and a
+// running checksum of everything read
+type rdbDecoder struct {
+	r   *bufio.Reader
+	h   hash.Hash64
+	err error
+}
+
+func (d *rdbDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	d.h.Write([]byte{b})
+	return b
+}
+
+func (d *rdbDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	var b []byte
+	if n <= rdbPrealloc {
+		b = make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			d.err = err
+			return nil
+		}
+	} else {
+		var buf bytes.Buffer
+		buf.Grow(rdbPrealloc)
+		if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			d.err = err
+			return nil
+		}
+		b = buf.Bytes()
+	}
+	d.h.Write(b)
+	return b
+}
+
+func (d *rdbDecoder) uvarint() uint64 {
+	var n uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b := d.byte()
+		n |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return n
+		}
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: invalid length", ErrBadSnapshot)
+	}
+	return 0
+}
+
+func (d *rdbDecoder) length() int {
+	n := d.uvarint()
+	if n > rdbMaxLen {
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: length %d out of range", ErrBadSnapshot, n)
+		}
+		return 0
+	}
+	return int(n)
+}
+
+func (d *rdbDecoder) uint64() uint64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *rdbDecoder) string() string {
+	return string(d.bytes(d.length()))
+}
+
+func (d *rdbDecoder) value(op byte) (store.ValueType, any) {
+	switch op {
+	case rdbTypeString:
+		return store.StringType, d.string()
+
+	case rdbTypeList:
+		n := d.length()
+		list := make([]string, 0, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			list = append(list, d.string())
+		}
+		return store.ListType, list
+
+	case rdbTypeSet:
+		n := d.length()
+		set := make(map[string]struct{}, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			set[d.string()] = struct{}{}
+		}
+		return store.SetType, set
+
+	case rdbTypeHash:
+		n := d.length()
+		hash := make(map[string]string, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			field := d.string()
+			hash[field] = d.string()
+		}
+		return store.HashType, hash
+
+	case rdbTypeZSet:
+		n := d.length()
+		members := make([]store.ScoredMember, 0, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			member := d.string()
+			score := math.Float64frombits(d.uint64())
+			if math.IsNaN(score) {
+				d.err = fmt.Errorf("%w: NaN score", ErrBadSnapshot)
+				break
+			}
+			members = append(members, store.ScoredMember{Member: member, Score: score})
+		}
+		return store.ZSetType, members
+	}
+
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: unknown value type %d", ErrBadSnapshot, op)
+	}
+	return 0, nil
+}
@@ -1,27 +1,203 @@
 package persistence
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
 )
 
-func Replay(path string, dispatch func(resp.Value)) error {
+// LoadError reports where reading the AOF stopped
+type LoadError struct {
+	// The file that couldn't be read, empty for a single file
+	File string
+
+	// End of the last complete record; the file can be cut here to drop
+	// everything that couldn't be read. A MULTI block without its EXEC
+	// counts as incomplete, so this is never inside a transaction.
+	Offset int64
+
+	// The file just ends early, as a torn final write after a crash
+	// leaves it. Otherwise it has unreadable data at Offset. Only the last
+	// incremental file can be truncated, any other file is still read to
+	// the end before the next one is started.
+	Truncated bool
+
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	file := "AOF"
+	if e.File != "" {
+		file = "AOF file " + e.File
+	}
+	if e.Truncated {
+		return fmt.Sprintf("%s is truncated, last complete record ends at byte offset %d", file, e.Offset)
+	}
+	return fmt.Sprintf("bad %s format at byte offset %d: %v", file, e.Offset, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Replay loads the AOF called name in dir, every file its manifest lists
+// in order, see ReplayFile. A file that can't be read to the end returns a
+// *LoadError, after everything before it was applied.
+func Replay(dir, name string, restore RestoreFunc, dispatch func(resp.Value)) error {
+	return ReplayFrom(dir, name, nil, restore, dispatch)
+}
+
+// ReplayFrom replays the records after pos, used when a snapshot already
+// covers the AOF up to there: the files before pos.File are skipped, and
+// pos.File is read from pos.Offset. A nil pos replays everything.
+func ReplayFrom(dir, name string, pos *AOFPosition, restore RestoreFunc, dispatch func(resp.Value)) error {
+	m, err := ReadManifest(dir, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // no AOF yet
+	}
+	if err != nil {
+		return err
+	}
+
+	files := m.Files
+	var offset int64
+	if pos != nil {
+		i := 0
+		for i < len(files) && files[i].Name != pos.File {
+			i++
+		}
+		if i == len(files) {
+			return fmt.Errorf("AOF file %s is not in the manifest", pos.File)
+		}
+		files, offset = files[i:], pos.Offset
+	}
+
+	for i, f := range files {
+		err := ReplayFile(filepath.Join(dir, f.Name), offset, restore, dispatch)
+		var loadErr *LoadError
+		if errors.As(err, &loadErr) {
+			loadErr.File = f.Name
+			loadErr.Truncated = loadErr.Truncated && i == len(files)-1
+			return loadErr
+		}
+		if err != nil {
+			return fmt.Errorf("AOF file %s: %w", f.Name, err)
+		}
+		offset = 0
+	}
+	return nil
+}
+
+// ReplayFile loads a single AOF file from offset. A file that starts with
+// a snapshot preamble has its keys handed to restore, the RESP records
+// that follow and plain AOF files go to dispatch.
+func ReplayFile(path string, offset int64, restore RestoreFunc, dispatch func(resp.Value)) error {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil // no AOF yet
+		return err
 	}
 	defer f.Close()
 
-	r := resp.NewReader(f)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Both parts read from the same buffer, so the RESP reader continues
+	// exactly where the preamble ends
+	br := bufio.NewReader(f)
+	if offset == 0 && hasPreamble(br) {
+		if _, err := ReadSnapshot(br, restore); err != nil {
+			return fmt.Errorf("AOF preamble: %w", err)
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		offset = pos - int64(br.Buffered())
+	}
+
+	r := resp.NewReader(br)
+
+	// Commands between MULTI and EXEC are only applied once EXEC is read,
+	// a transaction cut off by a crash is dropped as a whole
+	var txn []resp.Value
+	inTxn := false
+	good := offset // end of the last complete record outside a transaction
 
 	for {
 		v, err := r.ReadValue()
-		if err != nil {
+		if err == io.EOF {
 			break
 		}
-		dispatch(v)
+		if err != nil {
+			return &LoadError{Offset: good, Truncated: err == io.ErrUnexpectedEOF, Err: err}
+		}
+
+		switch commandName(v) {
+		case "MULTI":
+			inTxn = true
+			txn = nil
+			continue
+		case "EXEC":
+			for _, queued := range txn {
+				dispatch(queued)
+			}
+			inTxn = false
+			txn = nil
+		case "DISCARD":
+			// Older files closed an interrupted block this way
+			inTxn = false
+			txn = nil
+		default:
+			if inTxn {
+				txn = append(txn, v)
+				continue
+			}
+			dispatch(v)
+		}
+
+		good = offset + r.Offset()
 	}
 
+	if inTxn {
+		return &LoadError{Offset: good, Truncated: true, Err: io.ErrUnexpectedEOF}
+	}
 	return nil
 }
+
+// TruncateAt cuts the file at path to offset, dropping a torn tail
+func TruncateAt(path string, offset int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// hasPreamble reports whether the AOF read by br starts with a snapshot
+func hasPreamble(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(rdbMagic))
+	return string(magic) == rdbMagic
+}
+
+func commandName(v resp.Value) string {
+	if v.Type != resp.Array || len(v.Array) == 0 {
+		return ""
+	}
+	return strings.ToUpper(v.Array[0].Str)
+}
@@ -0,0 +1,253 @@
+package persistence
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+)
+
+// rewriteItemsPerCmd caps the elements per generated command so that huge
+// collections don't turn into a single enormous RESP array
+const rewriteItemsPerCmd = 64
+
+var ErrRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+// StartRewrite compacts the AOF in the background. snapshot must return a
+// point-in-time copy of the dataset, and the caller must make sure no write
+// runs between taking it and StartRewrite returning, so every write ends up
+// either in the snapshot or in the new incremental file - never both.
+func (a *AOF) StartRewrite(snapshot func() map[string]*store.Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.rewriting {
+		return ErrRewriteInProgress
+	}
+
+	// Writes after the snapshot go to a new incremental file, which the
+	// rewritten base is followed by
+	next, err := a.newIncrLocked()
+	if err != nil {
+		return err
+	}
+	rotated := make(chan struct{})
+	a.queueLocked(record{next: next, done: rotated})
+
+	seq := a.manifest.nextSeq(BaseFile)
+	base := ManifestFile{Name: baseFileName(a.name, seq, a.opts.Preamble), Seq: seq, Type: BaseFile}
+	keep := a.manifest.Last().Seq
+
+	data := snapshot()
+	a.rewriting = true
+
+	go a.rewrite(data, base, keep, rotated)
+	return nil
+}
+
+// Rewriting reports whether a background rewrite is running
+func (a *AOF) Rewriting() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rewriting
+}
+
+// ShouldRewrite reports whether the AOF grew enough for an automatic rewrite
+func (a *AOF) ShouldRewrite() bool {
+	if a.opts.AutoRewritePercentage <= 0 || a.Rewriting() {
+		return false
+	}
+
+	size := a.size.Load()
+	if size < a.opts.AutoRewriteMinSize {
+		return false
+	}
+
+	base := a.baseSize.Load()
+	if base == 0 {
+		base = 1
+	}
+	growth := (size - base) * 100 / base
+	return growth >= int64(a.opts.AutoRewritePercentage)
+}
+
+// rewrite writes data as the new base file. It replaces the old base and
+// every incremental file before keep, the first one started after the
+// snapshot. rotated is closed once the writer moved on to that file.
+func (a *AOF) rewrite(data map[string]*store.Entry, base ManifestFile, keep int64, rotated chan struct{}) {
+	start := time.Now()
+	tmp := a.path(fmt.Sprintf("%s.rewrite-%d.tmp", a.name, os.Getpid()))
+
+	size, err := a.writeBase(tmp, data)
+	if err == nil {
+		// The old files may only go once nothing is written to them
+		a.wait(rotated)
+		err = a.finishRewrite(tmp, base, keep, size)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		a.mu.Lock()
+		a.rewriting = false
+		a.mu.Unlock()
+		log.Printf("AOF rewrite failed: %v", err)
+		return
+	}
+
+	log.Printf("AOF rewrite finished in %v (%d keys)", time.Since(start).Round(time.Millisecond), len(data))
+}
+
+// finishRewrite puts the new base in place and lists it in the manifest
+// together with the incremental files from keep on. Writing the manifest
+// is the commit point: a crash before it leaves the old files listed, and
+// the new base is removed as stale on the next start.
+func (a *AOF) finishRewrite(tmp string, base ManifestFile, keep int64, size int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.Rename(tmp, a.path(base.Name)); err != nil {
+		return err
+	}
+
+	m := &Manifest{Files: []ManifestFile{base}}
+	var dropped []ManifestFile
+	for _, f := range a.manifest.Files {
+		if f.Type == IncrFile && f.Seq >= keep {
+			m.Files = append(m.Files, f)
+		} else {
+			dropped = append(dropped, f)
+		}
+	}
+
+	if err := m.Write(a.dir, a.name); err != nil {
+		os.Remove(a.path(base.Name))
+		return err
+	}
+	a.manifest = m
+	a.rewriting = false
+
+	for _, f := range dropped {
+		if info, err := os.Stat(a.path(f.Name)); err == nil {
+			size -= info.Size()
+		}
+		os.Remove(a.path(f.Name))
+	}
+	a.size.Add(size)
+	a.baseSize.Store(a.size.Load())
+
+	// Bytes a failed write left out of the old files are in the new base
+	a.setError(nil)
+	return nil
+}
+
+// writeBase writes the base of the rewritten AOF to path and returns its
+// size. With the preamble option it's a binary snapshot, which loads much
+// faster than commands.
+func (a *AOF) writeBase(path string, data map[string]*store.Entry) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if a.opts.Preamble {
+		err = WriteSnapshot(f, SnapshotInfo{Created: time.Now()}, data)
+	} else {
+		err = writeCommands(f, data)
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return size, err
+}
+
+// writeCommands writes the minimal commands that rebuild data
+func writeCommands(w io.Writer, data map[string]*store.Entry) error {
+	bw := bufio.NewWriter(w)
+	for key, e := range data {
+		if err := writeEntry(bw, key, e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeEntry(w *bufio.Writer, key string, e *store.Entry) error {
+	var cmds [][]string
+
+	switch e.Type {
+	case store.StringType:
+		cmds = append(cmds, []string{"SET", key, e.Value.(string)})
+
+	case store.ListType:
+		cmds = batched("RPUSH", key, e.Value.([]string))
+
+	case store.SetType:
+		members := make([]string, 0, len(e.Value.(map[string]struct{})))
+		for member := range e.Value.(map[string]struct{}) {
+			members = append(members, member)
+		}
+		cmds = batched("SADD", key, members)
+
+	case store.HashType:
+		pairs := make([]string, 0, 2*len(e.Value.(map[string]string)))
+		for field, value := range e.Value.(map[string]string) {
+			pairs = append(pairs, field, value)
+		}
+		cmds = batched("HSET", key, pairs)
+
+	case store.ZSetType:
+		members := e.ZSetMembers()
+		pairs := make([]string, 0, 2*len(members))
+		for _, m := range members {
+			pairs = append(pairs, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+		}
+		cmds = batched("ZADD", key, pairs)
+	}
+
+	for _, cmd := range cmds {
+		if _, err := w.Write(EncodeCommand(cmd[0], cmd[1:])); err != nil {
+			return err
+		}
+	}
+
+	if !e.Expiry.IsZero() {
+		if _, err := w.Write(EncodeExpireAt(key, e.Expiry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batched splits a collection into commands of at most rewriteItemsPerCmd
+// elements (pairs count as two elements)
+func batched(cmd, key string, items []string) [][]string {
+	var cmds [][]string
+	per := rewriteItemsPerCmd
+	if cmd == "HSET" || cmd == "ZADD" {
+		per *= 2
+	}
+
+	for start := 0; start < len(items); start += per {
+		end := min(start+per, len(items))
+		args := append([]string{cmd, key}, items[start:end]...)
+		cmds = append(cmds, args)
+	}
+	return cmds
+}
@@ -0,0 +1,212 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+)
+
+// After a failed background save, scheduled saves wait this long before
+// trying again
+const saveRetryDelay = 5 * time.Second
+
+var ErrSaveInProgress = errors.New("ERR Background save already in progress")
+
+// SaveRule triggers a background save once at least Changes writes happened
+// and Seconds passed since the last save, like Redis' save <seconds> <changes>
+type SaveRule struct {
+	Seconds int
+	Changes int64
+}
+
+// ParseSaveRules parses a Redis style save setting, "3600 1 300 100".
+// An empty string disables scheduled saves.
+func ParseSaveRules(s string) ([]SaveRule, error) {
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save rules %q, want pairs of <seconds> <changes>", s)
+	}
+
+	rules := make([]SaveRule, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err1 := strconv.Atoi(fields[i])
+		changes, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+		if err1 != nil || err2 != nil || seconds < 0 || changes < 0 {
+			return nil, fmt.Errorf("invalid save rule %q", fields[i]+" "+fields[i+1])
+		}
+		rules = append(rules, SaveRule{Seconds: seconds, Changes: changes})
+	}
+	return rules, nil
+}
+
+// RDBStats is a point-in-time view of the snapshot state, reported by INFO
+type RDBStats struct {
+	ChangesSinceSave int64
+	Saving           bool
+	LastSave         time.Time
+	LastSaveErr      error // nil when the last save succeeded
+}
+
+// RDB tracks the snapshot file and runs foreground and background saves.
+// Changes are counted by the store, see store.Store.Changes.
+type RDB struct {
+	path  string
+	rules []SaveRule
+
+	mu          sync.Mutex
+	saving      bool
+	lastSave    time.Time // last successful save, startup time before that
+	lastAttempt time.Time
+	lastErr     error
+	saved       int64 // store change counter at the last save
+	bg          sync.WaitGroup
+}
+
+func NewRDB(path string, rules []SaveRule) *RDB {
+	return &RDB{
+		path:     path,
+		rules:    rules,
+		lastSave: time.Now(),
+	}
+}
+
+// Path returns the snapshot file path
+func (r *RDB) Path() string {
+	return r.path
+}
+
+// Rules returns the scheduled save rules
+func (r *RDB) Rules() []SaveRule {
+	return r.rules
+}
+
+// Save writes the snapshot in the foreground. data must be a point-in-time
+// copy of the dataset and changes the store change counter at that point.
+func (r *RDB) Save(info SnapshotInfo, data map[string]*store.Entry, changes int64) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+	return r.save(info, data, changes)
+}
+
+// StartBackground writes the snapshot from a background goroutine, see Save
+func (r *RDB) StartBackground(info SnapshotInfo, data map[string]*store.Entry, changes int64) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+
+	r.bg.Add(1)
+	go func() {
+		defer r.bg.Done()
+		r.save(info, data, changes)
+	}()
+	return nil
+}
+
+func (r *RDB) begin() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.saving {
+		return ErrSaveInProgress
+	}
+	r.saving = true
+	return nil
+}
+
+func (r *RDB) save(info SnapshotInfo, data map[string]*store.Entry, changes int64) error {
+	start := time.Now()
+	err := SaveSnapshot(r.path, info, data)
+
+	r.mu.Lock()
+	r.saving = false
+	r.lastAttempt = time.Now()
+	r.lastErr = err
+	if err == nil {
+		r.lastSave = info.Created
+		r.saved = changes
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Snapshot save failed: %v", err)
+		return err
+	}
+	log.Printf("Snapshot saved in %v (%d keys)", time.Since(start).Round(time.Millisecond), len(data))
+	return nil
+}
+
+// Wait blocks until a running background save has finished
+func (r *RDB) Wait() {
+	r.bg.Wait()
+}
+
+// Saving reports whether a save is running
+func (r *RDB) Saving() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saving
+}
+
+// LastSave returns the time of the last successful save
+func (r *RDB) LastSave() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastSave
+}
+
+// MarkClean treats the dataset as saved at the given change counter, used
+// after loading so restored keys don't count as unsaved changes
+func (r *RDB) MarkClean(changes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = changes
+}
+
+// Dirty reports whether there are changes since the last save
+func (r *RDB) Dirty(changes int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return changes > r.saved
+}
+
+// ShouldSave reports whether a save rule is due
+func (r *RDB) ShouldSave(changes int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.saving {
+		return false
+	}
+	if r.lastErr != nil && time.Since(r.lastAttempt) < saveRetryDelay {
+		return false
+	}
+
+	dirty := changes - r.saved
+	elapsed := time.Since(r.lastSave)
+	for _, rule := range r.rules {
+		if dirty >= rule.Changes && dirty > 0 && elapsed >= time.Duration(rule.Seconds)*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the current snapshot state
+func (r *RDB) Stats(changes int64) RDBStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RDBStats{
+		ChangesSinceSave: changes - r.saved,
+		Saving:           r.saving,
+		LastSave:         r.lastSave,
+		LastSaveErr:      r.lastErr,
+	}
+}
@@ -24,3 +24,58 @@ func BulkValue(s string) Value {
 func ArrayValue(arr []Value) Value {
 	return Value{Type: Array, Array: arr}
 }
+
+// NullBulkValue creates a null bulk string response Value, the nil reply
+// for a missing key or field. BulkValue("") is an empty string.
+func NullBulkValue() Value {
+	return Value{Type: BulkString, Null: true}
+}
+
+// NullArrayValue creates a null array response Value
+func NullArrayValue() Value {
+	return Value{Type: Array, Null: true}
+}
+
+// NullValue creates a RESP3 null, written as a null bulk string to RESP2
+// clients
+func NullValue() Value {
+	return Value{Type: Null}
+}
+
+// DoubleValue creates a RESP3 double, a bulk string for RESP2
+func DoubleValue(f float64) Value {
+	return Value{Type: Double, Float: f}
+}
+
+// BoolValue creates a RESP3 boolean, the integer 1 or 0 for RESP2
+func BoolValue(b bool) Value {
+	return Value{Type: Boolean, Bool: b}
+}
+
+// BigNumberValue creates a RESP3 big number from its decimal digits, a bulk
+// string for RESP2
+func BigNumberValue(digits string) Value {
+	return Value{Type: BigNumber, Str: digits}
+}
+
+// VerbatimValue creates a RESP3 verbatim string, format is "txt" for plain
+// text or "mkd" for markdown. RESP2 gets a bulk string.
+func VerbatimValue(format, s string) Value {
+	return Value{Type: Verbatim, Format: format, Str: s}
+}
+
+// MapValue creates a RESP3 map from alternating keys and values, a flat
+// array for RESP2
+func MapValue(pairs []Value) Value {
+	return Value{Type: Map, Array: pairs}
+}
+
+// SetValue creates a RESP3 set, an array for RESP2
+func SetValue(arr []Value) Value {
+	return Value{Type: Set, Array: arr}
+}
+
+// PushValue creates a RESP3 push, an array for RESP2
+func PushValue(arr []Value) Value {
+	return Value{Type: Push, Array: arr}
+}
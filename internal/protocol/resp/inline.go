@@ -0,0 +1,197 @@
+package resp
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrUnbalancedQuotes is returned for an inline command with an unterminated
+// quoted argument, or a closing quote not followed by a space
+var ErrUnbalancedQuotes error = &ProtocolError{Msg: "unbalanced quotes in request"}
+
+// ReadCommand reads the next command sent by a client. Besides RESP arrays
+// of bulk strings it accepts inline commands, a plain line of space
+// separated arguments as typed into telnet or netcat. Either way the
+// command is returned as an array of bulk strings. Blank lines and empty
+// arrays are skipped.
+func (rd *Reader) ReadCommand() (Value, error) {
+	for {
+		prefix, err := rd.r.Peek(1)
+		if err != nil {
+			return Value{}, err
+		}
+
+		start := rd.n
+		var v Value
+		if ValueType(prefix[0]) == Array {
+			v, err = rd.readRequest()
+		} else {
+			v, err = rd.readInline()
+		}
+		if err == io.EOF && rd.n > start {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return Value{}, err
+		}
+
+		if len(v.Array) > 0 {
+			return v, nil
+		}
+	}
+}
+
+// readRequest reads a command sent as a RESP array. Unlike replies, its
+// elements can only be bulk strings.
+func (rd *Reader) readRequest() (Value, error) {
+	if _, err := rd.r.ReadByte(); err != nil {
+		return Value{}, err
+	}
+	rd.n++
+
+	count, err := rd.readCount()
+	if err != nil {
+		return Value{}, err
+	}
+
+	arr := make([]Value, 0, min(max(count, 0), preallocElems))
+	for i := 0; i < count; i++ {
+		prefix, err := rd.r.ReadByte()
+		if err != nil {
+			return Value{}, err
+		}
+		rd.n++
+		if ValueType(prefix) != BulkString {
+			return Value{}, protocolError("expected '$', got '%c'", prefix)
+		}
+
+		size, err := rd.readLength("bulk", rd.limits.MaxBulkLen)
+		if err != nil {
+			return Value{}, err
+		}
+		if size < 0 {
+			return Value{}, protocolError("invalid bulk length")
+		}
+
+		s, err := rd.readBlob(size)
+		if err != nil {
+			return Value{}, err
+		}
+		arr = append(arr, BulkValue(s))
+	}
+	return ArrayValue(arr), nil
+}
+
+func (rd *Reader) readInline() (Value, error) {
+	line, err := rd.readRawLine("inline request")
+	if err != nil {
+		return Value{}, err
+	}
+
+	args, err := SplitArgs(line)
+	if err != nil {
+		return Value{}, err
+	}
+
+	arr := make([]Value, len(args))
+	for i, arg := range args {
+		arr[i] = BulkValue(arg)
+	}
+	return ArrayValue(arr), nil
+}
+
+// SplitArgs splits an inline command line into arguments the way Redis
+// does. Arguments are separated by whitespace and may be quoted: double
+// quotes understand \n, \r, \t, \b, \a, \\, \" and \xHH escapes, single
+// quotes only \'.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		// Skip blanks between arguments
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			switch {
+			case inDouble:
+				switch {
+				case i == len(line):
+					return nil, ErrUnbalancedQuotes
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					arg.WriteByte(byte(b))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					switch c := line[i]; c {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					case 'b':
+						arg.WriteByte('\b')
+					case 'a':
+						arg.WriteByte('\a')
+					default:
+						arg.WriteByte(c)
+					}
+				case line[i] == '"':
+					// The closing quote must end the argument
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(line[i])
+				}
+			case inSingle:
+				switch {
+				case i == len(line):
+					return nil, ErrUnbalancedQuotes
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					arg.WriteByte('\'')
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(line[i])
+				}
+			default:
+				switch {
+				case i == len(line) || isSpace(line[i]):
+					done = true
+					continue
+				case line[i] == '"':
+					inDouble = true
+				case line[i] == '\'':
+					inSingle = true
+				default:
+					arg.WriteByte(line[i])
+				}
+			}
+			i++
+		}
+		args = append(args, arg.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -4,12 +4,52 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 )
 
+// Limits the server puts on what clients send, as in Redis
+const (
+	DefaultMaxBulkLen      = 512 << 20 // proto-max-bulk-len
+	DefaultMaxMultiBulkLen = 1 << 20   // arguments in one command
+	DefaultMaxInlineLen    = 64 << 10  // inline command or header line
+)
+
+// Bulk strings up to this size are read into a buffer allocated up front,
+// longer ones grow as their bytes arrive, so a length a client claims but
+// never sends costs no memory
+const preallocBlob = 64 << 10
+
+// Aggregates get room for this many elements up front, bigger ones grow
+// as their elements arrive
+const preallocElems = 1024
+
+// Limits caps what a Reader accepts, 0 means no limit
+type Limits struct {
+	MaxBulkLen      int64 // longest bulk string
+	MaxMultiBulkLen int   // most elements in one aggregate
+	MaxInlineLen    int   // longest line, inline commands included
+}
+
+// ProtocolError is returned for input that breaks the protocol or a limit.
+// The server replies with it before closing the connection.
+type ProtocolError struct {
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Msg
+}
+
+func protocolError(format string, args ...any) error {
+	return &ProtocolError{Msg: fmt.Sprintf(format, args...)}
+}
+
 type Reader struct {
-	r *bufio.Reader
+	r      *bufio.Reader
+	n      int64 // bytes consumed, see Offset
+	limits Limits
 }
 
 func NewReader(rd io.Reader) *Reader {
@@ -18,11 +58,35 @@ func NewReader(rd io.Reader) *Reader {
 	}
 }
 
+// SetLimits caps the sizes the reader accepts from now on. Values beyond
+// them fail with a *ProtocolError.
+func (rd *Reader) SetLimits(l Limits) {
+	rd.limits = l
+}
+
+// Offset returns the number of bytes consumed so far. After a successful
+// ReadValue it is the end of that value.
+func (rd *Reader) Offset() int64 {
+	return rd.n
+}
+
+// ReadValue reads the next value. io.EOF means the input ended cleanly
+// between values, io.ErrUnexpectedEOF that it ended in the middle of one.
 func (rd *Reader) ReadValue() (Value, error) {
+	start := rd.n
+	v, err := rd.readValue()
+	if err == io.EOF && rd.n > start {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (rd *Reader) readValue() (Value, error) {
 	prefix, err := rd.r.ReadByte()
 	if err != nil {
 		return Value{}, err
 	}
+	rd.n++
 
 	switch ValueType(prefix) {
 	case SimpleString:
@@ -35,18 +99,71 @@ func (rd *Reader) ReadValue() (Value, error) {
 		return rd.readArray()
 	case Error:
 		return rd.readError()
+	case Null:
+		return rd.readNull()
+	case Double:
+		return rd.readDouble()
+	case Boolean:
+		return rd.readBoolean()
+	case BigNumber:
+		return rd.readBigNumber()
+	case Verbatim:
+		return rd.readVerbatim()
+	case Map, Set, Push:
+		return rd.readAggregate(ValueType(prefix))
+	case Attribute:
+		return rd.readAttribute()
 	default:
-		return Value{}, fmt.Errorf("unknown RESP type: %q", prefix)
+		return Value{}, protocolError("unknown RESP type %q", prefix)
 	}
 }
 
+// readLine reads a line and checks it ends in CRLF
 func (rd *Reader) readLine() (string, error) {
-	line, err := rd.r.ReadString('\n')
+	line, err := rd.readRawLine("line")
 	if err != nil {
 		return "", err
 	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", protocolError("expected CRLF at the end of the line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// readRawLine reads up to and including a newline. A line over the inline
+// limit fails before it is buffered whole, what names it in the error.
+func (rd *Reader) readRawLine(what string) (string, error) {
+	var line []byte
+	for {
+		chunk, err := rd.r.ReadSlice('\n')
+		rd.n += int64(len(chunk))
+		line = append(line, chunk...)
+		if max := rd.limits.MaxInlineLen; max > 0 && len(line) > max {
+			return "", protocolError("too big %s", what)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(line), nil
+	}
+}
+
+// readLength reads the length line of a bulk string or aggregate, -1 is a
+// null
+func (rd *Reader) readLength(what string, max int64) (int64, error) {
+	line, err := rd.readLine()
+	if err != nil {
+		return 0, err
+	}
 
-	return strings.TrimSuffix(line, "\r\n"), nil
+	n, err := strconv.ParseInt(line, 10, 64)
+	if err != nil || n < -1 || (max > 0 && n > max) {
+		return 0, protocolError("invalid %s length", what)
+	}
+	return n, nil
 }
 
 func (rd *Reader) readSimpleString() (Value, error) {
@@ -69,7 +186,7 @@ func (rd *Reader) readInteger() (Value, error) {
 
 	n, err := strconv.ParseInt(line, 10, 64)
 	if err != nil {
-		return Value{}, err
+		return Value{}, protocolError("invalid integer %q", line)
 	}
 
 	return Value{
@@ -79,43 +196,96 @@ func (rd *Reader) readInteger() (Value, error) {
 }
 
 func (rd *Reader) readBulkString() (Value, error) {
-	line, err := rd.readLine()
+	size, err := rd.readLength("bulk", rd.limits.MaxBulkLen)
 	if err != nil {
 		return Value{}, err
 	}
 
-	size, _ := strconv.Atoi(line)
-
 	if size == -1 {
-		return Value{Type: BulkString}, nil
+		return Value{Type: BulkString, Null: true}, nil
 	}
 
-	buf := make([]byte, size+2)
-	if _, err := io.ReadFull(rd.r, buf); err != nil {
+	s, err := rd.readBlob(size)
+	if err != nil {
 		return Value{}, err
 	}
 
 	return Value{
 		Type: BulkString,
-		Str:  string(buf[:size]),
+		Str:  s,
 	}, nil
 
 }
 
-func (rd *Reader) readArray() (Value, error) {
-	line, err := rd.readLine()
+// readBlob reads size bytes and the CRLF after them
+func (rd *Reader) readBlob(size int64) (string, error) {
+	var s string
+	if size <= preallocBlob {
+		buf := make([]byte, size)
+		n, err := io.ReadFull(rd.r, buf)
+		rd.n += int64(n)
+		if err != nil {
+			return "", eof(err)
+		}
+		s = string(buf)
+	} else {
+		var b strings.Builder
+		b.Grow(preallocBlob)
+		n, err := io.CopyN(&b, rd.r, size)
+		rd.n += n
+		if err != nil {
+			return "", eof(err)
+		}
+		s = b.String()
+	}
+
+	var crlf [2]byte
+	n, err := io.ReadFull(rd.r, crlf[:])
+	rd.n += int64(n)
 	if err != nil {
-		return Value{}, err
+		return "", eof(err)
+	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return "", protocolError("expected CRLF after %d bytes of data", size)
 	}
+	return s, nil
+}
+
+// eof turns a short read into io.EOF, ReadValue tells a cut off value from
+// a clean end
+func eof(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
+
+// readCount reads the element count of an aggregate, -1 for a null
+func (rd *Reader) readCount() (int, error) {
+	n, err := rd.readLength("multibulk", int64(rd.limits.MaxMultiBulkLen))
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 {
+		// No reader limit, still too many elements to be real
+		return 0, protocolError("invalid multibulk length")
+	}
+	return int(n), nil
+}
 
-	count, err := strconv.Atoi(line)
+func (rd *Reader) readArray() (Value, error) {
+	count, err := rd.readCount()
 	if err != nil {
 		return Value{}, err
 	}
 
-	arr := make([]Value, 0, count)
+	if count < 0 {
+		return Value{Type: Array, Null: true}, nil
+	}
+
+	arr := make([]Value, 0, min(count, preallocElems))
 	for i := 0; i < count; i++ {
-		v, err := rd.ReadValue()
+		v, err := rd.readValue()
 		if err != nil {
 			return Value{}, err
 		}
@@ -139,3 +309,117 @@ func (rd *Reader) readError() (Value, error) {
 		Str:  line,
 	}, nil
 }
+
+func (rd *Reader) readNull() (Value, error) {
+	line, err := rd.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if line != "" {
+		return Value{}, protocolError("invalid null %q", line)
+	}
+	return Value{Type: Null}, nil
+}
+
+func (rd *Reader) readDouble() (Value, error) {
+	line, err := rd.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	// ParseFloat also takes the inf, -inf and nan RESP3 uses
+	f, err := strconv.ParseFloat(line, 64)
+	if err != nil {
+		return Value{}, protocolError("invalid double %q", line)
+	}
+	return Value{Type: Double, Float: f}, nil
+}
+
+func (rd *Reader) readBoolean() (Value, error) {
+	line, err := rd.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch line {
+	case "t":
+		return Value{Type: Boolean, Bool: true}, nil
+	case "f":
+		return Value{Type: Boolean, Bool: false}, nil
+	}
+	return Value{}, protocolError("invalid boolean %q", line)
+}
+
+func (rd *Reader) readBigNumber() (Value, error) {
+	line, err := rd.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(line, "-"), "+")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Value{}, protocolError("invalid big number %q", line)
+	}
+	return Value{Type: BigNumber, Str: line}, nil
+}
+
+func (rd *Reader) readVerbatim() (Value, error) {
+	size, err := rd.readLength("verbatim string", rd.limits.MaxBulkLen)
+	if err != nil {
+		return Value{}, err
+	}
+	if size < 4 {
+		return Value{}, protocolError("invalid verbatim string length")
+	}
+
+	s, err := rd.readBlob(size)
+	if err != nil {
+		return Value{}, err
+	}
+	if s[3] != ':' {
+		return Value{}, protocolError("verbatim string without a format")
+	}
+	return Value{Type: Verbatim, Format: s[:3], Str: s[4:]}, nil
+}
+
+// readAggregate reads a map, set or push. Maps hold their keys and values
+// alternating in Array.
+func (rd *Reader) readAggregate(t ValueType) (Value, error) {
+	count, err := rd.readCount()
+	if err != nil {
+		return Value{}, err
+	}
+	if count < 0 {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+	if t == Map {
+		count *= 2
+	}
+
+	arr := make([]Value, 0, min(count, preallocElems))
+	for i := 0; i < count; i++ {
+		v, err := rd.readValue()
+		if err != nil {
+			return Value{}, err
+		}
+		arr = append(arr, v)
+	}
+
+	return Value{Type: t, Array: arr}, nil
+}
+
+// readAttribute reads attributes and the value they describe, which gets
+// them in Attrs
+func (rd *Reader) readAttribute() (Value, error) {
+	attrs, err := rd.readAggregate(Map)
+	if err != nil {
+		return Value{}, err
+	}
+
+	v, err := rd.readValue()
+	if err != nil {
+		return Value{}, err
+	}
+	v.Attrs = attrs.Array
+	return v, nil
+}
@@ -0,0 +1,198 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Limits small enough for the fuzzer to run into. As with the defaults, an
+// inline line is shorter than the longest bulk string, so no argument can
+// exceed MaxBulkLen.
+var fuzzLimits = Limits{MaxBulkLen: 64, MaxMultiBulkLen: 16, MaxInlineLen: 32}
+
+var valueSeeds = []string{
+	"+OK\r\n",
+	"-ERR unknown command\r\n",
+	":-42\r\n",
+	"$5\r\nhello\r\n",
+	"$0\r\n\r\n",
+	"$-1\r\n",
+	"*-1\r\n",
+	"*2\r\n$3\r\nGET\r\n$1\r\nk\r\n",
+	"*1\r\n*1\r\n:1\r\n",
+	"_\r\n",
+	",3.14\r\n",
+	",-inf\r\n",
+	"#t\r\n",
+	"(3492890328409238509324850943850943825024385\r\n",
+	"=9\r\ntxt:hello\r\n",
+	"%1\r\n+key\r\n:1\r\n",
+	"~2\r\n+a\r\n+b\r\n",
+	">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n",
+	"|1\r\n+ttl\r\n:3600\r\n$1\r\nv\r\n",
+	// Broken input
+	"$-2\r\n",
+	"$abc\r\n",
+	"$5\r\nhelloXX",
+	"$5\r\nhel",
+	"*99999999999\r\n",
+	"+OK\n",
+	"=2\r\nab\r\n",
+	"!\r\n",
+}
+
+// checkErr fails unless err is one of the errors the reader is meant to
+// return
+func checkErr(t *testing.T, err error) {
+	var protoErr *ProtocolError
+	if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &protoErr) {
+		t.Fatalf("unexpected error %T: %v", err, err)
+	}
+}
+
+func encode(t *testing.T, v Value) []byte {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	wr.SetProtocol(3)
+	if err := wr.WriteValue(v); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// FuzzReadValue checks the reader never panics on arbitrary input, and that
+// what it reads survives being written and read again unchanged
+func FuzzReadValue(f *testing.F) {
+	for _, seed := range valueSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rd := NewReader(bytes.NewReader(data))
+		rd.SetLimits(fuzzLimits)
+		for {
+			v, err := rd.ReadValue()
+			if err != nil {
+				checkErr(t, err)
+				return
+			}
+			if rd.Offset() > int64(len(data)) {
+				t.Fatalf("offset %d past the %d bytes of input", rd.Offset(), len(data))
+			}
+
+			first := encode(t, v)
+			again, err := NewReader(bytes.NewReader(first)).ReadValue()
+			if err != nil {
+				t.Fatalf("reading back %q: %v", first, err)
+			}
+			if second := encode(t, again); !bytes.Equal(first, second) {
+				t.Fatalf("round trip changed the value: %q became %q", first, second)
+			}
+		}
+	})
+}
+
+// FuzzReadCommand checks every command the reader accepts is a non empty
+// array of bulk strings within the limits
+func FuzzReadCommand(f *testing.F) {
+	for _, seed := range valueSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Add([]byte("SET key value\r\nGET key\n\r\n"))
+	f.Add([]byte(`SET "a b" 'c\'d' "\x41\n"` + "\r\n"))
+	f.Add([]byte("SET \"unbalanced\r\n"))
+	f.Add([]byte("*1\r\n:1\r\n"))
+	f.Add([]byte("*0\r\n*1\r\n$4\r\nPING\r\n"))
+	f.Add([]byte("*1\r\n$65\r\n" + strings.Repeat("x", 65) + "\r\n"))
+	f.Add([]byte("*17\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rd := NewReader(bytes.NewReader(data))
+		rd.SetLimits(fuzzLimits)
+		for {
+			v, err := rd.ReadCommand()
+			if err != nil {
+				checkErr(t, err)
+				return
+			}
+			if v.Type != Array || len(v.Array) == 0 {
+				t.Fatalf("command is not a non empty array: %+v", v)
+			}
+			for _, arg := range v.Array {
+				if arg.Type != BulkString || arg.Null {
+					t.Fatalf("argument is not a bulk string: %+v", arg)
+				}
+				if int64(len(arg.Str)) > fuzzLimits.MaxBulkLen {
+					t.Fatalf("argument of %d bytes got past the limits", len(arg.Str))
+				}
+			}
+		}
+	})
+}
+
+// quoteArg quotes s so SplitArgs gives it back as one argument
+func quoteArg(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FuzzSplitArgs checks SplitArgs never panics, and that the arguments it
+// returns split the same once quoted again
+func FuzzSplitArgs(f *testing.F) {
+	for _, seed := range []string{
+		"SET key value",
+		"  GET\t key  ",
+		`SET "hello world" 'it\'s'`,
+		`SET k "\x00\xff\n\r\t\b\a\\\""`,
+		`SET "a"b`,
+		`SET 'open`,
+		`"\x4"`,
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		args, err := SplitArgs(line)
+		if err != nil {
+			if err != ErrUnbalancedQuotes {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = quoteArg(arg)
+		}
+		again, err := SplitArgs(strings.Join(quoted, " "))
+		if err != nil {
+			t.Fatalf("splitting %q: %v", quoted, err)
+		}
+		if len(again) != len(args) {
+			t.Fatalf("got %d arguments back, want %d", len(again), len(args))
+		}
+		for i := range args {
+			if again[i] != args[i] {
+				t.Fatalf("argument %d became %q, want %q", i, again[i], args[i])
+			}
+		}
+	})
+}
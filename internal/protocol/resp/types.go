@@ -8,11 +8,34 @@ const (
 	Integer      ValueType = ':'
 	BulkString   ValueType = '$'
 	Array        ValueType = '*'
+
+	// RESP3 types, written as their closest RESP2 equivalent to clients
+	// that didn't switch with HELLO 3
+	Null      ValueType = '_'
+	Double    ValueType = ','
+	Boolean   ValueType = '#'
+	BigNumber ValueType = '(' // decimal digits in Str
+	Verbatim  ValueType = '=' // text in Str, its format in Format
+	Map       ValueType = '%' // keys and values alternate in Array
+	Set       ValueType = '~'
+	Attribute ValueType = '|' // only the prefix, attributes travel in Value.Attrs
+	Push      ValueType = '>' // out of band data like pub/sub messages
 )
 
 type Value struct {
-	Type  ValueType
-	Str   string
-	Int   int64
-	Array []Value
+	Type   ValueType
+	Str    string
+	Int    int64
+	Float  float64 // Double
+	Bool   bool    // Boolean
+	Format string  // Verbatim format, three characters like "txt"
+	Array  []Value
+
+	// Null marks a null bulk string ($-1) or array (*-1), e.g. a missing key
+	// or EXEC aborted by WATCH. An empty Str without it is an empty string.
+	Null bool
+
+	// Attrs are RESP3 attributes sent ahead of the value, keys and values
+	// alternating. They are dropped for RESP2.
+	Attrs []Value
 }
@@ -4,51 +4,169 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"strings"
 )
 
 type Writer struct {
-	w *bufio.Writer
+	w     *bufio.Writer
+	proto int // RESP version, 2 or 3
 }
 
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
-		w: bufio.NewWriter(w),
+		w:     bufio.NewWriter(w),
+		proto: 2,
 	}
 }
 
+// SetProtocol switches the writer to RESP2 or RESP3. RESP2 writes RESP3
+// types as their closest RESP2 equivalent.
+func (wr *Writer) SetProtocol(proto int) {
+	wr.proto = proto
+}
+
+// Protocol returns the RESP version in use
+func (wr *Writer) Protocol() int {
+	return wr.proto
+}
+
+// WriteValue writes v and flushes it to the connection
 func (wr *Writer) WriteValue(v Value) error {
-	switch v.Type {
-	case SimpleString:
-		if _, err := fmt.Fprintf(wr.w, "+%s\r\n", v.Str); err != nil {
+	if err := wr.write(v); err != nil {
+		return err
+	}
+	return wr.w.Flush()
+}
+
+// Buffer writes v without flushing, so replies to pipelined commands can go
+// out together with a single Flush
+func (wr *Writer) Buffer(v Value) error {
+	return wr.write(v)
+}
+
+// Flush sends the buffered values to the connection
+func (wr *Writer) Flush() error {
+	return wr.w.Flush()
+}
+
+func (wr *Writer) write(v Value) error {
+	resp3 := wr.proto >= 3
+	if len(v.Attrs) > 0 && resp3 {
+		if err := wr.aggregate(Attribute, len(v.Attrs)/2, v.Attrs); err != nil {
 			return err
 		}
-		return wr.w.Flush()
+	}
+
+	var err error
+	switch v.Type {
+	case SimpleString:
+		_, err = fmt.Fprintf(wr.w, "+%s\r\n", oneLine(v.Str))
+	case Error:
+		_, err = fmt.Fprintf(wr.w, "-%s\r\n", oneLine(v.Str))
 	case Integer:
-		if _, err := fmt.Fprintf(wr.w, ":%d\r\n", v.Int); err != nil {
-			return err
-		}
-		return wr.w.Flush()
+		_, err = fmt.Fprintf(wr.w, ":%d\r\n", v.Int)
 	case BulkString:
-		if v.Str == "" {
-			if _, err := wr.w.WriteString("$-1\r\n"); err != nil {
-				return err
-			}
-		} else {
-			if _, err := fmt.Fprintf(wr.w, "$%d\r\n%s\r\n", len(v.Str), v.Str); err != nil {
-				return err
-			}
-		}
-		return wr.w.Flush()
+		if v.Null {
+			return wr.null("$-1\r\n")
+		}
+		err = wr.bulk(v.Str)
 	case Array:
-		_, err := fmt.Fprintf(wr.w, "*%d\r\n", len(v.Array))
-		if err != nil {
-			return err
+		if v.Null {
+			return wr.null("*-1\r\n")
 		}
-		for _, el := range v.Array {
-			if err = wr.WriteValue(el); err != nil {
-				return err
-			}
+		err = wr.aggregate(Array, len(v.Array), v.Array)
+
+	case Null:
+		return wr.null("$-1\r\n")
+	case Double:
+		if !resp3 {
+			return wr.bulk(formatDouble(v.Float))
 		}
+		_, err = fmt.Fprintf(wr.w, ",%s\r\n", formatDouble(v.Float))
+	case Boolean:
+		switch {
+		case !resp3 && v.Bool:
+			_, err = wr.w.WriteString(":1\r\n")
+		case !resp3:
+			_, err = wr.w.WriteString(":0\r\n")
+		case v.Bool:
+			_, err = wr.w.WriteString("#t\r\n")
+		default:
+			_, err = wr.w.WriteString("#f\r\n")
+		}
+	case BigNumber:
+		if !resp3 {
+			return wr.bulk(v.Str)
+		}
+		_, err = fmt.Fprintf(wr.w, "(%s\r\n", v.Str)
+	case Verbatim:
+		if !resp3 {
+			return wr.bulk(v.Str)
+		}
+		_, err = fmt.Fprintf(wr.w, "=%d\r\n%s:%s\r\n", len(v.Format)+1+len(v.Str), v.Format, v.Str)
+	case Map:
+		if !resp3 {
+			return wr.aggregate(Array, len(v.Array), v.Array)
+		}
+		err = wr.aggregate(Map, len(v.Array)/2, v.Array)
+	case Set, Push:
+		if !resp3 {
+			return wr.aggregate(Array, len(v.Array), v.Array)
+		}
+		err = wr.aggregate(v.Type, len(v.Array), v.Array)
 	}
-	return wr.w.Flush()
+	return err
+}
+
+// oneLine replaces CR and LF with spaces, like Redis does for simple
+// strings and errors. They often echo client input, which could otherwise
+// end the line early and smuggle in a reply of its own.
+func oneLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+func (wr *Writer) bulk(s string) error {
+	_, err := fmt.Fprintf(wr.w, "$%d\r\n%s\r\n", len(s), s)
+	return err
+}
+
+// null writes RESP3's null, or the given RESP2 null
+func (wr *Writer) null(resp2 string) error {
+	if wr.proto >= 3 {
+		resp2 = "_\r\n"
+	}
+	_, err := wr.w.WriteString(resp2)
+	return err
+}
+
+// aggregate writes a header with count, then the elements
+func (wr *Writer) aggregate(t ValueType, count int, elems []Value) error {
+	if _, err := fmt.Fprintf(wr.w, "%c%d\r\n", t, count); err != nil {
+		return err
+	}
+	for _, el := range elems {
+		if err := wr.write(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDouble formats a double the way RESP3 spells it, also used for the
+// bulk string RESP2 gets
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
 }
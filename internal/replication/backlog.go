@@ -0,0 +1,68 @@
+package replication
+
+// Backlog keeps the most recent bytes of the replication stream in a ring
+// buffer, so a replica that reconnects after a short break can continue
+// from where it stopped instead of doing a full sync. Not safe for
+// concurrent use, the Master guards it.
+type Backlog struct {
+	buf  []byte
+	pos  int   // where the next byte goes
+	size int   // bytes held, at most len(buf)
+	end  int64 // stream offset after the newest byte
+}
+
+func NewBacklog(capacity int) *Backlog {
+	return &Backlog{buf: make([]byte, capacity)}
+}
+
+// Write adds stream bytes, overwriting the oldest once the buffer is full
+func (b *Backlog) Write(p []byte) {
+	b.end += int64(len(p))
+
+	// Only the tail of a write larger than the buffer can be kept
+	if len(p) > len(b.buf) {
+		p = p[len(p)-len(b.buf):]
+	}
+
+	n := copy(b.buf[b.pos:], p)
+	copy(b.buf, p[n:])
+	b.pos = (b.pos + len(p)) % len(b.buf)
+	b.size = min(b.size+len(p), len(b.buf))
+}
+
+// Reset empties the backlog, the next byte written is at offset
+func (b *Backlog) Reset(offset int64) {
+	b.pos = 0
+	b.size = 0
+	b.end = offset
+}
+
+// Start returns the stream offset of the oldest byte held
+func (b *Backlog) Start() int64 {
+	return b.end - int64(b.size)
+}
+
+// Len returns the number of bytes held
+func (b *Backlog) Len() int {
+	return b.size
+}
+
+// Cap returns the backlog size
+func (b *Backlog) Cap() int {
+	return len(b.buf)
+}
+
+// ReadFrom returns a copy of the stream from offset on, false when the
+// backlog doesn't reach back that far or offset is in the future
+func (b *Backlog) ReadFrom(offset int64) ([]byte, bool) {
+	if offset < b.Start() || offset > b.end {
+		return nil, false
+	}
+
+	n := int(b.end - offset)
+	out := make([]byte, n)
+	start := (b.pos - n + len(b.buf)) % len(b.buf)
+	copied := copy(out, b.buf[start:min(start+n, len(b.buf))])
+	copy(out[copied:], b.buf[:n-copied])
+	return out, true
+}
@@ -0,0 +1,377 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/persistence"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+
+	// A master that sends nothing for this long, not even its periodic
+	// PING, is considered gone, like Redis' repl-timeout
+	readTimeout = 60 * time.Second
+
+	// How often the replica reports its offset with REPLCONF ACK
+	ackInterval = time.Second
+
+	// Wait between attempts to reach the master
+	retryDelay = time.Second
+)
+
+var errStopped = errors.New("replication stopped")
+
+// Hooks connect a Link to the server it runs in
+type Hooks struct {
+	// BeginSync is called before a full sync loads the master's dataset,
+	// Restore for every key of it and EndSync once it is done, also when
+	// the transfer failed half way
+	BeginSync func()
+	Restore   persistence.RestoreFunc
+	EndSync   func()
+
+	// Apply runs a command of the stream, ApplyTxn the commands of a
+	// MULTI ... EXEC block as one transaction. Both call relay once the
+	// commands ran, before anything taking the exclusive lock, like the
+	// PSYNC of a replica of this server, can run in between.
+	Apply    func(v resp.Value, relay func())
+	ApplyTxn func(vs []resp.Value, relay func())
+}
+
+// Link is the replica side of replication: it connects to the master,
+// syncs and applies the master's write stream until stopped. A broken
+// connection is retried, with a partial resync when the master's backlog
+// still covers the gap.
+type Link struct {
+	addr  string
+	port  int // announced with REPLCONF listening-port
+	m     *Master
+	hooks Hooks
+
+	mu      sync.Mutex
+	conn    net.Conn
+	up      bool
+	syncing bool
+	lastIO  time.Time
+	stopped bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewLink(addr string, port int, m *Master, hooks Hooks) *Link {
+	return &Link{
+		addr:  addr,
+		port:  port,
+		m:     m,
+		hooks: hooks,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Addr returns the master's address
+func (l *Link) Addr() string {
+	return l.addr
+}
+
+// Run follows the master in the background until Stop
+func (l *Link) Run() {
+	go func() {
+		defer close(l.done)
+
+		for {
+			err := l.sync()
+
+			select {
+			case <-l.stop:
+				return
+			default:
+			}
+			log.Printf("Replication with master %s: %v, reconnecting", l.addr, err)
+
+			select {
+			case <-l.stop:
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+	}()
+}
+
+// Stop disconnects from the master and waits until nothing is applied
+// anymore
+func (l *Link) Stop() {
+	l.mu.Lock()
+	if !l.stopped {
+		l.stopped = true
+		close(l.stop)
+		if l.conn != nil {
+			l.conn.Close()
+		}
+	}
+	l.mu.Unlock()
+
+	<-l.done
+}
+
+// sync runs one connection to the master: handshake, full or partial
+// resync, then the stream until an error
+func (l *Link) sync() error {
+	conn, err := net.DialTimeout("tcp", l.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return errStopped
+	}
+	l.conn = conn
+	l.mu.Unlock()
+
+	br := bufio.NewReader(&timeoutReader{conn: conn, link: l})
+
+	if _, err := l.command(conn, br, "PING"); err != nil {
+		return err
+	}
+	if _, err := l.command(conn, br, "REPLCONF", "listening-port", strconv.Itoa(l.port)); err != nil {
+		return err
+	}
+
+	replid, offset := l.m.ReplID()
+	reply, err := l.command(conn, br, "PSYNC", replid, strconv.FormatInt(offset+1, 10))
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(reply)
+	switch {
+	case len(fields) == 3 && fields[0] == "FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid PSYNC reply %q", reply)
+		}
+		if err := l.fullSync(br, fields[1], offset); err != nil {
+			return err
+		}
+	case len(fields) >= 1 && fields[0] == "CONTINUE":
+		// A promoted master continues under its new ID
+		if len(fields) == 2 && fields[1] != replid {
+			l.m.continueAs(fields[1])
+		}
+		log.Printf("Partial resync with master %s from offset %d", l.addr, offset)
+	default:
+		return fmt.Errorf("unexpected PSYNC reply %q", reply)
+	}
+
+	l.setUp(true)
+	defer l.setUp(false)
+
+	stopAcks := make(chan struct{})
+	defer close(stopAcks)
+	go l.sendAcks(conn, stopAcks)
+
+	return l.stream(br)
+}
+
+// command sends a handshake command and returns its status reply
+func (l *Link) command(conn net.Conn, br *bufio.Reader, args ...string) (string, error) {
+	if _, err := conn.Write(persistence.EncodeCommand(args[0], args[1:])); err != nil {
+		return "", err
+	}
+
+	line, err := readLine(br)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "-") {
+		return "", fmt.Errorf("%s: %s", args[0], line[1:])
+	}
+	return strings.TrimPrefix(line, "+"), nil
+}
+
+// fullSync loads the snapshot that follows +FULLRESYNC, sent like a bulk
+// string without the trailing CRLF
+func (l *Link) fullSync(br *bufio.Reader, replid string, offset int64) error {
+	line, err := readLine(br)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(strings.TrimPrefix(line, "$"), 10, 64)
+	if !strings.HasPrefix(line, "$") || err != nil || size < 0 {
+		return fmt.Errorf("invalid snapshot header %q", line)
+	}
+
+	start := time.Now()
+	log.Printf("Full resync with master %s, loading %d bytes", l.addr, size)
+
+	l.setSyncing(true)
+	defer l.setSyncing(false)
+	l.hooks.BeginSync()
+	defer l.hooks.EndSync()
+
+	body := io.LimitReader(br, size)
+	_, err = persistence.ReadSnapshot(body, l.hooks.Restore)
+	if err == nil {
+		_, err = io.Copy(io.Discard, body)
+	}
+	if err != nil {
+		// Part of the dataset is gone, a partial resync must not build on it
+		l.m.reset(newReplID(), 0)
+		return fmt.Errorf("loading snapshot from master: %w", err)
+	}
+
+	l.m.reset(replid, offset)
+	log.Printf("Loaded master snapshot in %.3f seconds", time.Since(start).Seconds())
+	return nil
+}
+
+// stream applies the master's write stream and relays it to this server's
+// own replicas. A MULTI ... EXEC block is applied and counted only once it
+// is complete, so a disconnect in the middle resumes before it.
+func (l *Link) stream(br *bufio.Reader) error {
+	rd := resp.NewReader(br)
+
+	var txn []resp.Value
+	var txnData []byte
+	inTxn := false
+
+	for {
+		v, err := rd.ReadValue()
+		if err != nil {
+			return err
+		}
+		data := encode(v)
+		relay := func() { l.m.Relay(data) }
+
+		switch name := commandName(v); {
+		case name == "MULTI":
+			inTxn = true
+			txn = nil
+			txnData = data
+			continue
+		case name == "EXEC" && inTxn:
+			data = append(txnData, data...)
+			l.hooks.ApplyTxn(txn, relay)
+			inTxn = false
+			txn, txnData = nil, nil
+		case inTxn:
+			txn = append(txn, v)
+			txnData = append(txnData, data...)
+			continue
+		case name == "PING":
+			// Keeps the link alive, nothing to apply
+			relay()
+		default:
+			l.hooks.Apply(v, relay)
+		}
+	}
+}
+
+// sendAcks reports the processed offset to the master until stop is closed
+func (l *Link) sendAcks(conn net.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(ackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, offset := l.m.ReplID()
+			ack := persistence.EncodeCommand("REPLCONF", []string{"ACK", strconv.FormatInt(offset, 10)})
+			if _, err := conn.Write(ack); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (l *Link) setUp(up bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.up = up
+}
+
+func (l *Link) setSyncing(syncing bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.syncing = syncing
+}
+
+func (l *Link) touch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastIO = time.Now()
+}
+
+// LinkStats is a point-in-time view of the link to the master, reported by
+// INFO
+type LinkStats struct {
+	Addr    string
+	Up      bool
+	Syncing bool
+	LastIO  time.Time // zero before anything was read
+}
+
+// Stats returns the current state of the link
+func (l *Link) Stats() LinkStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LinkStats{Addr: l.addr, Up: l.up, Syncing: l.syncing, LastIO: l.lastIO}
+}
+
+// timeoutReader gives every read from the master a deadline and records
+// when data last arrived
+type timeoutReader struct {
+	conn net.Conn
+	link *Link
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	n, err := r.conn.Read(p)
+	if n > 0 {
+		r.link.touch()
+	}
+	return n, err
+}
+
+// readLine reads a CRLF terminated line without the CRLF
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// encode returns the wire form of a value, the bytes the master sent for it
+func encode(v resp.Value) []byte {
+	var buf bytes.Buffer
+	resp.NewWriter(&buf).WriteValue(v)
+	return buf.Bytes()
+}
+
+func commandName(v resp.Value) string {
+	if v.Type != resp.Array || len(v.Array) == 0 {
+		return ""
+	}
+	return strings.ToUpper(v.Array[0].Str)
+}
@@ -0,0 +1,271 @@
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/persistence"
+)
+
+// How often the master pings its replicas when no writes flow, so they can
+// tell a quiet master from a dead link
+const pingInterval = 10 * time.Second
+
+// Master is the replication state every server has: its replication ID,
+// the offset of its write stream, the backlog and the replicas attached to
+// it. A server that follows a master itself relays that master's stream
+// instead of its own writes, see Relay.
+type Master struct {
+	mu       sync.Mutex
+	replid   string
+	offset   int64 // bytes of write stream so far, master_repl_offset
+	backlog  *Backlog
+	replicas map[*Replica]struct{}
+	nextID   int64     // orders replicas in INFO
+	lastSend time.Time // for Heartbeat
+
+	// The ID and offset before the last promotion, so replicas of the old
+	// master can continue with a partial resync
+	replid2 string
+	offset2 int64
+
+	following bool // this server is a replica, see Follow
+	closed    bool // shutting down, no more replicas
+}
+
+func NewMaster(backlogSize int) *Master {
+	return &Master{
+		replid:   newReplID(),
+		offset2:  -1,
+		backlog:  NewBacklog(max(backlogSize, 1)),
+		replicas: make(map[*Replica]struct{}),
+		lastSend: time.Now(),
+	}
+}
+
+// newReplID returns a random 40 character replication ID
+func newReplID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Feed adds a write of this server to the stream. Ignored while following
+// a master, whose stream is relayed instead.
+func (m *Master) Feed(data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.following {
+		m.appendLocked(data)
+	}
+}
+
+// Relay adds bytes of the followed master's stream, unchanged, so this
+// server's offset and backlog match the master's and replicas attached to
+// it get the same stream
+func (m *Master) Relay(data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appendLocked(data)
+}
+
+func (m *Master) appendLocked(data []byte) {
+	m.backlog.Write(data)
+	m.offset += int64(len(data))
+	m.lastSend = time.Now()
+	for r := range m.replicas {
+		r.send(data)
+	}
+}
+
+// Heartbeat pings the replicas when nothing was sent for a while. The PING
+// goes through the stream like any write, so every replica sees it at the
+// same offset. Called periodically by the server.
+func (m *Master) Heartbeat() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.following || len(m.replicas) == 0 || time.Since(m.lastSend) < pingInterval {
+		return
+	}
+	m.appendLocked(persistence.EncodeCommand("PING", nil))
+}
+
+// Attach registers a replica asking for the stream of replid from offset,
+// the next byte it needs. When the backlog still holds that part it
+// continues from there, otherwise the replica gets a full sync and
+// snapshot is called for the dataset. The caller must make sure no write
+// runs meanwhile, so the snapshot matches the offset.
+func (m *Master) Attach(replid string, offset int64, addr string, port int, snapshot func() map[string]*store.Entry) *Replica {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	r := newReplica(m, m.nextID, addr, port)
+	if data, ok := m.continueLocked(replid, offset); ok {
+		r.header = "+CONTINUE " + m.replid + "\r\n"
+		r.buf = data
+		r.state = "online"
+	} else {
+		r.header = "+FULLRESYNC " + m.replid + " " + strconv.FormatInt(m.offset, 10) + "\r\n"
+		r.snapshot = snapshot()
+		r.state = "send_bulk"
+	}
+
+	if m.closed {
+		r.Close()
+		return r
+	}
+	m.replicas[r] = struct{}{}
+	return r
+}
+
+// continueLocked returns the stream a partial resync from offset needs
+func (m *Master) continueLocked(replid string, offset int64) ([]byte, bool) {
+	from := offset - 1 // PSYNC asks for the next byte, 1 based
+	switch {
+	case replid == m.replid:
+	case replid == m.replid2 && from <= m.offset2:
+	default:
+		return nil, false
+	}
+	return m.backlog.ReadFrom(from)
+}
+
+// detach forgets a replica, called once its connection is gone
+func (m *Master) detach(r *Replica) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.replicas, r)
+}
+
+// Follow marks this server as a replica: its own writes stop feeding the
+// stream, which now comes from the master through Relay
+func (m *Master) Follow() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.following = true
+}
+
+// Promote turns a replica back into a master. It takes a new replication
+// ID but keeps the old one, so its own replicas can continue with a
+// partial resync. They are disconnected to learn the new ID that way.
+func (m *Master) Promote() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.following {
+		return
+	}
+	m.following = false
+	m.replid2 = m.replid
+	m.offset2 = m.offset
+	m.replid = newReplID()
+	for r := range m.replicas {
+		r.Close()
+	}
+}
+
+// Following reports whether this server is a replica
+func (m *Master) Following() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.following
+}
+
+// ReplID returns the replication ID and the offset of the stream
+func (m *Master) ReplID() (string, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.replid, m.offset
+}
+
+// continueAs switches to the ID a promoted master continues the stream
+// under. The old ID stays valid for this server's own replicas.
+func (m *Master) continueAs(replid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replid2 = m.replid
+	m.offset2 = m.offset
+	m.replid = replid
+}
+
+// reset adopts a master's ID and offset after a full sync. Attached
+// replicas were following a different history and must sync again.
+func (m *Master) reset(replid string, offset int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replid = replid
+	m.offset = offset
+	m.replid2 = ""
+	m.offset2 = -1
+	m.backlog.Reset(offset)
+	for r := range m.replicas {
+		r.Close()
+	}
+}
+
+// Close disconnects all replicas and refuses new ones, on shutdown
+func (m *Master) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	for r := range m.replicas {
+		r.Close()
+	}
+}
+
+// ReplicaInfo describes an attached replica for INFO
+type ReplicaInfo struct {
+	Addr   string
+	Port   int
+	State  string
+	Offset int64 // last offset the replica acknowledged
+	Lag    time.Duration
+}
+
+// Stats is a point-in-time view of the replication state, reported by INFO
+type Stats struct {
+	ReplID        string
+	ReplID2       string
+	Offset        int64
+	Offset2       int64 // -1 without a second ID
+	BacklogSize   int
+	BacklogStart  int64
+	BacklogLength int
+	Replicas      []ReplicaInfo
+}
+
+// Stats returns the current replication state
+func (m *Master) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := Stats{
+		ReplID:        m.replid,
+		ReplID2:       m.replid2,
+		Offset:        m.offset,
+		Offset2:       m.offset2,
+		BacklogSize:   m.backlog.Cap(),
+		BacklogStart:  m.backlog.Start(),
+		BacklogLength: m.backlog.Len(),
+	}
+	replicas := make([]*Replica, 0, len(m.replicas))
+	for r := range m.replicas {
+		replicas = append(replicas, r)
+	}
+	slices.SortFunc(replicas, func(a, b *Replica) int { return int(a.id - b.id) })
+	for _, r := range replicas {
+		st.Replicas = append(st.Replicas, r.info())
+	}
+	return st
+}
@@ -0,0 +1,159 @@
+package replication
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/engine/store"
+	"github.com/Eahtasham/go-redis/internal/persistence"
+)
+
+// A replica whose unsent stream grows beyond this is disconnected rather
+// than letting it use unbounded memory, like Redis' client-output-buffer-limit
+// for replicas. It reconnects and syncs again.
+const outputLimit = 256 << 20
+
+var errOutputLimit = errors.New("replica output buffer limit reached")
+
+// Replica is a replica attached to this server, on the master side of the
+// link. It is created by PSYNC, after which the connection only carries
+// the stream to the replica and its REPLCONF ACKs back.
+type Replica struct {
+	m    *Master
+	id   int64
+	addr string
+	port int
+
+	// What Serve sends before the stream: the PSYNC reply and, for a full
+	// sync, the dataset as of the stream offset in that reply
+	header   string
+	snapshot map[string]*store.Entry
+
+	mu      sync.Mutex
+	buf     []byte // stream not sent yet
+	state   string // send_bulk while the snapshot goes out, then online
+	ack     int64
+	ackTime time.Time
+	err     error // why the replica was closed
+
+	wake      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newReplica(m *Master, id int64, addr string, port int) *Replica {
+	return &Replica{
+		m:       m,
+		id:      id,
+		addr:    addr,
+		port:    port,
+		ackTime: time.Now(),
+		wake:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+// send queues stream bytes, called by the master with its lock held
+func (r *Replica) send(data []byte) {
+	r.mu.Lock()
+	if len(r.buf)+len(data) > outputLimit {
+		r.err = errOutputLimit
+		r.mu.Unlock()
+		r.Close()
+		return
+	}
+	r.buf = append(r.buf, data...)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Serve writes the PSYNC reply, the snapshot for a full sync and then the
+// stream to w until the replica is closed or stop is closed. The replica
+// is detached from the master when it returns.
+func (r *Replica) Serve(w io.Writer, stop <-chan struct{}) error {
+	defer r.m.detach(r)
+
+	if _, err := io.WriteString(w, r.header); err != nil {
+		return err
+	}
+
+	// Sent like a bulk string without the trailing CRLF, as Redis does
+	if r.snapshot != nil {
+		var b bytes.Buffer
+		if err := persistence.WriteSnapshot(&b, persistence.SnapshotInfo{Created: time.Now()}, r.snapshot); err != nil {
+			return err
+		}
+		r.snapshot = nil
+
+		if _, err := fmt.Fprintf(w, "$%d\r\n", b.Len()); err != nil {
+			return err
+		}
+		if _, err := w.Write(b.Bytes()); err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		r.state = "online"
+		r.mu.Unlock()
+	}
+
+	for {
+		r.mu.Lock()
+		data := r.buf
+		r.buf = nil
+		r.mu.Unlock()
+
+		if len(data) > 0 {
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-r.wake:
+		case <-r.closed:
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return r.err
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// Ack records the offset the replica reported with REPLCONF ACK
+func (r *Replica) Ack(offset int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ack = offset
+	r.ackTime = time.Now()
+}
+
+// Close stops Serve, called when the connection goes away or the replica
+// has to sync again
+func (r *Replica) Close() {
+	r.closeOnce.Do(func() { close(r.closed) })
+}
+
+func (r *Replica) info() ReplicaInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return ReplicaInfo{
+		Addr:   r.addr,
+		Port:   r.port,
+		State:  r.state,
+		Offset: r.ack,
+		Lag:    time.Since(r.ackTime),
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Eahtasham/go-redis/internal/persistence"
+	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+)
+
+// Config holds the server settings, see DefaultConfig for the defaults
+type Config struct {
+	Addr string
+
+	// The AOF lives in AOFDir as a base file and incremental files named
+	// after AOFFilename, plus a manifest listing them, like Redis'
+	// appenddirname / appendfilename
+	AOFDir      string
+	AOFFilename string
+
+	// Snapshot file, loaded at startup before the AOF
+	DBFilename string
+
+	// Take a background snapshot when a rule is due, like Redis' save.
+	// Empty disables scheduled snapshots and the save on shutdown.
+	SaveRules []persistence.SaveRule
+
+	// When the AOF is fsynced: always, everysec or no
+	AppendFsync persistence.FsyncPolicy
+
+	// What a write does when the AOF queue is full: block, grow or refuse
+	AOFBackpressure persistence.BackpressurePolicy
+
+	// Start rewritten AOFs with a binary snapshot, followed by the commands
+	// appended since, like Redis' aof-use-rdb-preamble
+	AOFUseRDBPreamble bool
+
+	// Load an AOF that ends in the middle of a record and cut the torn
+	// tail, instead of refusing to start, like Redis' aof-load-truncated
+	AOFLoadTruncated bool
+
+	// Rewrite the AOF automatically once it grew by this percentage since
+	// the last rewrite and is at least AutoAOFRewriteMinSize bytes, like
+	// Redis' auto-aof-rewrite-percentage / auto-aof-rewrite-min-size.
+	// A percentage of 0 disables automatic rewrites.
+	AutoAOFRewritePercentage int
+	AutoAOFRewriteMinSize    int64
+
+	// Master to replicate from as host:port, empty for a master, like
+	// Redis' replicaof
+	ReplicaOf string
+
+	// Bytes of the replication stream kept for replicas that reconnect,
+	// like Redis' repl-backlog-size
+	ReplBacklogSize int
+
+	// Run as a cluster node, keeping the node's view of the cluster in
+	// ClusterConfigFile, like Redis' cluster-enabled / cluster-config-file
+	ClusterEnabled    bool
+	ClusterConfigFile string
+
+	// How long a node may not answer before it is considered failing,
+	// like Redis' cluster-node-timeout
+	ClusterNodeTimeout time.Duration
+
+	// Largest bulk string a client may send, like Redis'
+	// proto-max-bulk-len. Bigger requests are refused with a protocol error.
+	ProtoMaxBulkLen int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:                     ":6379",
+		AOFDir:                   "appendonlydir",
+		AOFFilename:              "appendonly.aof",
+		DBFilename:               "dump.rdb",
+		SaveRules:                []persistence.SaveRule{{Seconds: 3600, Changes: 1}, {Seconds: 300, Changes: 100}, {Seconds: 60, Changes: 10000}},
+		AppendFsync:              persistence.FsyncEverySec,
+		AOFBackpressure:          persistence.BackpressureBlock,
+		AOFUseRDBPreamble:        true,
+		AOFLoadTruncated:         true,
+		AutoAOFRewritePercentage: 100,
+		AutoAOFRewriteMinSize:    64 << 20,
+		ReplBacklogSize:          1 << 20,
+		ClusterConfigFile:        "nodes.conf",
+		ClusterNodeTimeout:       15 * time.Second,
+		ProtoMaxBulkLen:          resp.DefaultMaxBulkLen,
+	}
+}
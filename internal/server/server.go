@@ -2,31 +2,46 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
+	"strconv"
+	"time"
 
+	"github.com/Eahtasham/go-redis/internal/cluster"
 	"github.com/Eahtasham/go-redis/internal/commands"
 	"github.com/Eahtasham/go-redis/internal/commands/handlers"
+	"github.com/Eahtasham/go-redis/internal/engine/pubsub"
 	"github.com/Eahtasham/go-redis/internal/engine/store"
 	"github.com/Eahtasham/go-redis/internal/netlayer"
 	"github.com/Eahtasham/go-redis/internal/persistence"
 	"github.com/Eahtasham/go-redis/internal/protocol/resp"
+	"github.com/Eahtasham/go-redis/internal/replication"
 )
 
-const AOFPath = "appendonly.aof"
+// How often background housekeeping such as the auto AOF rewrite check runs
+const cronInterval = 100 * time.Millisecond
 
 type Server struct {
+	Config   Config
 	Listener *netlayer.Listener
 	Store    *store.Store
+	PubSub   *pubsub.Broker
 	AOF      *persistence.AOF
+	RDB      *persistence.RDB
+	Repl     *replication.Master
+	Cluster  *cluster.Cluster // nil unless cluster mode is enabled
+	loaded   chan struct{}    // closed once the dataset is restored
 	ctx      context.Context
 	cancel   context.CancelFunc
 }
 
-func New(addr string) *Server {
+func New(cfg Config) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	ln, err := netlayer.NewListener(addr)
+	ln, err := netlayer.NewListener(cfg.Addr)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -34,33 +49,74 @@ func New(addr string) *Server {
 	// Initialize the store
 	s := store.NewStore()
 
+	// Initialize the pub/sub broker
+	broker := pubsub.NewBroker()
+
 	// Initialize AOF persistence
-	aof, err := persistence.NewAOF(AOFPath)
+	aof, err := persistence.NewAOF(cfg.AOFDir, cfg.AOFFilename, persistence.Options{
+		Fsync:                 cfg.AppendFsync,
+		Backpressure:          cfg.AOFBackpressure,
+		Preamble:              cfg.AOFUseRDBPreamble,
+		AutoRewritePercentage: cfg.AutoAOFRewritePercentage,
+		AutoRewriteMinSize:    cfg.AutoAOFRewriteMinSize,
+	})
 	if err != nil {
 		log.Printf("Warning: Could not initialize AOF: %v", err)
 		// Continue without persistence
 	}
 
+	rdb := persistence.NewRDB(cfg.DBFilename, cfg.SaveRules)
+
+	// Initialize replication, every server can have replicas
+	repl := replication.NewMaster(cfg.ReplBacklogSize)
+
 	// Wire the store to handlers
 	handlers.InitStore(s)
+	handlers.InitPubSub(broker)
 
 	// Wire AOF to handlers (may be nil if init failed)
 	handlers.InitAOF(aof)
+	handlers.InitRDB(rdb)
+	handlers.InitReplication(repl, listenPort(cfg.Addr))
 
 	// Register all command handlers
 	handlers.RegisterAll()
 
+	// In cluster mode commands are routed by their keys' hash slots
+	var cl *cluster.Cluster
+	if cfg.ClusterEnabled {
+		if cfg.ReplicaOf != "" {
+			log.Fatal("replicaof is not supported in cluster mode")
+		}
+		cl, err = cluster.New(cluster.Config{
+			IP:          announceIP(cfg.Addr),
+			Port:        listenPort(cfg.Addr),
+			ConfigFile:  cfg.ClusterConfigFile,
+			NodeTimeout: cfg.ClusterNodeTimeout,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.InitCluster(cl)
+	}
+
 	return &Server{
+		Config:   cfg,
 		Listener: ln,
 		Store:    s,
+		PubSub:   broker,
 		AOF:      aof,
+		RDB:      rdb,
+		Repl:     repl,
+		Cluster:  cl,
+		loaded:   make(chan struct{}),
 		ctx:      ctx,
 		cancel:   cancel,
 	}
 }
 
 func (s *Server) Start() error {
-	fmt.Println("Server Starting on :6379")
+	fmt.Println("Server Starting on", s.Config.Addr)
 
 	// Start AOF background writer
 	if s.AOF != nil {
@@ -68,35 +124,211 @@ func (s *Server) Start() error {
 		fmt.Println("AOF persistence enabled")
 	}
 
-	// Replay AOF to restore state (before accepting connections)
-	count := 0
-	persistence.Replay(AOFPath, func(v resp.Value) {
-		commands.Dispatch(v)
+	if s.Cluster != nil {
+		if err := s.Cluster.Start(); err != nil {
+			return err
+		}
+	}
+
+	// Restore the dataset in the background, clients connecting meanwhile
+	// get -LOADING until it's done
+	commands.SetLoading(true)
+	go s.load()
+
+	limits := resp.Limits{
+		MaxBulkLen:      s.Config.ProtoMaxBulkLen,
+		MaxMultiBulkLen: resp.DefaultMaxMultiBulkLen,
+		MaxInlineLen:    resp.DefaultMaxInlineLen,
+	}
+
+	fmt.Println("Ready to accept connections")
+	return s.Listener.Serve(s.ctx, func(conn net.Conn) {
+		netlayer.HandleConn(conn, limits)
+	})
+}
+
+// load restores the snapshot and replays the AOF into the store, then
+// leaves the LOADING state and starts the background jobs that need the
+// full dataset
+func (s *Server) load() {
+	pos, err := s.loadSnapshot()
+	if err != nil {
+		log.Fatalf("Loading snapshot %s: %v", s.Config.DBFilename, err)
+	}
+
+	start := time.Now()
+	keys, count := 0, 0
+	err = persistence.ReplayFrom(s.Config.AOFDir, s.Config.AOFFilename, pos, func(key string, t store.ValueType, val any, expiry time.Time) {
+		if s.Store.Restore(key, t, val, expiry) {
+			keys++
+		}
+	}, func(v resp.Value) {
+		commands.Load(v)
 		count++
 	})
-	if count > 0 {
-		fmt.Printf("Replayed %d commands from AOF\n", count)
+	var loadErr *persistence.LoadError
+	switch {
+	case errors.As(err, &loadErr) && loadErr.Truncated && s.Config.AOFLoadTruncated:
+		// A torn final write after a crash, everything before it is loaded
+		log.Printf("Warning: %v, truncating the AOF there", err)
+		if s.AOF != nil {
+			if err := s.AOF.Truncate(loadErr.Offset); err != nil {
+				log.Fatalf("Truncating AOF in %s: %v", s.Config.AOFDir, err)
+			}
+		}
+	case err != nil:
+		log.Fatalf("Loading AOF in %s: %v, run aofcheck -fix to repair it", s.Config.AOFDir, err)
+	}
+	if keys > 0 {
+		fmt.Printf("Loaded %d keys from AOF preamble\n", keys)
+	}
+	if keys > 0 || count > 0 {
+		fmt.Printf("Replayed %d commands from AOF in %.3f seconds\n", count, time.Since(start).Seconds())
+	}
+
+	// Only writes from clients count towards the save rules
+	s.RDB.MarkClean(s.Store.Changes())
+
+	// A replica refuses writes from the moment clients get in
+	if s.Config.ReplicaOf != "" {
+		s.Repl.Follow()
+	}
+	commands.SetLoading(false)
+	if s.Config.ReplicaOf != "" {
+		handlers.ReplicaOf(s.Config.ReplicaOf)
 	}
 
 	// Start background expiration sweeper
 	s.Store.StartExpirer()
 	fmt.Println("Active expiration enabled")
 
-	fmt.Println("Ready to accept connections")
-	return s.Listener.Serve(s.ctx, netlayer.HandleConn)
+	go s.cron()
+	close(s.loaded)
+}
+
+// loadSnapshot restores the snapshot, if any, and returns the AOF position
+// replay should continue from, nil for all of it. The snapshot records
+// where the AOF stood when it was taken; if the AOF was rewritten since, it
+// holds everything and the snapshot is skipped. An empty AOF is rebuilt
+// from the snapshot.
+func (s *Server) loadSnapshot() (*persistence.AOFPosition, error) {
+	path := s.Config.DBFilename
+	info, err := persistence.ReadSnapshotInfo(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pos *persistence.AOFPosition
+	seedAOF := false
+	if s.AOF != nil {
+		switch {
+		case info.AOF != nil && persistence.Continues(s.Config.AOFDir, s.Config.AOFFilename, *info.AOF):
+			pos = info.AOF
+		case s.AOF.Stats().Size > 0:
+			log.Printf("Snapshot %s is older than the AOF, loading the AOF only", path)
+			return nil, nil
+		default:
+			seedAOF = true
+		}
+	}
+
+	start := time.Now()
+	keys := 0
+	_, err = persistence.LoadSnapshot(path, func(key string, t store.ValueType, val any, expiry time.Time) {
+		if s.Store.Restore(key, t, val, expiry) {
+			keys++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Loaded %d keys from snapshot in %.3f seconds\n", keys, time.Since(start).Seconds())
+
+	if seedAOF {
+		log.Println("AOF is empty, rewriting it from the snapshot")
+		if err := handlers.RewriteAOF(); err != nil {
+			log.Printf("Rewriting AOF from snapshot: %v", err)
+		}
+	}
+	return pos, nil
+}
+
+// listenPort returns the port of a listen address, announced to the master
+// when replicating
+func listenPort(addr string) int {
+	_, port, _ := net.SplitHostPort(addr)
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// announceIP returns the IP of a listen address, empty when it listens on
+// all interfaces
+func announceIP(addr string) string {
+	host, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return host
+}
+
+// cron runs periodic housekeeping until the server shuts down
+func (s *Server) cron() {
+	ticker := time.NewTicker(cronInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.AOF != nil && s.AOF.ShouldRewrite() {
+				log.Println("Starting automatic AOF rewrite")
+				if err := handlers.RewriteAOF(); err != nil {
+					log.Printf("Automatic AOF rewrite: %v", err)
+				}
+			}
+			if s.RDB.ShouldSave(s.Store.Changes()) {
+				log.Println("Save rule due, starting background save")
+				if err := handlers.BgSave(); err != nil {
+					log.Printf("Background save: %v", err)
+				}
+			}
+			s.Repl.Heartbeat()
+		}
+	}
 }
 
 func (s *Server) Shutdown() {
 	fmt.Println("Shutting down server...")
 
-	// Stop accepting new connections
+	// Stop accepting new connections, replicas don't disconnect on their own
 	s.cancel()
+	s.Repl.Close()
+	if s.Cluster != nil {
+		s.Cluster.Close()
+	}
 	s.Listener.Close()
 
-	// Stop AOF and ensure all pending writes are flushed
+	// Let a running load finish, the background jobs start after it
+	<-s.loaded
+
+	// Disconnect from the master
+	handlers.StopReplication()
+
 	// Stop background expiration sweeper
 	s.Store.StopExpirer()
 
+	// Save a final snapshot when snapshots are scheduled, like Redis does
+	s.RDB.Wait()
+	if len(s.Config.SaveRules) > 0 && s.RDB.Dirty(s.Store.Changes()) {
+		if err := handlers.Save(); err != nil {
+			log.Printf("Saving snapshot on shutdown: %v", err)
+		}
+	}
+
 	// Stop AOF and ensure all pending writes are flushed
 	if s.AOF != nil {
 		s.AOF.Stop()